@@ -3,28 +3,47 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/adevinta/graph-intel-api/gremlin"
+	"github.com/adevinta/graph-intel-api/health"
 	"github.com/adevinta/graph-intel-api/intel"
 	"github.com/adevinta/graph-intel-api/log"
 	"github.com/adevinta/graph-intel-api/rest"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
 	defaultLogLevel                  = "info"
+	defaultLogFormat                 = "text"
 	defaultListenAddr                = ":8000"
-	defaultGremlinAuthMode           = "none"
+	defaultReadinessTimeout          = 5 * time.Second
+	defaultShutdownGracePeriod       = 60 * time.Second
+	defaultGremlinAuthMode           = "plain"
 	defaultAWSRegion                 = "eu-west-1"
 	defaultGremlinRetryLimit         = 5
 	defaultGremlinRetryDuration      = 5 * time.Second
+	defaultGremlinPoolSize           = gremlin.DefaultPoolSize
+	defaultGremlinPoolIdleTimeout    = 5 * time.Minute
+	defaultGremlinPoolHealthCheck    = 30 * time.Second
 	defaultIntelResolveTimeoutMs     = 60000
 	defaultIntelBlastRadiusTimeoutMs = 60000
+	defaultIntelBatchConcurrency     = intel.DefaultBatchConcurrency
+	defaultIntelRiskWeight           = 1.0
+	defaultIntelAssetMaxDepth        = 3
+	defaultIntelTrendMaxSnapshots    = 100
 )
 
 func main() {
@@ -37,55 +56,139 @@ func main() {
 		log.Fatalf("graph-intel-api: error setting log level: %v", err)
 	}
 
-	if err := run(cfg); err != nil {
+	if err := log.SetFormat(cfg.LogFormat); err != nil {
+		log.Fatalf("graph-intel-api: error setting log format: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Restore the default behavior of the signals as soon as the first
+	// one is received, so a second signal force-exits the process
+	// during the shutdown grace period.
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		if err := runSeed(ctx, cfg.IntelConfig.GremlinConfig, os.Args[2:]); err != nil {
+			log.Fatalf("graph-intel-api: error seeding graph: %v", err)
+		}
+		return
+	}
+
+	if err := run(ctx, cfg); err != nil {
 		log.Fatalf("graph-intel-api: error running server %v", err)
 	}
 }
 
-// run does the actual work.
-func run(cfg config) error {
-	mux, err := setupMux(cfg.IntelConfig)
+// run does the actual work. It serves requests until ctx is done.
+func run(ctx context.Context, cfg config) error {
+	intelAPI, err := intel.NewAPI(cfg.IntelConfig)
 	if err != nil {
-		return fmt.Errorf("could not set up mux: %w", err)
+		return fmt.Errorf("error creating intel API: %w", err)
 	}
+	defer intelAPI.Close()
 
-	log.Info.Printf("graph-intel-api: listening on address %s", cfg.ListenAddr)
-	err = http.ListenAndServe(cfg.ListenAddr, mux)
-	if errors.Is(err, http.ErrServerClosed) {
-		log.Info.Printf("graph-intel-api: server closed")
-		return nil
+	if len(cfg.SeedFiles) > 0 {
+		if err := seed(ctx, cfg.IntelConfig.GremlinConfig, cfg.SeedFiles, false); err != nil {
+			return fmt.Errorf("error seeding graph: %w", err)
+		}
 	}
-	return err
-}
 
-// setupMux returns an [http.Handler] configured with the provided command
-// config.
-func setupMux(intelConfig intel.Config) (http.Handler, error) {
-	intelAPI, err := intel.NewAPI(intelConfig)
+	mux := setupMux(cfg, intelAPI)
+
+	ln, err := net.Listen("tcp", cfg.ListenAddr)
 	if err != nil {
-		return nil, fmt.Errorf("error creating intel API: %w", err)
+		return fmt.Errorf("could not listen: %w", err)
 	}
 
+	log.Info.Printf("graph-intel-api: listening on address %s", cfg.ListenAddr)
+	return serve(ctx, ln, mux, cfg.ShutdownGracePeriod)
+}
+
+// serve serves requests on ln using h until ctx is done. Then, it stops
+// accepting new connections and waits for the in-flight requests to finish.
+// If they do not finish within gracePeriod, their contexts are canceled and
+// the server is closed.
+func serve(ctx context.Context, ln net.Listener, h http.Handler, gracePeriod time.Duration) error {
+	// reqCtx is the parent context of all the requests. It is canceled
+	// when the grace period expires, so the in-flight requests abort
+	// their Gremlin queries.
+	reqCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := &http.Server{
+		Handler: h,
+		BaseContext: func(net.Listener) context.Context {
+			return reqCtx
+		},
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Info.Printf("graph-intel-api: shutting down server: grace period %v", gracePeriod)
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer shutdownCancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Error.Printf("graph-intel-api: grace period expired: canceling in-flight requests")
+		cancel()
+		if err := srv.Close(); err != nil {
+			return fmt.Errorf("could not close server: %w", err)
+		}
+	}
+
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	log.Info.Printf("graph-intel-api: server closed")
+	return nil
+}
+
+// setupMux returns an [http.Handler] configured with the provided command
+// config and intel API.
+func setupMux(cfg config, intelAPI intel.API) http.Handler {
 	restAPI := rest.NewAPI(intelAPI)
 	mux := http.NewServeMux()
 	mux.Handle("/", restAPI)
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", health.Liveness)
+	mux.Handle("/readyz", health.NewReadiness(intelAPI, cfg.ReadinessTimeout))
 
-	return mux, nil
+	return mux
 }
 
 // config defines the config parameters used by graph-intel-api.
 type config struct {
-	LogLevel    string
-	ListenAddr  string
-	IntelConfig intel.Config
+	LogLevel            string
+	LogFormat           string
+	ListenAddr          string
+	ReadinessTimeout    time.Duration
+	ShutdownGracePeriod time.Duration
+	SeedFiles           []string
+	IntelConfig         intel.Config
 }
 
 // readConfig reads the configuration parameters from the environment.
 func readConfig() (cfg config, err error) {
 	// Required configuration.
 
+	// The in-memory graph starts empty if no GraphSON file is provided.
 	gremlinEndpoint := os.Getenv("GREMLIN_ENDPOINT")
-	if gremlinEndpoint == "" {
+	if gremlinEndpoint == "" && os.Getenv("GREMLIN_AUTH_MODE") != "memory" {
 		return config{}, errors.New("missing GREMLIN_ENDPOINT env var")
 	}
 
@@ -96,11 +199,32 @@ func readConfig() (cfg config, err error) {
 		logLevel = level
 	}
 
+	logFormat := defaultLogFormat
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		logFormat = format
+	}
+
 	listenAddr := defaultListenAddr
 	if addr := os.Getenv("LISTEN_ADDR"); addr != "" {
 		listenAddr = addr
 	}
 
+	readinessTimeout := defaultReadinessTimeout
+	if timeout := os.Getenv("READINESS_TIMEOUT"); timeout != "" {
+		readinessTimeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid READINESS_TIMEOUT value")
+		}
+	}
+
+	shutdownGracePeriod := defaultShutdownGracePeriod
+	if period := os.Getenv("SHUTDOWN_GRACE_PERIOD"); period != "" {
+		shutdownGracePeriod, err = time.ParseDuration(period)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid SHUTDOWN_GRACE_PERIOD value")
+		}
+	}
+
 	gremlinAuthMode := defaultGremlinAuthMode
 	if mode := os.Getenv("GREMLIN_AUTH_MODE"); mode != "" {
 		gremlinAuthMode = mode
@@ -111,6 +235,32 @@ func readConfig() (cfg config, err error) {
 		awsRegion = region
 	}
 
+	gremlinUsername, err := readSecret("GREMLIN_USERNAME")
+	if err != nil {
+		return config{}, err
+	}
+
+	gremlinPassword, err := readSecret("GREMLIN_PASSWORD")
+	if err != nil {
+		return config{}, err
+	}
+
+	awsRoleARN := os.Getenv("GREMLIN_AWS_ROLE_ARN")
+	awsExternalID := os.Getenv("GREMLIN_AWS_EXTERNAL_ID")
+
+	gremlinTLSCAFile := os.Getenv("GREMLIN_TLS_CA_FILE")
+	gremlinTLSCertFile := os.Getenv("GREMLIN_TLS_CERT_FILE")
+	gremlinTLSKeyFile := os.Getenv("GREMLIN_TLS_KEY_FILE")
+	gremlinTLSServerName := os.Getenv("GREMLIN_TLS_SERVER_NAME")
+
+	gremlinTLSInsecureSkipVerify := false
+	if skip := os.Getenv("GREMLIN_TLS_INSECURE_SKIP_VERIFY"); skip != "" {
+		gremlinTLSInsecureSkipVerify, err = strconv.ParseBool(skip)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid GREMLIN_TLS_INSECURE_SKIP_VERIFY value")
+		}
+	}
+
 	gremlinRetryLimit := defaultGremlinRetryLimit
 	if limit := os.Getenv("GREMLIN_RETRY_LIMIT"); limit != "" {
 		gremlinRetryLimit, err = strconv.Atoi(limit)
@@ -127,6 +277,30 @@ func readConfig() (cfg config, err error) {
 		}
 	}
 
+	gremlinPoolSize := defaultGremlinPoolSize
+	if size := os.Getenv("GREMLIN_POOL_SIZE"); size != "" {
+		gremlinPoolSize, err = strconv.Atoi(size)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid GREMLIN_POOL_SIZE value")
+		}
+	}
+
+	gremlinPoolIdleTimeout := defaultGremlinPoolIdleTimeout
+	if timeout := os.Getenv("GREMLIN_POOL_IDLE_TIMEOUT"); timeout != "" {
+		gremlinPoolIdleTimeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid GREMLIN_POOL_IDLE_TIMEOUT value")
+		}
+	}
+
+	gremlinPoolHealthCheck := defaultGremlinPoolHealthCheck
+	if interval := os.Getenv("GREMLIN_POOL_HEALTH_CHECK_INTERVAL"); interval != "" {
+		gremlinPoolHealthCheck, err = time.ParseDuration(interval)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid GREMLIN_POOL_HEALTH_CHECK_INTERVAL value")
+		}
+	}
+
 	intelResolveTimeoutMs := defaultIntelResolveTimeoutMs
 	if timeout := os.Getenv("INTEL_RESOLVE_TIMEOUT_MS"); timeout != "" {
 		intelResolveTimeoutMs, err = strconv.Atoi(timeout)
@@ -143,20 +317,146 @@ func readConfig() (cfg config, err error) {
 		}
 	}
 
+	intelBatchConcurrency := defaultIntelBatchConcurrency
+	if concurrency := os.Getenv("INTEL_BATCH_CONCURRENCY"); concurrency != "" {
+		intelBatchConcurrency, err = strconv.Atoi(concurrency)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid INTEL_BATCH_CONCURRENCY value")
+		}
+	}
+
+	intelRiskWeightNet := defaultIntelRiskWeight
+	if weight := os.Getenv("INTEL_RISK_WEIGHT_NET"); weight != "" {
+		intelRiskWeightNet, err = strconv.ParseFloat(weight, 64)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid INTEL_RISK_WEIGHT_NET value")
+		}
+	}
+
+	intelRiskWeightInternetExposure := defaultIntelRiskWeight
+	if weight := os.Getenv("INTEL_RISK_WEIGHT_INTERNET_EXPOSURE"); weight != "" {
+		intelRiskWeightInternetExposure, err = strconv.ParseFloat(weight, 64)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid INTEL_RISK_WEIGHT_INTERNET_EXPOSURE value")
+		}
+	}
+
+	intelRiskWeightIAM := defaultIntelRiskWeight
+	if weight := os.Getenv("INTEL_RISK_WEIGHT_IAM"); weight != "" {
+		intelRiskWeightIAM, err = strconv.ParseFloat(weight, 64)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid INTEL_RISK_WEIGHT_IAM value")
+		}
+	}
+
+	intelAssetMaxDepth := defaultIntelAssetMaxDepth
+	if depth := os.Getenv("INTEL_ASSET_MAX_DEPTH"); depth != "" {
+		intelAssetMaxDepth, err = strconv.Atoi(depth)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid INTEL_ASSET_MAX_DEPTH value")
+		}
+	}
+
+	intelTrendMaxSnapshots := defaultIntelTrendMaxSnapshots
+	if snaps := os.Getenv("INTEL_TREND_MAX_SNAPSHOTS"); snaps != "" {
+		intelTrendMaxSnapshots, err = strconv.Atoi(snaps)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid INTEL_TREND_MAX_SNAPSHOTS value")
+		}
+	}
+
+	var seedFiles []string
+	if files := os.Getenv("SEED_FILES"); files != "" {
+		if gremlinAuthMode != "memory" {
+			return config{}, errors.New("SEED_FILES requires GREMLIN_AUTH_MODE=memory")
+		}
+		seedFiles = strings.Split(files, ",")
+	}
+
+	var intelModels []intel.ModelConfig
+	if path := os.Getenv("INTEL_MODELS_FILE"); path != "" {
+		intelModels, err = readModels(path)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid INTEL_MODELS_FILE value: %w", err)
+		}
+	}
+
 	cfg = config{
-		LogLevel:   logLevel,
-		ListenAddr: listenAddr,
+		LogLevel:            logLevel,
+		LogFormat:           logFormat,
+		ListenAddr:          listenAddr,
+		ReadinessTimeout:    readinessTimeout,
+		ShutdownGracePeriod: shutdownGracePeriod,
+		SeedFiles:           seedFiles,
 		IntelConfig: intel.Config{
 			GremlinConfig: gremlin.Config{
-				Endpoint:      gremlinEndpoint,
-				AuthMode:      gremlinAuthMode,
-				AWSRegion:     awsRegion,
-				RetryLimit:    gremlinRetryLimit,
-				RetryDuration: gremlinRetryDuration,
+				Endpoint:                gremlinEndpoint,
+				AuthMode:                gremlinAuthMode,
+				Username:                gremlinUsername,
+				Password:                gremlinPassword,
+				AWSRegion:               awsRegion,
+				AWSRoleARN:              awsRoleARN,
+				AWSExternalID:           awsExternalID,
+				TLSCAFile:               gremlinTLSCAFile,
+				TLSCertFile:             gremlinTLSCertFile,
+				TLSKeyFile:              gremlinTLSKeyFile,
+				TLSServerName:           gremlinTLSServerName,
+				TLSInsecureSkipVerify:   gremlinTLSInsecureSkipVerify,
+				RetryLimit:              gremlinRetryLimit,
+				RetryDuration:           gremlinRetryDuration,
+				PoolSize:                gremlinPoolSize,
+				PoolIdleTimeout:         gremlinPoolIdleTimeout,
+				PoolHealthCheckInterval: gremlinPoolHealthCheck,
 			},
 			ResolveTimeoutMs:     intelResolveTimeoutMs,
 			BlastRadiusTimeoutMs: intelBlastRadiusTimeoutMs,
+			BatchConcurrency:     intelBatchConcurrency,
+			Models:               intelModels,
+			RiskWeights: intel.RiskWeights{
+				Net:              intelRiskWeightNet,
+				InternetExposure: intelRiskWeightInternetExposure,
+				IAM:              intelRiskWeightIAM,
+			},
+			AssetMaxDepth:     intelAssetMaxDepth,
+			TrendMaxSnapshots: intelTrendMaxSnapshots,
 		},
 	}
 	return cfg, nil
 }
+
+// readModels reads the configurable blast radius models from the JSON file
+// in path.
+func readModels(path string) ([]intel.ModelConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file: %w", err)
+	}
+	defer f.Close()
+
+	var models []intel.ModelConfig
+	if err := json.NewDecoder(f).Decode(&models); err != nil {
+		return nil, fmt.Errorf("could not parse file: %w", err)
+	}
+	return models, nil
+}
+
+// readSecret returns the value of the environment variable name. If the
+// environment variable name_FILE is set, the value is read from the file it
+// points to instead, ignoring trailing newlines. This allows to use secrets
+// mounted as files. Setting both environment variables is an error.
+func readSecret(name string) (string, error) {
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return os.Getenv(name), nil
+	}
+
+	if os.Getenv(name) != "" {
+		return "", fmt.Errorf("%v and %v_FILE are mutually exclusive", name, name)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid %v_FILE value: %w", name, err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
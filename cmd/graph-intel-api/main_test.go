@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,7 +20,18 @@ import (
 	"github.com/google/go-cmp/cmp"
 )
 
-const gremlinEndpoint = "ws://127.0.0.1:8182/gremlin"
+// gremlinEndpoint and gremlinAuthMode configure the Gremlin backend used by
+// the tests. By default, the tests use an in-memory graph. If the
+// GREMLIN_TEST_ENDPOINT environment variable is set, they use the Gremlin
+// server listening on that endpoint.
+var gremlinEndpoint, gremlinAuthMode = gremlinTestBackend()
+
+func gremlinTestBackend() (endpoint, authMode string) {
+	if endpoint := os.Getenv("GREMLIN_TEST_ENDPOINT"); endpoint != "" {
+		return endpoint, "plain"
+	}
+	return "", "memory"
+}
 
 type blastRadiusResp struct {
 	Score    float64 `json:"score"`
@@ -30,14 +46,15 @@ var wantBlastRadiusResp = blastRadiusResp{
 func setupBlastRadiusGraph() error {
 	gremlinConfig := gremlin.Config{
 		Endpoint: gremlinEndpoint,
-		AuthMode: "plain",
+		AuthMode: gremlinAuthMode,
 	}
 	conn, err := gremlin.NewConnection(gremlinConfig)
 	if err != nil {
 		return fmt.Errorf("error creating Gremlin connection: %w", err)
 	}
+	defer conn.Close()
 
-	_, err = conn.Query(func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+	_, err = conn.Query(context.Background(), "setup", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
 		<-g.V().Drop().Iterate()
 		<-g.
 			AddV("Universe").Property(gremlingo.T.Id, "u0").Property("namespace", "altimeter").Property("version", 1).As("u0").
@@ -87,19 +104,23 @@ func TestSetupMux_BlastRadius(t *testing.T) {
 		t.Fatalf("error setting up the initial graph: %v", err)
 	}
 
-	cfg := intel.Config{
-		GremlinConfig: gremlin.Config{
-			Endpoint: gremlinEndpoint,
-			AuthMode: "plain",
+	cfg := config{
+		IntelConfig: intel.Config{
+			GremlinConfig: gremlin.Config{
+				Endpoint: gremlinEndpoint,
+				AuthMode: gremlinAuthMode,
+			},
+			ResolveTimeoutMs:     60000,
+			BlastRadiusTimeoutMs: 60000,
 		},
-		ResolveTimeoutMs:     60000,
-		BlastRadiusTimeoutMs: 60000,
 	}
-	mux, err := setupMux(cfg)
+	intelAPI, err := intel.NewAPI(cfg.IntelConfig)
 	if err != nil {
-		t.Fatalf("could not set up mux: %v", err)
+		t.Fatalf("error creating intel API: %v", err)
 	}
-	ts := httptest.NewServer(mux)
+	defer intelAPI.Close()
+
+	ts := httptest.NewServer(setupMux(cfg, intelAPI))
 	defer ts.Close()
 
 	url := ts.URL + "/v1/blast-radius?asset_type=IP&asset_identifier=1.2.3.4"
@@ -123,6 +144,171 @@ func TestSetupMux_BlastRadius(t *testing.T) {
 	}
 }
 
+func TestSetupMux_Metrics(t *testing.T) {
+	cfg := config{
+		IntelConfig: intel.Config{
+			GremlinConfig: gremlin.Config{
+				Endpoint: gremlinEndpoint,
+				AuthMode: gremlinAuthMode,
+			},
+		},
+	}
+	intelAPI, err := intel.NewAPI(cfg.IntelConfig)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	ts := httptest.NewServer(setupMux(cfg, intelAPI))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("request error: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: got=%v want=%v", res.StatusCode, http.StatusOK)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("could not read body: %v", err)
+	}
+
+	if !strings.Contains(string(body), "graph_intel_api_gremlin_connections_created_total") {
+		t.Errorf("missing Gremlin metrics")
+	}
+}
+
+func TestSetupMux_Health(t *testing.T) {
+	if err := setupBlastRadiusGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := config{
+		ReadinessTimeout: 5 * time.Second,
+		IntelConfig: intel.Config{
+			GremlinConfig: gremlin.Config{
+				Endpoint: gremlinEndpoint,
+				AuthMode: gremlinAuthMode,
+			},
+		},
+	}
+	intelAPI, err := intel.NewAPI(cfg.IntelConfig)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	ts := httptest.NewServer(setupMux(cfg, intelAPI))
+	defer ts.Close()
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		res, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("request error: %v", err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("unexpected status for %v: got=%v want=%v", path, res.StatusCode, http.StatusOK)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	tests := []struct {
+		name        string
+		gracePeriod time.Duration
+		handlerWait time.Duration
+		wantStatus  int
+		wantCancel  bool
+	}{
+		{
+			name:        "drain in-flight requests",
+			gracePeriod: 5 * time.Second,
+			handlerWait: 100 * time.Millisecond,
+			wantStatus:  http.StatusOK,
+			wantCancel:  false,
+		},
+		{
+			name:        "grace period expired",
+			gracePeriod: 100 * time.Millisecond,
+			handlerWait: time.Minute,
+			wantCancel:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			canceled := make(chan bool, 1)
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				select {
+				case <-r.Context().Done():
+					canceled <- true
+				case <-time.After(tt.handlerWait):
+					canceled <- false
+					w.WriteHeader(http.StatusOK)
+				}
+			})
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("could not listen: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			errc := make(chan error, 1)
+			go func() {
+				errc <- serve(ctx, ln, h, tt.gracePeriod)
+			}()
+
+			type response struct {
+				status int
+				err    error
+			}
+			resc := make(chan response, 1)
+			go func() {
+				res, err := http.Get("http://" + ln.Addr().String())
+				if err != nil {
+					resc <- response{err: err}
+					return
+				}
+				res.Body.Close()
+				resc <- response{status: res.StatusCode}
+			}()
+
+			<-started
+			cancel()
+
+			if err := <-errc; err != nil {
+				t.Fatalf("serve error: %v", err)
+			}
+
+			if got := <-canceled; got != tt.wantCancel {
+				t.Errorf("unexpected request cancelation: got=%v want=%v", got, tt.wantCancel)
+			}
+
+			res := <-resc
+			if tt.wantStatus == 0 {
+				return
+			}
+			if res.err != nil {
+				t.Fatalf("request error: %v", res.err)
+			}
+			if res.status != tt.wantStatus {
+				t.Errorf("unexpected status: got=%v want=%v", res.status, tt.wantStatus)
+			}
+		})
+	}
+}
+
 func TestReadConfig(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -136,18 +322,32 @@ func TestReadConfig(t *testing.T) {
 				"GREMLIN_ENDPOINT": "ws://127.0.0.1:8182/gremlin",
 			},
 			wantConfig: config{
-				LogLevel:   defaultLogLevel,
-				ListenAddr: defaultListenAddr,
+				LogLevel:            defaultLogLevel,
+				LogFormat:           defaultLogFormat,
+				ListenAddr:          defaultListenAddr,
+				ReadinessTimeout:    defaultReadinessTimeout,
+				ShutdownGracePeriod: defaultShutdownGracePeriod,
 				IntelConfig: intel.Config{
 					GremlinConfig: gremlin.Config{
-						Endpoint:      "ws://127.0.0.1:8182/gremlin",
-						AuthMode:      defaultGremlinAuthMode,
-						AWSRegion:     defaultAWSRegion,
-						RetryLimit:    defaultGremlinRetryLimit,
-						RetryDuration: defaultGremlinRetryDuration,
+						Endpoint:                "ws://127.0.0.1:8182/gremlin",
+						AuthMode:                defaultGremlinAuthMode,
+						AWSRegion:               defaultAWSRegion,
+						RetryLimit:              defaultGremlinRetryLimit,
+						RetryDuration:           defaultGremlinRetryDuration,
+						PoolSize:                defaultGremlinPoolSize,
+						PoolIdleTimeout:         defaultGremlinPoolIdleTimeout,
+						PoolHealthCheckInterval: defaultGremlinPoolHealthCheck,
 					},
 					ResolveTimeoutMs:     defaultIntelResolveTimeoutMs,
 					BlastRadiusTimeoutMs: defaultIntelBlastRadiusTimeoutMs,
+					BatchConcurrency:     defaultIntelBatchConcurrency,
+					RiskWeights: intel.RiskWeights{
+						Net:              defaultIntelRiskWeight,
+						InternetExposure: defaultIntelRiskWeight,
+						IAM:              defaultIntelRiskWeight,
+					},
+					AssetMaxDepth:     defaultIntelAssetMaxDepth,
+					TrendMaxSnapshots: defaultIntelTrendMaxSnapshots,
 				},
 			},
 			wantNilErr: true,
@@ -155,29 +355,86 @@ func TestReadConfig(t *testing.T) {
 		{
 			name: "set optional config",
 			env: map[string]string{
-				"GREMLIN_ENDPOINT":              "ws://127.0.0.1:8182/gremlin",
-				"LOG_LEVEL":                     "error",
-				"LISTEN_ADDR":                   ":1234",
-				"GREMLIN_AUTH_MODE":             "neptune_iam",
-				"AWS_REGION":                    "eu-west-2",
-				"GREMLIN_RETRY_LIMIT":           "10",
-				"GREMLIN_RETRY_DURATION":        "10s",
-				"INTEL_RESOLVE_TIMEOUT_MS":      "30000",
-				"INTEL_BLAST_RADIUS_TIMEOUT_MS": "30000",
+				"GREMLIN_ENDPOINT":                    "ws://127.0.0.1:8182/gremlin",
+				"LOG_LEVEL":                           "error",
+				"LOG_FORMAT":                          "json",
+				"LISTEN_ADDR":                         ":1234",
+				"READINESS_TIMEOUT":                   "1s",
+				"SHUTDOWN_GRACE_PERIOD":               "10s",
+				"GREMLIN_AUTH_MODE":                   "neptune_iam",
+				"GREMLIN_USERNAME":                    "user",
+				"GREMLIN_PASSWORD":                    "password",
+				"AWS_REGION":                          "eu-west-2",
+				"GREMLIN_AWS_ROLE_ARN":                "arn:aws:iam::123456789012:role/graph-intel-api",
+				"GREMLIN_AWS_EXTERNAL_ID":             "external-id",
+				"GREMLIN_TLS_CA_FILE":                 "/etc/gremlin/ca.pem",
+				"GREMLIN_TLS_CERT_FILE":               "/etc/gremlin/client.pem",
+				"GREMLIN_TLS_KEY_FILE":                "/etc/gremlin/client-key.pem",
+				"GREMLIN_TLS_SERVER_NAME":             "gremlin.example.com",
+				"GREMLIN_TLS_INSECURE_SKIP_VERIFY":    "true",
+				"GREMLIN_RETRY_LIMIT":                 "10",
+				"GREMLIN_RETRY_DURATION":              "10s",
+				"GREMLIN_POOL_SIZE":                   "20",
+				"GREMLIN_POOL_IDLE_TIMEOUT":           "1m",
+				"GREMLIN_POOL_HEALTH_CHECK_INTERVAL":  "10s",
+				"INTEL_RESOLVE_TIMEOUT_MS":            "30000",
+				"INTEL_BLAST_RADIUS_TIMEOUT_MS":       "30000",
+				"INTEL_BATCH_CONCURRENCY":             "20",
+				"INTEL_MODELS_FILE":                   "testdata/models.json",
+				"INTEL_RISK_WEIGHT_NET":               "2",
+				"INTEL_RISK_WEIGHT_INTERNET_EXPOSURE": "0.5",
+				"INTEL_RISK_WEIGHT_IAM":               "0",
+				"INTEL_ASSET_MAX_DEPTH":               "5",
+				"INTEL_TREND_MAX_SNAPSHOTS":           "50",
 			},
 			wantConfig: config{
-				LogLevel:   "error",
-				ListenAddr: ":1234",
+				LogLevel:            "error",
+				LogFormat:           "json",
+				ListenAddr:          ":1234",
+				ReadinessTimeout:    time.Second,
+				ShutdownGracePeriod: 10 * time.Second,
 				IntelConfig: intel.Config{
 					GremlinConfig: gremlin.Config{
-						Endpoint:      "ws://127.0.0.1:8182/gremlin",
-						AuthMode:      "neptune_iam",
-						AWSRegion:     "eu-west-2",
-						RetryLimit:    10,
-						RetryDuration: 10 * time.Second,
+						Endpoint:                "ws://127.0.0.1:8182/gremlin",
+						AuthMode:                "neptune_iam",
+						Username:                "user",
+						Password:                "password",
+						AWSRegion:               "eu-west-2",
+						AWSRoleARN:              "arn:aws:iam::123456789012:role/graph-intel-api",
+						AWSExternalID:           "external-id",
+						TLSCAFile:               "/etc/gremlin/ca.pem",
+						TLSCertFile:             "/etc/gremlin/client.pem",
+						TLSKeyFile:              "/etc/gremlin/client-key.pem",
+						TLSServerName:           "gremlin.example.com",
+						TLSInsecureSkipVerify:   true,
+						RetryLimit:              10,
+						RetryDuration:           10 * time.Second,
+						PoolSize:                20,
+						PoolIdleTimeout:         time.Minute,
+						PoolHealthCheckInterval: 10 * time.Second,
 					},
 					ResolveTimeoutMs:     30000,
 					BlastRadiusTimeoutMs: 30000,
+					BatchConcurrency:     20,
+					Models: []intel.ModelConfig{
+						{
+							Name: "critical",
+							Weights: map[string]float64{
+								"ec2:security-group": 0,
+								"rds:db":             10,
+							},
+							DefaultWeight: 1,
+							Decay:         "exponential",
+							DecayRate:     0.5,
+						},
+					},
+					RiskWeights: intel.RiskWeights{
+						Net:              2,
+						InternetExposure: 0.5,
+						IAM:              0,
+					},
+					AssetMaxDepth:     5,
+					TrendMaxSnapshots: 50,
 				},
 			},
 			wantNilErr: true,
@@ -188,6 +445,93 @@ func TestReadConfig(t *testing.T) {
 			wantConfig: config{},
 			wantNilErr: false,
 		},
+		{
+			name: "missing INTEL_MODELS_FILE",
+			env: map[string]string{
+				"GREMLIN_ENDPOINT":  "ws://127.0.0.1:8182/gremlin",
+				"INTEL_MODELS_FILE": "testdata/unknown.json",
+			},
+			wantConfig: config{},
+			wantNilErr: false,
+		},
+		{
+			name: "invalid INTEL_RISK_WEIGHT_NET",
+			env: map[string]string{
+				"GREMLIN_ENDPOINT":      "ws://127.0.0.1:8182/gremlin",
+				"INTEL_RISK_WEIGHT_NET": "high",
+			},
+			wantConfig: config{},
+			wantNilErr: false,
+		},
+		{
+			name: "basic auth with secret files",
+			env: map[string]string{
+				"GREMLIN_ENDPOINT":      "ws://127.0.0.1:8182/gremlin",
+				"GREMLIN_AUTH_MODE":     "basic",
+				"GREMLIN_USERNAME":      "user",
+				"GREMLIN_PASSWORD_FILE": "testdata/password",
+			},
+			wantConfig: config{
+				LogLevel:            defaultLogLevel,
+				LogFormat:           defaultLogFormat,
+				ListenAddr:          defaultListenAddr,
+				ReadinessTimeout:    defaultReadinessTimeout,
+				ShutdownGracePeriod: defaultShutdownGracePeriod,
+				IntelConfig: intel.Config{
+					GremlinConfig: gremlin.Config{
+						Endpoint:                "ws://127.0.0.1:8182/gremlin",
+						AuthMode:                "basic",
+						Username:                "user",
+						Password:                "s3cr3t",
+						AWSRegion:               defaultAWSRegion,
+						RetryLimit:              defaultGremlinRetryLimit,
+						RetryDuration:           defaultGremlinRetryDuration,
+						PoolSize:                defaultGremlinPoolSize,
+						PoolIdleTimeout:         defaultGremlinPoolIdleTimeout,
+						PoolHealthCheckInterval: defaultGremlinPoolHealthCheck,
+					},
+					ResolveTimeoutMs:     defaultIntelResolveTimeoutMs,
+					BlastRadiusTimeoutMs: defaultIntelBlastRadiusTimeoutMs,
+					BatchConcurrency:     defaultIntelBatchConcurrency,
+					RiskWeights: intel.RiskWeights{
+						Net:              defaultIntelRiskWeight,
+						InternetExposure: defaultIntelRiskWeight,
+						IAM:              defaultIntelRiskWeight,
+					},
+					AssetMaxDepth:     defaultIntelAssetMaxDepth,
+					TrendMaxSnapshots: defaultIntelTrendMaxSnapshots,
+				},
+			},
+			wantNilErr: true,
+		},
+		{
+			name: "missing GREMLIN_PASSWORD_FILE",
+			env: map[string]string{
+				"GREMLIN_ENDPOINT":      "ws://127.0.0.1:8182/gremlin",
+				"GREMLIN_PASSWORD_FILE": "testdata/unknown",
+			},
+			wantConfig: config{},
+			wantNilErr: false,
+		},
+		{
+			name: "GREMLIN_PASSWORD and GREMLIN_PASSWORD_FILE",
+			env: map[string]string{
+				"GREMLIN_ENDPOINT":      "ws://127.0.0.1:8182/gremlin",
+				"GREMLIN_PASSWORD":      "password",
+				"GREMLIN_PASSWORD_FILE": "testdata/password",
+			},
+			wantConfig: config{},
+			wantNilErr: false,
+		},
+		{
+			name: "invalid GREMLIN_TLS_INSECURE_SKIP_VERIFY",
+			env: map[string]string{
+				"GREMLIN_ENDPOINT":                 "ws://127.0.0.1:8182/gremlin",
+				"GREMLIN_TLS_INSECURE_SKIP_VERIFY": "maybe",
+			},
+			wantConfig: config{},
+			wantNilErr: false,
+		},
 		{
 			name: "invalid GREMLIN_RETRY_DURATION",
 			env: map[string]string{
@@ -204,22 +548,82 @@ func TestReadConfig(t *testing.T) {
 				"GREMLIN_RETRY_DURATION": "0",
 			},
 			wantConfig: config{
-				LogLevel:   defaultLogLevel,
-				ListenAddr: defaultListenAddr,
+				LogLevel:            defaultLogLevel,
+				LogFormat:           defaultLogFormat,
+				ListenAddr:          defaultListenAddr,
+				ReadinessTimeout:    defaultReadinessTimeout,
+				ShutdownGracePeriod: defaultShutdownGracePeriod,
 				IntelConfig: intel.Config{
 					GremlinConfig: gremlin.Config{
-						Endpoint:      "ws://127.0.0.1:8182/gremlin",
-						AuthMode:      defaultGremlinAuthMode,
-						AWSRegion:     defaultAWSRegion,
-						RetryLimit:    defaultGremlinRetryLimit,
-						RetryDuration: 0,
+						Endpoint:                "ws://127.0.0.1:8182/gremlin",
+						AuthMode:                defaultGremlinAuthMode,
+						AWSRegion:               defaultAWSRegion,
+						RetryLimit:              defaultGremlinRetryLimit,
+						RetryDuration:           0,
+						PoolSize:                defaultGremlinPoolSize,
+						PoolIdleTimeout:         defaultGremlinPoolIdleTimeout,
+						PoolHealthCheckInterval: defaultGremlinPoolHealthCheck,
 					},
 					ResolveTimeoutMs:     defaultIntelResolveTimeoutMs,
 					BlastRadiusTimeoutMs: defaultIntelBlastRadiusTimeoutMs,
+					BatchConcurrency:     defaultIntelBatchConcurrency,
+					RiskWeights: intel.RiskWeights{
+						Net:              defaultIntelRiskWeight,
+						InternetExposure: defaultIntelRiskWeight,
+						IAM:              defaultIntelRiskWeight,
+					},
+					AssetMaxDepth:     defaultIntelAssetMaxDepth,
+					TrendMaxSnapshots: defaultIntelTrendMaxSnapshots,
 				},
 			},
 			wantNilErr: true,
 		},
+		{
+			name: "empty in-memory graph with seed files",
+			env: map[string]string{
+				"GREMLIN_AUTH_MODE": "memory",
+				"SEED_FILES":        "a.yaml,b.yaml",
+			},
+			wantConfig: config{
+				LogLevel:            defaultLogLevel,
+				LogFormat:           defaultLogFormat,
+				ListenAddr:          defaultListenAddr,
+				ReadinessTimeout:    defaultReadinessTimeout,
+				ShutdownGracePeriod: defaultShutdownGracePeriod,
+				SeedFiles:           []string{"a.yaml", "b.yaml"},
+				IntelConfig: intel.Config{
+					GremlinConfig: gremlin.Config{
+						AuthMode:                "memory",
+						AWSRegion:               defaultAWSRegion,
+						RetryLimit:              defaultGremlinRetryLimit,
+						RetryDuration:           defaultGremlinRetryDuration,
+						PoolSize:                defaultGremlinPoolSize,
+						PoolIdleTimeout:         defaultGremlinPoolIdleTimeout,
+						PoolHealthCheckInterval: defaultGremlinPoolHealthCheck,
+					},
+					ResolveTimeoutMs:     defaultIntelResolveTimeoutMs,
+					BlastRadiusTimeoutMs: defaultIntelBlastRadiusTimeoutMs,
+					BatchConcurrency:     defaultIntelBatchConcurrency,
+					RiskWeights: intel.RiskWeights{
+						Net:              defaultIntelRiskWeight,
+						InternetExposure: defaultIntelRiskWeight,
+						IAM:              defaultIntelRiskWeight,
+					},
+					AssetMaxDepth:     defaultIntelAssetMaxDepth,
+					TrendMaxSnapshots: defaultIntelTrendMaxSnapshots,
+				},
+			},
+			wantNilErr: true,
+		},
+		{
+			name: "SEED_FILES without in-memory graph",
+			env: map[string]string{
+				"GREMLIN_ENDPOINT": "ws://127.0.0.1:8182/gremlin",
+				"SEED_FILES":       "a.yaml",
+			},
+			wantConfig: config{},
+			wantNilErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/adevinta/graph-intel-api/gremlin"
+	"github.com/adevinta/graph-intel-api/log"
+	"github.com/adevinta/graph-intel-api/scenario"
+)
+
+// seedUsage is the usage message of the seed subcommand.
+const seedUsage = `usage: graph-intel-api seed [-reset] file...
+
+Seed loads the provided scenario files into the Gremlin server configured by
+the GREMLIN_* environment variables. All the files are validated before
+modifying the graph.
+
+The in-memory graph used with GREMLIN_AUTH_MODE=memory only lives as long as
+the process, so it cannot be seeded with this subcommand. Use the SEED_FILES
+environment variable to seed it when the server starts.
+`
+
+// runSeed runs the seed subcommand with the provided command line
+// arguments.
+func runSeed(ctx context.Context, cfg gremlin.Config, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), seedUsage)
+		fs.PrintDefaults()
+	}
+	reset := fs.Bool("reset", false, "remove all the vertices and edges before seeding")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("missing scenario files")
+	}
+
+	return seed(ctx, cfg, fs.Args(), *reset)
+}
+
+// seed loads the scenario files in paths into the Gremlin server configured
+// by cfg. If reset is true, all the vertices and edges are removed before
+// seeding the graph.
+func seed(ctx context.Context, cfg gremlin.Config, paths []string, reset bool) error {
+	var scenarios []scenario.Scenario
+	for _, path := range paths {
+		sc, err := scenario.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read %v: %w", path, err)
+		}
+		scenarios = append(scenarios, sc)
+	}
+
+	conn, err := gremlin.NewConnection(cfg)
+	if err != nil {
+		return fmt.Errorf("error creating Gremlin connection: %w", err)
+	}
+	defer conn.Close()
+
+	if reset {
+		if err := scenario.Reset(ctx, conn); err != nil {
+			return fmt.Errorf("could not reset graph: %w", err)
+		}
+	}
+
+	for i, sc := range scenarios {
+		path := paths[i]
+		if err := scenario.Seed(ctx, conn, sc); err != nil {
+			return fmt.Errorf("could not seed %v: %w", path, err)
+		}
+		log.Info.Printf("graph-intel-api: seeded scenario %v", path)
+	}
+	return nil
+}
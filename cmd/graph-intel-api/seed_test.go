@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adevinta/graph-intel-api/gremlin"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+)
+
+func TestRunSeed(t *testing.T) {
+	cfg := gremlin.Config{
+		Endpoint: gremlinEndpoint,
+		AuthMode: gremlinAuthMode,
+	}
+
+	// Seeding twice with -reset must not fail because of duplicated IDs.
+	for i := 0; i < 2; i++ {
+		if err := runSeed(context.Background(), cfg, []string{"-reset", "../../_scenario/public-elb.yaml"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	conn, err := gremlin.NewConnection(cfg)
+	if err != nil {
+		t.Fatalf("error creating Gremlin connection: %v", err)
+	}
+	defer conn.Close()
+
+	results, err := conn.Query(context.Background(), "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return g.V().HasLabel("elbv2:loadbalancer").Has("scheme", "internet-facing").In("includes").Id().ToList()
+	})
+	if err != nil {
+		t.Fatalf("query error: %v", err)
+	}
+
+	if len(results) != 1 || results[0].GetString() != "snapshot-1" {
+		t.Errorf("unexpected results: %v", results)
+	}
+}
+
+func TestRunSeed_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "no files",
+			args: nil,
+		},
+		{
+			name: "unknown flag",
+			args: []string{"-unknown", "../../_scenario/public-elb.yaml"},
+		},
+		{
+			name: "missing file",
+			args: []string{"missing.yaml"},
+		},
+	}
+
+	cfg := gremlin.Config{
+		Endpoint: gremlinEndpoint,
+		AuthMode: gremlinAuthMode,
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := runSeed(context.Background(), cfg, tt.args); err == nil {
+				t.Error("unexpected nil error")
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package gremlin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"testing"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+	"github.com/google/go-cmp/cmp"
+)
+
+// altimeterGraph is a GraphSON file with an altimeter-shaped graph. It
+// contains a Universe with two snapshots. The network interface ni0 can reach
+// the ip_range r0 through its security group sg0, and the instance i0 is
+// allowed to reach sg0 through sg1, which can reach the ip_range r1. The
+// second snapshot includes all the resources and the first one only ni0, sg0
+// and the resources it can reach.
+const altimeterGraph = "testdata/altimeter.json"
+
+// newAltimeterConnection returns a connection with the Gremlin backend used
+// by the tests loaded with [altimeterGraph]. When the tests run against a
+// Gremlin server, the GREMLIN_TEST_DATA_DIR environment variable must point
+// to the directory of the Gremlin server that contains the GraphSON file.
+func newAltimeterConnection(t *testing.T) Connection {
+	if gremlinAuthMode == "memory" {
+		conn, err := NewConnection(Config{Endpoint: altimeterGraph, AuthMode: "memory"})
+		if err != nil {
+			t.Fatalf("error creating connection: %v", err)
+		}
+		return conn
+	}
+
+	dir := os.Getenv("GREMLIN_TEST_DATA_DIR")
+	if dir == "" {
+		t.Skip("GREMLIN_TEST_DATA_DIR is not set")
+	}
+
+	conn, err := NewConnection(Config{Endpoint: gremlinEndpoint, AuthMode: gremlinAuthMode})
+	if err != nil {
+		t.Fatalf("error creating connection: %v", err)
+	}
+
+	_, err = conn.QueryOnce(context.Background(), "setup", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		if err := <-g.V().Drop().Iterate(); err != nil {
+			return nil, err
+		}
+		return nil, <-g.Io(path.Join(dir, path.Base(altimeterGraph))).Read().Iterate()
+	})
+	if err != nil {
+		conn.Close()
+		t.Fatalf("error loading graph: %v", err)
+	}
+	return conn
+}
+
+// TestConnectionQuery_Conformance runs the kind of traversals used by the
+// intel package against an altimeter-shaped graph. The same tests run against
+// the in-memory graph and, with _script/test, against a Gremlin server, so
+// they detect semantic differences between both backends.
+func TestConnectionQuery_Conformance(t *testing.T) {
+	tests := []struct {
+		name  string
+		query QueryFunc
+
+		// unordered reports whether the order of the results is not
+		// defined. If so, the results are sorted before comparing
+		// them.
+		unordered bool
+
+		want []any
+	}{
+		{
+			name: "reachable resources",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.
+					V("ni0").
+					Union(
+						gremlingo.T__.Identity(),
+						gremlingo.T__.OutE("resource_link").InV(),
+						gremlingo.T__.OutE("transient_resource_link").InV(),
+					).
+					HasLabel("ec2:security-group").
+					Repeat(
+						gremlingo.T__.
+							Union(
+								gremlingo.T__.
+									OutE("egress_rule").InV().HasLabel("egress_rule").
+									OutE("ip_range").InV().HasLabel("ip_range"),
+								gremlingo.T__.
+									InE("resource_link").OutV().HasLabel("user_id_group_pairs").
+									InE("user_id_group_pairs").OutV().HasLabel("ingress_rule").
+									InE("ingress_rule").OutV().HasLabel("ec2:security-group").
+									Union(
+										gremlingo.T__.Identity(),
+										gremlingo.T__.InE().OutV().Or(
+											gremlingo.T__.HasLabel("ec2:instance"),
+											gremlingo.T__.HasLabel("rds:db"),
+										),
+									),
+							).
+							SimplePath(),
+					).
+					Times(3).
+					Emit().
+					Project("id", "resource", "steps").
+					By(gremlingo.T__.Id()).
+					By(gremlingo.T__.Coalesce(
+						gremlingo.T__.Values("arn"),
+						gremlingo.T__.Values("cidr_ip"),
+						gremlingo.T__.Id(),
+					)).
+					By(gremlingo.T__.Path().Count(gremlingo.Scope.Local)).
+					ToList()
+			},
+			unordered: true,
+			want: []any{
+				map[any]any{"id": "i0", "resource": "arn:aws:ec2:eu-west-1:123456789012:instance/i0", "steps": int64(11)},
+				map[any]any{"id": "r0", "resource": "0.0.0.0/0", "steps": int64(7)},
+				map[any]any{"id": "r1", "resource": "10.0.0.0/8", "steps": int64(13)},
+				map[any]any{"id": "sg1", "resource": "arn:aws:ec2:eu-west-1:123456789012:security-group/sg1", "steps": int64(9)},
+			},
+		},
+		{
+			name: "path",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.
+					V("ni0").
+					OutE("resource_link").InV().
+					Repeat(gremlingo.T__.OutE().InV().SimplePath()).
+					Emit().
+					HasLabel("ip_range").
+					Path().
+					ToList()
+			},
+			want: []any{
+				[]any{"v[ni0]", "e[ni0-resource_link-sg0]", "v[sg0]", "e[sg0-egress_rule-er0]", "v[er0]", "e[er0-ip_range-r0]", "v[r0]"},
+			},
+		},
+		{
+			name: "arn suffix",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.
+					V().
+					HasLabel("ec2:instance", "ec2:network-interface").
+					Has("arn", gremlingo.TextP.EndingWith("/i0")).
+					Id().
+					ToList()
+			},
+			want: []any{"i0"},
+		},
+		{
+			name: "keyset page",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.
+					V("s1").
+					Out("includes").
+					Has(gremlingo.T.Id, gremlingo.P.Gt("i0")).
+					Order().By(gremlingo.T__.Id()).
+					Limit(3).
+					Id().
+					ToList()
+			},
+			want: []any{"ir0", "ni0", "r0"},
+		},
+		{
+			name: "snapshots in range",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.
+					V().
+					HasLabel("altimeter_snapshot").
+					Where(gremlingo.T__.In("universe_of").HasLabel("Universe").Has("namespace", "altimeter").Has("version", 1)).
+					Has("timestamp", gremlingo.P.Lte(int64(2000))).
+					Order().By("timestamp", gremlingo.Order.Desc).
+					Project("id", "timestamp").
+					By(gremlingo.T__.Id()).
+					By(gremlingo.T__.Values("timestamp")).
+					ToList()
+			},
+			want: []any{
+				map[any]any{"id": "s1", "timestamp": int64(2000)},
+				map[any]any{"id": "s0", "timestamp": int64(1000)},
+			},
+		},
+		{
+			name: "snapshots of resource",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.
+					V("r1").
+					In("includes").
+					HasLabel("altimeter_snapshot").
+					Id().
+					ToList()
+			},
+			want: []any{"s1"},
+		},
+	}
+
+	conn := newAltimeterConnection(t)
+	defer conn.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := conn.Query(context.Background(), "test", tt.query)
+			if err != nil {
+				t.Fatalf("query error: %v", err)
+			}
+
+			var got []any
+			for _, r := range results {
+				got = append(got, normalizeResult(r.GetInterface()))
+			}
+
+			if tt.unordered {
+				sort.Slice(got, func(i, j int) bool {
+					return fmt.Sprint(got[i]) < fmt.Sprint(got[j])
+				})
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("results mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+// normalizeResult returns a representation of a query result that does not
+// depend on the Gremlin backend. Vertices and edges are represented by their
+// IDs, and paths by the list of their objects.
+func normalizeResult(v any) any {
+	switch v := v.(type) {
+	case *gremlingo.Vertex:
+		return fmt.Sprintf("v[%v]", v.Id)
+	case *gremlingo.Edge:
+		return fmt.Sprintf("e[%v]", v.Id)
+	case *gremlingo.Path:
+		return normalizeResult(v.Objects)
+	case []any:
+		l := make([]any, len(v))
+		for i, e := range v {
+			l[i] = normalizeResult(e)
+		}
+		return l
+	case map[any]any:
+		m := make(map[any]any, len(v))
+		for k, e := range v {
+			m[normalizeResult(k)] = normalizeResult(e)
+		}
+		return m
+	}
+	return v
+}
@@ -0,0 +1,951 @@
+package gremlin
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adevinta/graph-intel-api/log"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+	"github.com/gorilla/websocket"
+)
+
+// cosmosMimeType is the MIME type of the GraphSON v2 serializer, which is the
+// only one supported by the Gremlin API of Azure Cosmos DB.
+const cosmosMimeType = "application/vnd.gremlin-v2.0+json"
+
+// Response status codes returned by Cosmos DB that are not returned by the
+// in-memory Gremlin server.
+const (
+	statusPartialContent = 206
+	statusUnauthorized   = 401
+	statusAuthRequired   = 407
+)
+
+// cosmosStatusCodeAttr is the status attribute of the responses that contains
+// the status code specific to Cosmos DB. Timeouts are reported with the
+// status code cosmosStatusTimeout.
+const (
+	cosmosStatusCodeAttr = "x-ms-status-code"
+	cosmosStatusTimeout  = 408
+)
+
+// cosmosHandshakeTimeout is the maximum time spent opening a websocket
+// connection with Cosmos DB.
+const cosmosHandshakeTimeout = 45 * time.Second
+
+// cosmosProxy is a Gremlin server that listens on the loopback interface and
+// forwards the bytecode traversals it receives to Azure Cosmos DB. The
+// Gremlin API of Cosmos DB only accepts string scripts serialized with
+// GraphSON v2, so the proxy translates the traversals into scripts and the
+// GraphSON responses into GraphBinary. Every websocket connection with the
+// proxy is backed by its own connection with Cosmos DB, which is
+// authenticated with SASL PLAIN using the database key.
+type cosmosProxy struct {
+	url      string
+	upgrader websocket.Upgrader
+	hs       *http.Server
+
+	// mu protects upstreams, which contains the connections with
+	// Cosmos DB waiting for their websocket connection with the proxy,
+	// indexed by the token included in the websocket URL.
+	mu        sync.Mutex
+	upstreams map[string]*cosmosConn
+}
+
+// newCosmosProxy starts a Cosmos DB proxy on a random local port.
+func newCosmosProxy() (*cosmosProxy, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("could not listen: %w", err)
+	}
+
+	proxy := &cosmosProxy{
+		url:       fmt.Sprintf("ws://%v/gremlin", ln.Addr()),
+		upstreams: make(map[string]*cosmosConn),
+	}
+	proxy.hs = &http.Server{Handler: proxy}
+	go func() {
+		if err := proxy.hs.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			log.Error.Printf("graph-intel-api: gremlin: Cosmos DB proxy error: %v", err)
+		}
+	}()
+
+	return proxy, nil
+}
+
+// close shuts down the proxy.
+func (proxy *cosmosProxy) close() {
+	if err := proxy.hs.Close(); err != nil {
+		log.Error.Printf("graph-intel-api: gremlin: error closing Cosmos DB proxy: %v", err)
+	}
+
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+
+	for token, cc := range proxy.upstreams {
+		cc.close()
+		delete(proxy.upstreams, token)
+	}
+}
+
+// connect is a [connHandler] for Azure Cosmos DB. It connects to Cosmos DB
+// and returns a connection with the proxy that uses it.
+func (proxy *cosmosProxy) connect(ctx context.Context, cfg Config) (*gremlingo.DriverRemoteConnection, error) {
+	log.FromContext(ctx).Debug.Printf("graph-intel-api: gremlin: connecting to Cosmos DB")
+	cc, err := dialCosmos(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		cc.close()
+		return nil, fmt.Errorf("could not generate token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+
+	proxy.mu.Lock()
+	proxy.upstreams[token] = cc
+	proxy.mu.Unlock()
+
+	conn, err := gremlingo.NewDriverRemoteConnection(proxy.url+"?upstream="+token, func(settings *gremlingo.DriverRemoteConnectionSettings) {
+		settings.LogVerbosity = gremlingo.Off
+	})
+	if err != nil {
+		if cc := proxy.upstream(token); cc != nil {
+			cc.close()
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// upstream removes the connection with Cosmos DB identified by token from
+// the waiting connections and returns it. It returns nil if there is no such
+// connection.
+func (proxy *cosmosProxy) upstream(token string) *cosmosConn {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+
+	cc := proxy.upstreams[token]
+	delete(proxy.upstreams, token)
+	return cc
+}
+
+// ServeHTTP handles a websocket connection. Requests are forwarded to the
+// connection with Cosmos DB identified by the URL of the request. Both
+// connections are closed together.
+func (proxy *cosmosProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cc := proxy.upstream(r.URL.Query().Get("upstream"))
+	if cc == nil {
+		http.Error(w, "unknown upstream connection", http.StatusNotFound)
+		return
+	}
+	defer cc.close()
+
+	ws, err := proxy.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	// Close the connection with the client if Cosmos DB closes its
+	// connection, so the client does not keep using it.
+	go func() {
+		<-cc.done
+		ws.Close()
+	}()
+
+	serveWebsocket(ws, func(msg []byte) ([]byte, error) {
+		return proxy.handle(cc, msg)
+	})
+}
+
+// handle processes a request using the connection with Cosmos DB cc and
+// returns the response. Errors returned by Cosmos DB are returned to the
+// client as server errors. It returns an error if the request could not be
+// sent to Cosmos DB or the response could not be read.
+func (proxy *cosmosProxy) handle(cc *cosmosConn, msg []byte) ([]byte, error) {
+	req, err := decodeRequest(msg)
+	if err != nil {
+		return gremlinResponse(req.id, statusMalformedRequest, fmt.Sprintf("invalid request: %v", err), nil), nil
+	}
+	if req.op != "bytecode" {
+		return gremlinResponse(req.id, statusMalformedRequest, fmt.Sprintf("unsupported operation %q", req.op), nil), nil
+	}
+
+	bc, ok := req.args["gremlin"].(*bytecode)
+	if !ok {
+		return gremlinResponse(req.id, statusMalformedRequest, "missing bytecode", nil), nil
+	}
+	script, err := translateBytecode(bc)
+	if err != nil {
+		return gremlinResponse(req.id, statusMalformedRequest, fmt.Sprintf("could not translate bytecode: %v", err), nil), nil
+	}
+
+	args := map[string]any{}
+	if timeout, ok := toInt(req.args["evaluationTimeout"]); ok && timeout > 0 {
+		args["evaluationTimeout"] = graphSONLong(timeout)
+	}
+
+	results, err := cc.submit(formatUUID(req.id), script, args)
+	var cerr *cosmosError
+	switch {
+	case errors.As(err, &cerr) && (cerr.code == statusTimeout || cerr.msStatusCode == cosmosStatusTimeout):
+		return timeoutResponse(req.id), nil
+	case errors.As(err, &cerr):
+		return gremlinResponse(req.id, cerr.code, cerr.Error(), nil), nil
+	case err != nil:
+		return nil, err
+	case len(results) == 0:
+		return gremlinResponse(req.id, statusNoContent, "", nil), nil
+	}
+	return gremlinResponse(req.id, statusSuccess, "", results), nil
+}
+
+// cosmosError is an error response returned by Cosmos DB.
+type cosmosError struct {
+	code         int
+	msStatusCode int64
+	msg          string
+}
+
+func (err *cosmosError) Error() string {
+	if err.msStatusCode != 0 {
+		return fmt.Sprintf("Cosmos DB error (status %v, %v %v): %v", err.code, cosmosStatusCodeAttr, err.msStatusCode, err.msg)
+	}
+	return fmt.Sprintf("Cosmos DB error (status %v): %v", err.code, err.msg)
+}
+
+// cosmosConn is a websocket connection with Cosmos DB. Requests can be
+// submitted concurrently.
+type cosmosConn struct {
+	ws       *websocket.Conn
+	username string
+	password string
+
+	// wmu serializes the writes to ws.
+	wmu sync.Mutex
+
+	// mu protects pending, which contains the channels where the
+	// responses of the in-flight requests are delivered, indexed by
+	// request ID, and err, which is the error that closed the
+	// connection.
+	mu      sync.Mutex
+	pending map[string]chan cosmosResponse
+	err     error
+
+	// done is closed when the connection is closed.
+	done chan struct{}
+}
+
+// cosmosResponse is a decoded GraphSON response message.
+type cosmosResponse struct {
+	requestID    string
+	code         int
+	msg          string
+	msStatusCode int64
+	data         []any
+}
+
+// dialCosmos connects to the Cosmos DB endpoint in cfg.
+func dialCosmos(ctx context.Context, cfg Config) (*cosmosConn, error) {
+	tlsCfg, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error loading TLS config: %w", err)
+	}
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: cosmosHandshakeTimeout,
+		TLSClientConfig:  tlsCfg,
+	}
+	ws, _, err := dialer.DialContext(ctx, cfg.Endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to Cosmos DB: %w", err)
+	}
+
+	cc := &cosmosConn{
+		ws:       ws,
+		username: cfg.Username,
+		password: cfg.Password,
+		pending:  make(map[string]chan cosmosResponse),
+		done:     make(chan struct{}),
+	}
+	go cc.readLoop()
+	return cc, nil
+}
+
+// close closes the connection. The in-flight requests fail.
+func (cc *cosmosConn) close() {
+	cc.ws.Close()
+}
+
+// readLoop reads the responses sent by Cosmos DB and delivers them to the
+// corresponding in-flight requests until the connection is closed.
+func (cc *cosmosConn) readLoop() {
+	for {
+		_, msg, err := cc.ws.ReadMessage()
+		if err != nil {
+			cc.fail(fmt.Errorf("could not read response: %w", err))
+			return
+		}
+
+		resp, err := decodeCosmosResponse(msg)
+		if err != nil {
+			cc.ws.Close()
+			cc.fail(fmt.Errorf("invalid response: %w", err))
+			return
+		}
+
+		cc.mu.Lock()
+		ch, ok := cc.pending[resp.requestID]
+		cc.mu.Unlock()
+		if !ok {
+			log.Debug.Printf("graph-intel-api: gremlin: discarding Cosmos DB response of unknown request %v", resp.requestID)
+			continue
+		}
+		ch <- resp
+	}
+}
+
+// fail marks the connection as closed because of err.
+func (cc *cosmosConn) fail(err error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.err = err
+	close(cc.done)
+}
+
+// submit sends a script to Cosmos DB and returns the results. If Cosmos DB
+// asks for authentication, the credentials of the connection are sent. If
+// Cosmos DB returns an error response, it returns a [*cosmosError].
+func (cc *cosmosConn) submit(id, script string, args map[string]any) ([]any, error) {
+	ch := make(chan cosmosResponse, 1)
+
+	cc.mu.Lock()
+	if cc.err != nil {
+		cc.mu.Unlock()
+		return nil, cc.err
+	}
+	cc.pending[id] = ch
+	cc.mu.Unlock()
+
+	defer func() {
+		cc.mu.Lock()
+		delete(cc.pending, id)
+		cc.mu.Unlock()
+	}()
+
+	args["gremlin"] = script
+	if err := cc.write(id, "eval", args); err != nil {
+		return nil, err
+	}
+
+	var (
+		results       []any
+		authenticated bool
+	)
+	for {
+		var resp cosmosResponse
+		select {
+		case resp = <-ch:
+		case <-cc.done:
+			// Prefer a response delivered before the
+			// connection was closed.
+			select {
+			case resp = <-ch:
+			default:
+				return nil, cc.err
+			}
+		}
+
+		switch resp.code {
+		case statusSuccess:
+			return append(results, resp.data...), nil
+		case statusNoContent:
+			return results, nil
+		case statusPartialContent:
+			results = append(results, resp.data...)
+		case statusAuthRequired:
+			if authenticated {
+				return nil, &cosmosError{code: statusUnauthorized, msg: "authentication failed"}
+			}
+			authenticated = true
+
+			sasl := base64.StdEncoding.EncodeToString([]byte("\x00" + cc.username + "\x00" + cc.password))
+			if err := cc.write(id, "authentication", map[string]any{"sasl": sasl}); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, &cosmosError{code: resp.code, msStatusCode: resp.msStatusCode, msg: resp.msg}
+		}
+	}
+}
+
+// write sends a request message to Cosmos DB.
+func (cc *cosmosConn) write(id, op string, args map[string]any) error {
+	req := map[string]any{
+		"requestId": map[string]any{"@type": "g:UUID", "@value": id},
+		"op":        op,
+		"processor": "",
+		"args":      args,
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("could not encode request: %w", err)
+	}
+
+	msg := make([]byte, 0, 1+len(cosmosMimeType)+len(body))
+	msg = append(msg, byte(len(cosmosMimeType)))
+	msg = append(msg, cosmosMimeType...)
+	msg = append(msg, body...)
+
+	cc.wmu.Lock()
+	defer cc.wmu.Unlock()
+
+	if err := cc.ws.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+		return fmt.Errorf("could not send request: %w", err)
+	}
+	return nil
+}
+
+// decodeCosmosResponse decodes a GraphSON response message.
+func decodeCosmosResponse(msg []byte) (cosmosResponse, error) {
+	var raw struct {
+		RequestID json.RawMessage `json:"requestId"`
+		Status    struct {
+			Code       int             `json:"code"`
+			Message    string          `json:"message"`
+			Attributes json.RawMessage `json:"attributes"`
+		} `json:"status"`
+		Result struct {
+			Data json.RawMessage `json:"data"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(msg, &raw); err != nil {
+		return cosmosResponse{}, err
+	}
+
+	resp := cosmosResponse{
+		code: raw.Status.Code,
+		msg:  raw.Status.Message,
+	}
+
+	id, err := decodeGraphSON(raw.RequestID)
+	if err != nil {
+		return cosmosResponse{}, fmt.Errorf("invalid request ID: %w", err)
+	}
+	s, ok := id.(string)
+	if !ok {
+		return cosmosResponse{}, errors.New("request ID is not a string")
+	}
+	resp.requestID = strings.ToLower(s)
+
+	attrs, err := decodeGraphSON(raw.Status.Attributes)
+	if err != nil {
+		return cosmosResponse{}, fmt.Errorf("invalid status attributes: %w", err)
+	}
+	if m, ok := attrs.(map[any]any); ok {
+		resp.msStatusCode, _ = toInt(m[cosmosStatusCodeAttr])
+	}
+
+	data, err := decodeGraphSON(raw.Result.Data)
+	if err != nil {
+		return cosmosResponse{}, fmt.Errorf("invalid result data: %w", err)
+	}
+	switch v := data.(type) {
+	case nil:
+	case []any:
+		resp.data = v
+	default:
+		resp.data = []any{v}
+	}
+
+	return resp, nil
+}
+
+// decodeGraphSON decodes a GraphSON v2 value. Cosmos DB returns most values
+// without type information, so JSON integers are decoded as Int64, JSON
+// objects with the shape of an element or a path are decoded as such and any
+// other JSON object is decoded as a map. The properties of the elements are
+// discarded, like Gremlin server does with bytecode traversals.
+func decodeGraphSON(b json.RawMessage) (any, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return graphSONValue(v)
+}
+
+// graphSONValue converts a GraphSON v2 value decoded by [json.Decoder] with
+// UseNumber enabled into the representation used by the GraphBinary
+// encoder.
+func graphSONValue(v any) (any, error) {
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v, nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	case []any:
+		l := make([]any, len(v))
+		for i, e := range v {
+			var err error
+			if l[i], err = graphSONValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return l, nil
+	case map[string]any:
+		if typ, ok := v["@type"].(string); ok {
+			return graphSONTypedValue(typ, v["@value"])
+		}
+		if v["type"] == "vertex" {
+			return graphSONVertex(v)
+		}
+		if v["type"] == "edge" {
+			return graphSONEdge(v)
+		}
+		if _, ok := v["objects"]; ok && len(v) == 2 {
+			if _, ok := v["labels"]; ok {
+				return graphSONPath(v)
+			}
+		}
+
+		m := make(map[any]any, len(v))
+		for k, e := range v {
+			var err error
+			if m[k], err = graphSONValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unexpected value %T", v)
+}
+
+// graphSONTypedValue converts a GraphSON v2 value with type information.
+func graphSONTypedValue(typ string, v any) (any, error) {
+	switch typ {
+	case "g:Int32":
+		n, err := graphSONInt(v)
+		if err != nil {
+			return nil, err
+		}
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return nil, fmt.Errorf("%v out of range: %v", typ, n)
+		}
+		return int32(n), nil
+	case "g:Int64":
+		return graphSONInt(v)
+	case "g:Date", "g:Timestamp":
+		n, err := graphSONInt(v)
+		if err != nil {
+			return nil, err
+		}
+		return time.UnixMilli(n), nil
+	case "g:Float", "g:Double":
+		num, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a number", typ)
+		}
+		f, err := num.Float64()
+		if err != nil {
+			return nil, err
+		}
+		if typ == "g:Float" {
+			return float32(f), nil
+		}
+		return f, nil
+	case "g:UUID", "g:T", "g:Direction":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a string", typ)
+		}
+		return s, nil
+	case "g:List", "g:Set":
+		l, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a list", typ)
+		}
+		return graphSONValue(l)
+	case "g:Map":
+		l, ok := v.([]any)
+		if !ok || len(l)%2 != 0 {
+			return nil, fmt.Errorf("%v is not a list of key-value pairs", typ)
+		}
+		m := make(map[any]any, len(l)/2)
+		for i := 0; i < len(l); i += 2 {
+			k, err := graphSONValue(l[i])
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case []any, map[any]any, memPath:
+				return nil, fmt.Errorf("unsupported %v key %T", typ, k)
+			}
+			if m[k], err = graphSONValue(l[i+1]); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case "g:Vertex", "g:Edge", "g:Path", "g:Property", "g:VertexProperty":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%v is not an object", typ)
+		}
+		switch typ {
+		case "g:Vertex":
+			return graphSONVertex(obj)
+		case "g:Edge":
+			return graphSONEdge(obj)
+		case "g:Path":
+			return graphSONPath(obj)
+		}
+		return graphSONValue(obj["value"])
+	}
+	return nil, fmt.Errorf("unsupported type %v", typ)
+}
+
+// graphSONInt converts an integer GraphSON v2 value.
+func graphSONInt(v any) (int64, error) {
+	num, ok := v.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("%v is not a number", v)
+	}
+	return num.Int64()
+}
+
+// graphSONVertex converts a GraphSON v2 vertex.
+func graphSONVertex(obj map[string]any) (*memVertex, error) {
+	id, err := graphSONValue(obj["id"])
+	if err != nil {
+		return nil, err
+	}
+	label, _ := obj["label"].(string)
+	return &memVertex{id: id, label: label}, nil
+}
+
+// graphSONEdge converts a GraphSON v2 edge.
+func graphSONEdge(obj map[string]any) (*memEdge, error) {
+	id, err := graphSONValue(obj["id"])
+	if err != nil {
+		return nil, err
+	}
+	label, _ := obj["label"].(string)
+
+	out, err := graphSONVertex(map[string]any{"id": obj["outV"], "label": obj["outVLabel"]})
+	if err != nil {
+		return nil, err
+	}
+	in, err := graphSONVertex(map[string]any{"id": obj["inV"], "label": obj["inVLabel"]})
+	if err != nil {
+		return nil, err
+	}
+	return &memEdge{id: id, label: label, out: out, in: in}, nil
+}
+
+// graphSONPath converts a GraphSON v2 path.
+func graphSONPath(obj map[string]any) (memPath, error) {
+	v, err := graphSONValue(obj["objects"])
+	if err != nil {
+		return memPath{}, err
+	}
+	objects, ok := v.([]any)
+	if !ok {
+		return memPath{}, errors.New("path objects are not a list")
+	}
+
+	v, err = graphSONValue(obj["labels"])
+	if err != nil {
+		return memPath{}, err
+	}
+	ls, _ := v.([]any)
+
+	p := memPath{labels: make([][]string, len(objects)), objects: objects}
+	for i := range p.labels {
+		p.labels[i] = []string{}
+		if i >= len(ls) {
+			continue
+		}
+		labels, _ := ls[i].([]any)
+		for _, l := range labels {
+			if s, ok := l.(string); ok {
+				p.labels[i] = append(p.labels[i], s)
+			}
+		}
+	}
+	return p, nil
+}
+
+// graphSONLong returns n as a GraphSON v2 Int64 value.
+func graphSONLong(n int64) map[string]any {
+	return map[string]any{"@type": "g:Int64", "@value": n}
+}
+
+// formatUUID returns the canonical text representation of a UUID.
+func formatUUID(id [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
+
+// translateBytecode returns the Gremlin script equivalent to a bytecode
+// traversal, using the syntax of the Groovy translator of TinkerPop. The
+// traversal source must be "g". The only option accepted in the source
+// instructions is evaluationTimeout, which is sent as a request argument by
+// gremlin-go.
+func translateBytecode(bc *bytecode) (string, error) {
+	for _, inst := range bc.sources {
+		switch inst.name {
+		case "with":
+			if len(inst.args) == 0 || inst.args[0] != "evaluationTimeout" {
+				return "", fmt.Errorf("unsupported option %v", inst.args)
+			}
+		case "withStrategies":
+			for _, arg := range inst.args {
+				s, ok := arg.(strategy)
+				if !ok || !strings.HasSuffix(s.name, ".OptionsStrategy") {
+					return "", errors.New("unsupported traversal strategy")
+				}
+				for k := range s.config {
+					if k != "evaluationTimeout" {
+						return "", fmt.Errorf("unsupported option %v", k)
+					}
+				}
+			}
+		default:
+			return "", fmt.Errorf("unsupported source instruction %q", inst.name)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("g")
+	if err := writeSteps(&b, bc.steps); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// writeSteps writes the script of a list of steps.
+func writeSteps(b *strings.Builder, steps []instruction) error {
+	for _, inst := range steps {
+		b.WriteString("." + inst.name + "(")
+		if err := writeArgs(b, inst.args); err != nil {
+			return fmt.Errorf("step %v: %w", inst.name, err)
+		}
+		b.WriteString(")")
+	}
+	return nil
+}
+
+// writeArgs writes a comma-separated list of arguments.
+func writeArgs(b *strings.Builder, args []any) error {
+	for i, arg := range args {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		if err := writeArg(b, arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tokenClasses maps the GraphBinary enum types to the corresponding Gremlin
+// classes.
+var tokenClasses = map[byte]string{
+	gbBarrier:     "SackFunctions.Barrier",
+	gbCardinality: "VertexProperty.Cardinality",
+	gbColumn:      "Column",
+	gbDirection:   "Direction",
+	gbOperator:    "Operator",
+	gbOrder:       "Order",
+	gbPick:        "Pick",
+	gbPop:         "Pop",
+	gbScope:       "Scope",
+	gbT:           "T",
+}
+
+// textPredicates contains the operators of the TextP predicates.
+var textPredicates = map[string]bool{
+	"containing":      true,
+	"endingWith":      true,
+	"notContaining":   true,
+	"notEndingWith":   true,
+	"notStartingWith": true,
+	"startingWith":    true,
+	"regex":           true,
+	"notRegex":        true,
+}
+
+// writeArg writes a step argument.
+func writeArg(b *strings.Builder, v any) error {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case string:
+		writeString(b, v)
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case uint8:
+		fmt.Fprintf(b, "(byte)%d", v)
+	case int16:
+		fmt.Fprintf(b, "(short)%d", v)
+	case int32:
+		fmt.Fprintf(b, "%d", v)
+	case int64:
+		fmt.Fprintf(b, "%dL", v)
+	case float32:
+		writeFloat(b, float64(v), 32)
+	case float64:
+		writeFloat(b, v, 64)
+	case time.Time:
+		fmt.Fprintf(b, "new Date(%dL)", v.UnixMilli())
+	case [16]byte:
+		fmt.Fprintf(b, "UUID.fromString('%v')", formatUUID(v))
+	case token:
+		class, ok := tokenClasses[v.kind]
+		if !ok {
+			return fmt.Errorf("unsupported enum type %#x", v.kind)
+		}
+		b.WriteString(class + "." + v.name)
+	case predicate:
+		return writePredicate(b, v)
+	case *bytecode:
+		if len(v.sources) > 0 {
+			return errors.New("source instructions in anonymous traversal")
+		}
+		b.WriteString("__")
+		return writeSteps(b, v.steps)
+	case vertexRef:
+		return writeArg(b, v.id)
+	case []any:
+		b.WriteString("[")
+		if err := writeArgs(b, v); err != nil {
+			return err
+		}
+		b.WriteString("]")
+	case map[any]any:
+		return writeMap(b, v)
+	default:
+		return fmt.Errorf("unsupported argument type %T", v)
+	}
+	return nil
+}
+
+// writeString writes a single-quoted string literal.
+func writeString(b *strings.Builder, s string) {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	b.WriteString("'" + r.Replace(s) + "'")
+}
+
+// writeFloat writes a floating point literal with the provided bit size.
+func writeFloat(b *strings.Builder, f float64, bitSize int) {
+	class, suffix := "Double", "d"
+	if bitSize == 32 {
+		class, suffix = "Float", "f"
+	}
+
+	switch {
+	case math.IsNaN(f):
+		b.WriteString(class + ".NaN")
+	case math.IsInf(f, 1):
+		b.WriteString(class + ".POSITIVE_INFINITY")
+	case math.IsInf(f, -1):
+		b.WriteString(class + ".NEGATIVE_INFINITY")
+	default:
+		b.WriteString(strconv.FormatFloat(f, 'g', -1, bitSize) + suffix)
+	}
+}
+
+// writePredicate writes a P or TextP predicate.
+func writePredicate(b *strings.Builder, p predicate) error {
+	if p.op == "and" || p.op == "or" {
+		if len(p.args) != 2 {
+			return fmt.Errorf("invalid number of arguments of %v predicate", p.op)
+		}
+		if err := writeArg(b, p.args[0]); err != nil {
+			return err
+		}
+		b.WriteString("." + p.op + "(")
+		if err := writeArg(b, p.args[1]); err != nil {
+			return err
+		}
+		b.WriteString(")")
+		return nil
+	}
+
+	class := "P"
+	if textPredicates[p.op] {
+		class = "TextP"
+	}
+	b.WriteString(class + "." + p.op + "(")
+
+	args := p.args
+	if (p.op == "within" || p.op == "without") && !(len(args) == 1 && isList(args[0])) {
+		args = []any{args}
+	}
+	if err := writeArgs(b, args); err != nil {
+		return err
+	}
+	b.WriteString(")")
+	return nil
+}
+
+// writeMap writes a map literal. Entries are sorted by key, so the output
+// is deterministic.
+func writeMap(b *strings.Builder, m map[any]any) error {
+	if len(m) == 0 {
+		b.WriteString("[:]")
+		return nil
+	}
+
+	entries := make([]string, 0, len(m))
+	for k, v := range m {
+		var eb strings.Builder
+		eb.WriteString("(")
+		if err := writeArg(&eb, k); err != nil {
+			return err
+		}
+		eb.WriteString("):")
+		if err := writeArg(&eb, v); err != nil {
+			return err
+		}
+		entries = append(entries, eb.String())
+	}
+	sort.Strings(entries)
+
+	b.WriteString("[" + strings.Join(entries, ",") + "]")
+	return nil
+}
+
+// isList reports whether v is a list.
+func isList(v any) bool {
+	_, ok := v.([]any)
+	return ok
+}
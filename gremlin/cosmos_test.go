@@ -0,0 +1,619 @@
+package gremlin
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
+)
+
+// fakeCosmosResponse is a response returned by [fakeCosmos].
+type fakeCosmosResponse struct {
+	code  int
+	msg   string
+	attrs string
+	data  string
+}
+
+// fakeCosmosDropScript is the script that makes [fakeCosmos] close the
+// connection.
+const fakeCosmosDropScript = "g.V().constant('drop connection')"
+
+// fakeCosmosRequest is a request received by [fakeCosmos].
+type fakeCosmosRequest struct {
+	RequestID struct {
+		Type  string `json:"@type"`
+		Value string `json:"@value"`
+	} `json:"requestId"`
+	Op        string         `json:"op"`
+	Processor string         `json:"processor"`
+	Args      map[string]any `json:"args"`
+}
+
+// fakeCosmos is a Gremlin server that behaves like the Gremlin API of Azure
+// Cosmos DB. It only accepts GraphSON v2 requests with string scripts and
+// requires SASL PLAIN authentication. The responses are looked up by script
+// in responses. If the script is [fakeCosmosDropScript], the connection is
+// closed without response.
+type fakeCosmos struct {
+	username  string
+	password  string
+	responses map[string][]fakeCosmosResponse
+
+	mu      sync.Mutex
+	conns   int
+	scripts []string
+	args    []map[string]any
+}
+
+func (fc *fakeCosmos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var upgrader websocket.Upgrader
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	fc.mu.Lock()
+	fc.conns++
+	fc.mu.Unlock()
+
+	var (
+		authenticated bool
+		pending       fakeCosmosRequest
+	)
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if len(msg) == 0 || !bytes.HasPrefix(msg[1:], []byte(cosmosMimeType)) {
+			return
+		}
+		var req fakeCosmosRequest
+		if err := json.Unmarshal(msg[1+len(cosmosMimeType):], &req); err != nil {
+			return
+		}
+		if req.RequestID.Type != "g:UUID" {
+			return
+		}
+
+		id := req.RequestID.Value
+		switch {
+		case req.Op == "authentication":
+			want := base64.StdEncoding.EncodeToString([]byte("\x00" + fc.username + "\x00" + fc.password))
+			if req.Args["sasl"] != want {
+				fc.write(ws, id, fakeCosmosResponse{code: http.StatusUnauthorized, msg: "invalid key"})
+				continue
+			}
+			authenticated = true
+			req = pending
+		case req.Op != "eval":
+			fc.write(ws, id, fakeCosmosResponse{code: 499, msg: "unsupported operation"})
+			continue
+		case !authenticated:
+			pending = req
+			fc.write(ws, id, fakeCosmosResponse{code: http.StatusProxyAuthRequired})
+			continue
+		}
+
+		script, _ := req.Args["gremlin"].(string)
+		if script == fakeCosmosDropScript {
+			return
+		}
+
+		fc.mu.Lock()
+		fc.scripts = append(fc.scripts, script)
+		fc.args = append(fc.args, req.Args)
+		fc.mu.Unlock()
+
+		resps, ok := fc.responses[script]
+		if !ok {
+			resps = []fakeCosmosResponse{{code: http.StatusInternalServerError, msg: "unexpected script: " + script}}
+		}
+		for _, resp := range resps {
+			fc.write(ws, id, resp)
+		}
+	}
+}
+
+// write writes a GraphSON response.
+func (fc *fakeCosmos) write(ws *websocket.Conn, id string, resp fakeCosmosResponse) {
+	attrs, data := resp.attrs, resp.data
+	if attrs == "" {
+		attrs = fmt.Sprintf(`{"x-ms-status-code":%v,"x-ms-request-charge":1.5}`, resp.code)
+	}
+	if data == "" {
+		data = "null"
+	}
+	msg := fmt.Sprintf(`{"requestId":%q,"status":{"code":%v,"message":%q,"attributes":%v},"result":{"data":%v,"meta":{}}}`, id, resp.code, resp.msg, attrs, data)
+	ws.WriteMessage(websocket.TextMessage, []byte(msg)) //nolint:errcheck
+}
+
+// newFakeCosmos starts a [fakeCosmos] server and returns the configuration
+// used to connect to it.
+func newFakeCosmos(t *testing.T, responses map[string][]fakeCosmosResponse) (*fakeCosmos, Config) {
+	fc := &fakeCosmos{
+		username:  "/dbs/db/colls/graph",
+		password:  "key",
+		responses: responses,
+	}
+	ts := httptest.NewServer(fc)
+	t.Cleanup(ts.Close)
+
+	cfg := Config{
+		Endpoint: strings.Replace(ts.URL, "http://", "ws://", 1) + "/",
+		AuthMode: "cosmos_key",
+		Username: fc.username,
+		Password: fc.password,
+	}
+	return fc, cfg
+}
+
+func TestNewConnection_MissingCosmosCredentials(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{
+			name: "missing username",
+			cfg:  Config{AuthMode: "cosmos_key", Password: "key"},
+		},
+		{
+			name: "missing key",
+			cfg:  Config{AuthMode: "cosmos_key", Username: "/dbs/db/colls/graph"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewConnection(tt.cfg); err == nil {
+				t.Error("unexpected nil error")
+			}
+		})
+	}
+}
+
+func TestConnectionQuery_Cosmos(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      QueryFunc
+		wantScript string
+		responses  []fakeCosmosResponse
+		want       []any
+	}{
+		{
+			name: "text predicate",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.
+					V().
+					HasLabel("ec2:instance", "ec2:network-interface").
+					Has("arn", gremlingo.TextP.EndingWith("/i-0'1")).
+					Id().
+					ToList()
+			},
+			wantScript: `g.V().hasLabel('ec2:instance','ec2:network-interface').has('arn',TextP.endingWith('/i-0\'1')).id()`,
+			responses: []fakeCosmosResponse{
+				{code: http.StatusOK, data: `["i0"]`},
+			},
+			want: []any{"i0"},
+		},
+		{
+			name: "keyset page",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.
+					V("s1").
+					Out("includes").
+					Has(gremlingo.T.Id, gremlingo.P.Gt("i0")).
+					Order().By(gremlingo.T__.Id(), gremlingo.Order.Asc).
+					Limit(3).
+					Id().
+					ToList()
+			},
+			wantScript: "g.V('s1').out('includes').has(T.id,P.gt('i0')).order().by(__.id(),Order.asc).limit(3L).id()",
+			responses: []fakeCosmosResponse{
+				{code: http.StatusOK, data: `["ir0","ni0","r0"]`},
+			},
+			want: []any{"ir0", "ni0", "r0"},
+		},
+		{
+			name: "repeat and path",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.
+					V("ni0").
+					Repeat(gremlingo.T__.OutE().InV().SimplePath()).
+					Times(int32(2)).
+					Emit().
+					HasLabel(gremlingo.P.Within("ip_range", "ec2:security-group")).
+					Path().
+					ToList()
+			},
+			wantScript: "g.V('ni0').repeat(__.outE().inV().simplePath()).times(2).emit().hasLabel(P.within(['ip_range','ec2:security-group'])).path()",
+			responses: []fakeCosmosResponse{
+				{
+					code: http.StatusOK,
+					data: `[{
+						"labels": [[], [], []],
+						"objects": [
+							{"id": "ni0", "label": "ec2:network-interface", "type": "vertex", "properties": {"arn": [{"id": "p0", "value": "arn0"}]}},
+							{"id": "e0", "label": "resource_link", "type": "edge", "inVLabel": "ec2:security-group", "outVLabel": "ec2:network-interface", "inV": "sg0", "outV": "ni0"},
+							{"id": "sg0", "label": "ec2:security-group", "type": "vertex", "properties": {}}
+						]
+					}]`,
+				},
+			},
+			want: []any{
+				[]any{"v[ni0]", "e[e0]", "v[sg0]"},
+			},
+		},
+		{
+			name: "projection",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.
+					V("s1").
+					Project("id", "resources", "score").
+					By(gremlingo.T__.Id()).
+					By(gremlingo.T__.Out("includes").Count()).
+					By(gremlingo.T__.Constant(0.5)).
+					ToList()
+			},
+			wantScript: "g.V('s1').project('id','resources','score').by(__.id()).by(__.out('includes').count()).by(__.constant(0.5d))",
+			responses: []fakeCosmosResponse{
+				{code: http.StatusOK, data: `[{"id": "s1", "resources": 10, "score": 0.5}]`},
+			},
+			want: []any{
+				map[any]any{"id": "s1", "resources": int64(10), "score": 0.5},
+			},
+		},
+		{
+			name: "typed values",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V("s1").Values("timestamp", "version").ToList()
+			},
+			wantScript: "g.V('s1').values('timestamp','version')",
+			responses: []fakeCosmosResponse{
+				{code: http.StatusOK, data: `[{"@type": "g:Int64", "@value": 2000}, {"@type": "g:Int32", "@value": 1}]`},
+			},
+			want: []any{int64(2000), int32(1)},
+		},
+		{
+			name: "partial content",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V().Id().ToList()
+			},
+			wantScript: "g.V().id()",
+			responses: []fakeCosmosResponse{
+				{code: statusPartialContent, data: `["a","b"]`},
+				{code: http.StatusOK, data: `["c"]`},
+			},
+			want: []any{"a", "b", "c"},
+		},
+		{
+			name: "no content",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V("unknown").ToList()
+			},
+			wantScript: "g.V('unknown')",
+			responses: []fakeCosmosResponse{
+				{code: http.StatusNoContent},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc, cfg := newFakeCosmos(t, map[string][]fakeCosmosResponse{
+				tt.wantScript: tt.responses,
+			})
+
+			conn, err := NewConnection(cfg)
+			if err != nil {
+				t.Fatalf("error creating connection: %v", err)
+			}
+			defer conn.Close()
+
+			results, err := conn.QueryOnce(context.Background(), "test", tt.query)
+			if err != nil {
+				t.Fatalf("query error: %v", err)
+			}
+
+			if diff := cmp.Diff([]string{tt.wantScript}, fc.scripts); diff != "" {
+				t.Errorf("scripts mismatch (-want +got):\n%v", diff)
+			}
+
+			var got []any
+			for _, r := range results {
+				got = append(got, normalizeResult(r.GetInterface()))
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("results mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestConnectionQuery_CosmosEvaluationTimeout(t *testing.T) {
+	fc, cfg := newFakeCosmos(t, map[string][]fakeCosmosResponse{
+		"g.V().count()": {{code: http.StatusOK, data: `[3]`}},
+	})
+
+	conn, err := NewConnection(cfg)
+	if err != nil {
+		t.Fatalf("error creating connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.QueryOnce(context.Background(), "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return g.With("evaluationTimeout", 1000).V().Count().ToList()
+	}); err != nil {
+		t.Fatalf("query error: %v", err)
+	}
+
+	want := []map[string]any{
+		{
+			"gremlin":           "g.V().count()",
+			"evaluationTimeout": map[string]any{"@type": "g:Int64", "@value": float64(1000)},
+		},
+	}
+	if diff := cmp.Diff(want, fc.args); diff != "" {
+		t.Errorf("args mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestConnectionQuery_CosmosErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		query    QueryFunc
+		wantErr  error
+		wantMsg  string
+	}{
+		{
+			name: "timeout",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V().Constant("timeout").ToList()
+			},
+			wantErr: ErrTimeout,
+		},
+		{
+			name: "server error",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V().Constant("throttled").ToList()
+			},
+			wantMsg: "Request rate is large",
+		},
+		{
+			name:     "invalid key",
+			password: "invalid",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V().ToList()
+			},
+			wantMsg: "invalid key",
+		},
+		{
+			name: "untranslatable bytecode",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.WithSideEffect("a", 1).V().ToList()
+			},
+			wantMsg: "unsupported source instruction",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc, cfg := newFakeCosmos(t, map[string][]fakeCosmosResponse{
+				"g.V().constant('timeout')": {
+					{code: http.StatusInternalServerError, msg: "GraphTimeoutException", attrs: `{"x-ms-status-code": 408}`},
+				},
+				"g.V().constant('throttled')": {
+					{code: http.StatusInternalServerError, msg: "Request rate is large", attrs: `{"x-ms-status-code": 429}`},
+				},
+				"g.V().count()": {
+					{code: http.StatusOK, data: `[3]`},
+				},
+			})
+			if tt.password != "" {
+				cfg.Password = tt.password
+			}
+			cfg.PoolSize = 1
+
+			conn, err := NewConnection(cfg)
+			if err != nil {
+				t.Fatalf("error creating connection: %v", err)
+			}
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, err = conn.QueryOnce(ctx, "test", tt.query)
+			if err == nil {
+				t.Fatal("unexpected nil error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("unexpected error: got=%v want=%v", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("unexpected error message: got=%q want=%q", err, tt.wantMsg)
+			}
+
+			if tt.password != "" {
+				return
+			}
+
+			// The connection with Cosmos DB is reused after
+			// server errors.
+			if _, err := conn.QueryOnce(ctx, "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V().Count().ToList()
+			}); err != nil {
+				t.Fatalf("query error: %v", err)
+			}
+
+			fc.mu.Lock()
+			conns := fc.conns
+			fc.mu.Unlock()
+			if conns != 1 {
+				t.Errorf("unexpected number of connections: got=%v want=1", conns)
+			}
+		})
+	}
+}
+
+func TestCosmosConnSubmit_DroppedConnection(t *testing.T) {
+	_, cfg := newFakeCosmos(t, nil)
+
+	cc, err := dialCosmos(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("error connecting: %v", err)
+	}
+	defer cc.close()
+
+	_, err = cc.submit("00000000-0000-0000-0000-000000000001", fakeCosmosDropScript, map[string]any{})
+	var cerr *cosmosError
+	if err == nil || errors.As(err, &cerr) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case <-cc.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed")
+	}
+
+	if _, err := cc.submit("00000000-0000-0000-0000-000000000002", "g.V()", map[string]any{}); err == nil {
+		t.Error("unexpected nil error after the connection was closed")
+	}
+}
+
+func TestTranslateBytecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		bc      *bytecode
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "literals",
+			bc: &bytecode{
+				steps: []instruction{
+					{name: "inject", args: []any{nil, true, uint8(1), int16(2), int32(3), int64(4), float32(1.5), 2.5, "a\\b\n"}},
+				},
+			},
+			want: `g.inject(null,true,(byte)1,(short)2,3,4L,1.5f,2.5d,'a\\b\n')`,
+		},
+		{
+			name: "special floats",
+			bc: &bytecode{
+				steps: []instruction{
+					{name: "inject", args: []any{math.NaN(), math.Inf(1), float32(math.Inf(-1))}},
+				},
+			},
+			want: "g.inject(Double.NaN,Double.POSITIVE_INFINITY,Float.NEGATIVE_INFINITY)",
+		},
+		{
+			name: "collections",
+			bc: &bytecode{
+				steps: []instruction{
+					{name: "inject", args: []any{[]any{"a", int32(1)}, map[any]any{"b": int32(2), "a": int32(1)}, map[any]any{}}},
+				},
+			},
+			want: "g.inject(['a',1],[('a'):1,('b'):2],[:])",
+		},
+		{
+			name: "tokens",
+			bc: &bytecode{
+				steps: []instruction{
+					{name: "order", args: []any{token{kind: gbScope, name: "local"}}},
+					{name: "by", args: []any{token{kind: gbColumn, name: "values"}, token{kind: gbOrder, name: "desc"}}},
+				},
+			},
+			want: "g.order(Scope.local).by(Column.values,Order.desc)",
+		},
+		{
+			name: "compound predicate",
+			bc: &bytecode{
+				steps: []instruction{
+					{name: "has", args: []any{"timestamp", predicate{
+						op: "and",
+						args: []any{
+							predicate{op: "gte", args: []any{int64(1)}},
+							predicate{op: "notStartingWith", args: []any{"x"}},
+						},
+					}}},
+				},
+			},
+			want: "g.has('timestamp',P.gte(1L).and(TextP.notStartingWith('x')))",
+		},
+		{
+			name: "options",
+			bc: &bytecode{
+				sources: []instruction{
+					{name: "withStrategies", args: []any{strategy{
+						name:   "org.apache.tinkerpop.gremlin.process.traversal.strategy.decoration.OptionsStrategy",
+						config: map[any]any{"evaluationTimeout": int64(1000)},
+					}}},
+					{name: "with", args: []any{"evaluationTimeout", int64(1000)}},
+				},
+				steps: []instruction{
+					{name: "V", args: []any{vertexRef{id: "v0"}}},
+				},
+			},
+			want: "g.V('v0')",
+		},
+		{
+			name: "unsupported strategy",
+			bc: &bytecode{
+				sources: []instruction{
+					{name: "withStrategies", args: []any{strategy{name: "org.apache.tinkerpop.gremlin.process.traversal.strategy.decoration.SubgraphStrategy"}}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported option",
+			bc: &bytecode{
+				sources: []instruction{
+					{name: "with", args: []any{"batchSize", int64(10)}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported argument",
+			bc: &bytecode{
+				steps: []instruction{
+					{name: "inject", args: []any{struct{}{}}},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := translateBytecode(tt.bc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: wantErr=%v got=%v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("unexpected script: got=%q want=%q", got, tt.want)
+			}
+		})
+	}
+}
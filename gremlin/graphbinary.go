@@ -0,0 +1,588 @@
+package gremlin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// GraphBinary type codes supported by the in-memory backend. See
+// https://tinkerpop.apache.org/docs/3.5.4/dev/io/#graphbinary.
+const (
+	gbInt               byte = 0x01
+	gbLong              byte = 0x02
+	gbString            byte = 0x03
+	gbDate              byte = 0x04
+	gbTimestamp         byte = 0x05
+	gbClass             byte = 0x06
+	gbDouble            byte = 0x07
+	gbFloat             byte = 0x08
+	gbList              byte = 0x09
+	gbMap               byte = 0x0a
+	gbSet               byte = 0x0b
+	gbUUID              byte = 0x0c
+	gbEdge              byte = 0x0d
+	gbPath              byte = 0x0e
+	gbVertex            byte = 0x11
+	gbBarrier           byte = 0x13
+	gbBinding           byte = 0x14
+	gbBytecode          byte = 0x15
+	gbCardinality       byte = 0x16
+	gbColumn            byte = 0x17
+	gbDirection         byte = 0x18
+	gbOperator          byte = 0x19
+	gbOrder             byte = 0x1a
+	gbPick              byte = 0x1b
+	gbPop               byte = 0x1c
+	gbP                 byte = 0x1e
+	gbScope             byte = 0x1f
+	gbT                 byte = 0x20
+	gbByte              byte = 0x24
+	gbShort             byte = 0x26
+	gbBoolean           byte = 0x27
+	gbTextP             byte = 0x28
+	gbTraversalStrategy byte = 0x29
+	gbNull              byte = 0xfe
+)
+
+// graphBinaryVersion is the version of the GraphBinary messages.
+const graphBinaryVersion byte = 0x81
+
+// Value flags of the fully qualified GraphBinary values.
+const (
+	gbValueFlagNone byte = 0x00
+	gbValueFlagNull byte = 0x01
+)
+
+// instruction is a step or source instruction of a Gremlin bytecode.
+type instruction struct {
+	name string
+	args []any
+}
+
+// bytecode is a decoded Gremlin bytecode.
+type bytecode struct {
+	steps   []instruction
+	sources []instruction
+}
+
+// token is a decoded Gremlin enum value, like T.id or Order.desc.
+type token struct {
+	kind byte
+	name string
+}
+
+// predicate is a decoded Gremlin P or TextP predicate.
+type predicate struct {
+	op   string
+	args []any
+}
+
+// strategy is a decoded Gremlin traversal strategy.
+type strategy struct {
+	name   string
+	config map[any]any
+}
+
+// vertexRef is a decoded Gremlin vertex. Only its ID is meaningful.
+type vertexRef struct {
+	id any
+}
+
+// gremlinRequest is a decoded Gremlin request message.
+type gremlinRequest struct {
+	id        [16]byte
+	op        string
+	processor string
+	args      map[string]any
+}
+
+// decodeRequest decodes a Gremlin request message serialized with
+// GraphBinary, including its MIME type header.
+func decodeRequest(msg []byte) (gremlinRequest, error) {
+	r := &gbReader{b: msg}
+
+	n, err := r.byte()
+	if err != nil {
+		return gremlinRequest{}, err
+	}
+	if _, err := r.read(int(n)); err != nil {
+		return gremlinRequest{}, err
+	}
+
+	version, err := r.byte()
+	if err != nil {
+		return gremlinRequest{}, err
+	}
+	if version != graphBinaryVersion {
+		return gremlinRequest{}, fmt.Errorf("unsupported version %#x", version)
+	}
+
+	var req gremlinRequest
+	id, err := r.read(16)
+	if err != nil {
+		return gremlinRequest{}, err
+	}
+	copy(req.id[:], id)
+
+	if req.op, err = r.string(); err != nil {
+		return gremlinRequest{}, err
+	}
+	if req.processor, err = r.string(); err != nil {
+		return gremlinRequest{}, err
+	}
+
+	nargs, err := r.int32()
+	if err != nil {
+		return gremlinRequest{}, err
+	}
+	req.args = make(map[string]any, nargs)
+	for i := int32(0); i < nargs; i++ {
+		k, err := r.value()
+		if err != nil {
+			return gremlinRequest{}, err
+		}
+		sk, ok := k.(string)
+		if !ok {
+			return gremlinRequest{}, errors.New("argument name is not a string")
+		}
+		if req.args[sk], err = r.value(); err != nil {
+			return gremlinRequest{}, err
+		}
+	}
+
+	return req, nil
+}
+
+// encodeResponse encodes a Gremlin response message using GraphBinary.
+func encodeResponse(id [16]byte, code int, msg string, data any) ([]byte, error) {
+	w := &gbWriter{}
+
+	w.WriteByte(graphBinaryVersion)
+	w.WriteByte(gbValueFlagNone)
+	w.Write(id[:])
+	w.int32(int32(code))
+
+	if msg == "" {
+		w.WriteByte(gbValueFlagNull)
+	} else {
+		w.WriteByte(gbValueFlagNone)
+		w.string(msg)
+	}
+
+	// Status attributes and result meta.
+	w.int32(0)
+	w.int32(0)
+
+	if err := w.value(data); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// gbReader reads GraphBinary values.
+type gbReader struct {
+	b []byte
+	i int
+}
+
+// read returns the next n bytes.
+func (r *gbReader) read(n int) ([]byte, error) {
+	if n < 0 || r.i+n > len(r.b) {
+		return nil, errors.New("unexpected end of message")
+	}
+	b := r.b[r.i : r.i+n]
+	r.i += n
+	return b, nil
+}
+
+// byte returns the next byte.
+func (r *gbReader) byte() (byte, error) {
+	b, err := r.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// int32 returns the next 4 bytes as a big-endian integer.
+func (r *gbReader) int32() (int32, error) {
+	b, err := r.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+// int64 returns the next 8 bytes as a big-endian integer.
+func (r *gbReader) int64() (int64, error) {
+	b, err := r.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+// string returns the next unqualified string.
+func (r *gbReader) string() (string, error) {
+	n, err := r.int32()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.read(int(n))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// value returns the next fully qualified value.
+func (r *gbReader) value() (any, error) {
+	typ, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	flag, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	if typ == gbNull || flag == gbValueFlagNull {
+		return nil, nil
+	}
+	return r.payload(typ)
+}
+
+// payload returns the next value of the provided type.
+func (r *gbReader) payload(typ byte) (any, error) {
+	switch typ {
+	case gbInt:
+		return r.int32()
+	case gbLong:
+		return r.int64()
+	case gbString, gbClass:
+		return r.string()
+	case gbDate, gbTimestamp:
+		ms, err := r.int64()
+		if err != nil {
+			return nil, err
+		}
+		return time.UnixMilli(ms), nil
+	case gbDouble:
+		v, err := r.int64()
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(uint64(v)), nil
+	case gbFloat:
+		v, err := r.int32()
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(uint32(v)), nil
+	case gbByte:
+		return r.byte()
+	case gbShort:
+		b, err := r.read(2)
+		if err != nil {
+			return nil, err
+		}
+		return int16(binary.BigEndian.Uint16(b)), nil
+	case gbBoolean:
+		b, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		return b != 0, nil
+	case gbUUID:
+		b, err := r.read(16)
+		if err != nil {
+			return nil, err
+		}
+		var id [16]byte
+		copy(id[:], b)
+		return id, nil
+	case gbList, gbSet:
+		return r.list()
+	case gbMap:
+		return r.mapValue()
+	case gbBytecode:
+		return r.bytecode()
+	case gbBarrier, gbCardinality, gbColumn, gbDirection, gbOperator, gbOrder, gbPick, gbPop, gbScope, gbT:
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := v.(string)
+		if !ok {
+			return nil, errors.New("enum value is not a string")
+		}
+		return token{kind: typ, name: name}, nil
+	case gbP, gbTextP:
+		op, err := r.string()
+		if err != nil {
+			return nil, err
+		}
+		args, err := r.list()
+		if err != nil {
+			return nil, err
+		}
+		return predicate{op: op, args: args}, nil
+	case gbBinding:
+		if _, err := r.string(); err != nil {
+			return nil, err
+		}
+		return r.value()
+	case gbTraversalStrategy:
+		name, err := r.string()
+		if err != nil {
+			return nil, err
+		}
+		config, err := r.mapValue()
+		if err != nil {
+			return nil, err
+		}
+		return strategy{name: name, config: config}, nil
+	case gbVertex:
+		id, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.string(); err != nil {
+			return nil, err
+		}
+		// Properties are always null.
+		if _, err := r.value(); err != nil {
+			return nil, err
+		}
+		return vertexRef{id: id}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %#x", typ)
+	}
+}
+
+// list returns the next list without type information.
+func (r *gbReader) list() ([]any, error) {
+	n, err := r.int32()
+	if err != nil {
+		return nil, err
+	}
+	l := make([]any, 0, n)
+	for i := int32(0); i < n; i++ {
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, v)
+	}
+	return l, nil
+}
+
+// mapValue returns the next map without type information.
+func (r *gbReader) mapValue() (map[any]any, error) {
+	n, err := r.int32()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[any]any, n)
+	for i := int32(0); i < n; i++ {
+		k, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+// bytecode returns the next bytecode without type information.
+func (r *gbReader) bytecode() (*bytecode, error) {
+	steps, err := r.instructions()
+	if err != nil {
+		return nil, err
+	}
+	sources, err := r.instructions()
+	if err != nil {
+		return nil, err
+	}
+	return &bytecode{steps: steps, sources: sources}, nil
+}
+
+// instructions returns the next list of bytecode instructions.
+func (r *gbReader) instructions() ([]instruction, error) {
+	n, err := r.int32()
+	if err != nil {
+		return nil, err
+	}
+	insts := make([]instruction, 0, n)
+	for i := int32(0); i < n; i++ {
+		name, err := r.string()
+		if err != nil {
+			return nil, err
+		}
+		args, err := r.list()
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, instruction{name: name, args: args})
+	}
+	return insts, nil
+}
+
+// gbWriter writes GraphBinary values.
+type gbWriter struct {
+	bytes.Buffer
+}
+
+// int32 writes v as a big-endian integer.
+func (w *gbWriter) int32(v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	w.Write(b[:])
+}
+
+// int64 writes v as a big-endian integer.
+func (w *gbWriter) int64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	w.Write(b[:])
+}
+
+// string writes s as an unqualified string.
+func (w *gbWriter) string(s string) {
+	w.int32(int32(len(s)))
+	w.WriteString(s)
+}
+
+// header writes the type code and value flag of a fully qualified value.
+func (w *gbWriter) header(typ byte) {
+	w.WriteByte(typ)
+	w.WriteByte(gbValueFlagNone)
+}
+
+// value writes v as a fully qualified value.
+func (w *gbWriter) value(v any) error {
+	switch v := v.(type) {
+	case nil:
+		w.WriteByte(gbNull)
+		w.WriteByte(gbValueFlagNull)
+	case string:
+		w.header(gbString)
+		w.string(v)
+	case bool:
+		w.header(gbBoolean)
+		if v {
+			w.WriteByte(1)
+		} else {
+			w.WriteByte(0)
+		}
+	case uint8:
+		w.header(gbByte)
+		w.WriteByte(v)
+	case int16:
+		w.header(gbShort)
+		var b [2]byte
+		binary.BigEndian.PutUint16(b[:], uint16(v))
+		w.Write(b[:])
+	case int32:
+		w.header(gbInt)
+		w.int32(v)
+	case int:
+		w.header(gbLong)
+		w.int64(int64(v))
+	case int64:
+		w.header(gbLong)
+		w.int64(v)
+	case float32:
+		w.header(gbFloat)
+		w.int32(int32(math.Float32bits(v)))
+	case float64:
+		w.header(gbDouble)
+		w.int64(int64(math.Float64bits(v)))
+	case time.Time:
+		w.header(gbDate)
+		w.int64(v.UnixMilli())
+	case [16]byte:
+		w.header(gbUUID)
+		w.Write(v[:])
+	case token:
+		w.header(v.kind)
+		return w.value(v.name)
+	case []any:
+		w.header(gbList)
+		return w.list(v)
+	case map[any]any:
+		w.header(gbMap)
+		w.int32(int32(len(v)))
+		for k, e := range v {
+			if err := w.value(k); err != nil {
+				return err
+			}
+			if err := w.value(e); err != nil {
+				return err
+			}
+		}
+	case *memVertex:
+		w.header(gbVertex)
+		if err := w.value(v.id); err != nil {
+			return err
+		}
+		w.string(v.label)
+		// Properties are not returned.
+		return w.value(nil)
+	case *memEdge:
+		w.header(gbEdge)
+		if err := w.value(v.id); err != nil {
+			return err
+		}
+		w.string(v.label)
+		if err := w.value(v.in.id); err != nil {
+			return err
+		}
+		w.string(v.in.label)
+		if err := w.value(v.out.id); err != nil {
+			return err
+		}
+		w.string(v.out.label)
+		// Parent and properties are not returned.
+		if err := w.value(nil); err != nil {
+			return err
+		}
+		return w.value(nil)
+	case memPath:
+		w.header(gbPath)
+		w.header(gbList)
+		w.int32(int32(len(v.labels)))
+		for _, labels := range v.labels {
+			w.header(gbSet)
+			w.int32(int32(len(labels)))
+			for _, l := range labels {
+				if err := w.value(l); err != nil {
+					return err
+				}
+			}
+		}
+		w.header(gbList)
+		return w.list(v.objects)
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	return nil
+}
+
+// list writes l without type information.
+func (w *gbWriter) list(l []any) error {
+	w.int32(int32(len(l)))
+	for _, e := range l {
+		if err := w.value(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -6,51 +6,162 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
-	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/adevinta/graph-intel-api/log"
 
 	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
-	"github.com/aws/aws-sdk-go-v2/aws/signer/v4"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
-// emptyStringSHA256 is the hex encoded sha256 value of an empty string.
-const emptyStringSHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+// DefaultPoolSize is the maximum number of open connections when
+// [Config.PoolSize] is not set.
+const DefaultPoolSize = 10
 
-// ErrTimeout is returned when a Gremlin query times out.
-var ErrTimeout = errors.New("timeout error")
+// healthCheckTimeout is the maximum time spent checking the health of an idle
+// connection.
+const healthCheckTimeout = 5 * time.Second
+
+var (
+	// ErrTimeout is returned when a Gremlin query times out.
+	ErrTimeout = errors.New("timeout error")
+
+	// ErrClosed is returned when a query is executed using a closed
+	// [Connection].
+	ErrClosed = errors.New("connection closed")
+)
+
+// Prometheus metrics exposed by the package.
+var (
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "graph_intel_api",
+		Subsystem: "gremlin",
+		Name:      "query_duration_seconds",
+		Help:      "Duration of Gremlin queries, including retries.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"kind", "result"})
+
+	queryRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "graph_intel_api",
+		Subsystem: "gremlin",
+		Name:      "query_retries_total",
+		Help:      "Number of Gremlin query retries.",
+	}, []string{"kind"})
+
+	queryTimeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "graph_intel_api",
+		Subsystem: "gremlin",
+		Name:      "query_timeouts_total",
+		Help:      "Number of Gremlin queries that timed out.",
+	}, []string{"kind"})
+
+	queriesAbandoned = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "graph_intel_api",
+		Subsystem: "gremlin",
+		Name:      "queries_abandoned_total",
+		Help:      "Number of Gremlin queries abandoned because their context was done.",
+	}, []string{"kind"})
+
+	connectionsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "graph_intel_api",
+		Subsystem: "gremlin",
+		Name:      "connections_created_total",
+		Help:      "Number of connections created with the Gremlin server.",
+	})
+)
 
 // Config contains the configuration parameters needed to interact with a
 // Gremlin server.
 type Config struct {
-	// Endpoint is the Gremlin Endpoint.
+	// Endpoint is the Gremlin Endpoint. If AuthMode is "memory", it is
+	// the path of the GraphSON file the graph is loaded from. If empty,
+	// the in-memory graph starts empty.
 	Endpoint string
 
 	// AuthMode is the authentication mode. Valid values: "plain",
-	// "neptune_iam".
+	// "basic", "cosmos_key", "neptune_iam", "memory". The "basic" mode
+	// authenticates using Username and Password with SASL PLAIN, as
+	// expected by Gremlin Server and JanusGraph. The "cosmos_key" mode
+	// connects to the Gremlin API of Azure Cosmos DB, which only
+	// accepts string scripts, so the traversals are translated into
+	// scripts. Username is the resource path of the graph, like
+	// "/dbs/<database>/colls/<graph>", and Password is the key of the
+	// database account. The "memory" mode runs the queries against an
+	// in-process graph instead of a Gremlin server and ignores the TLS
+	// settings.
 	AuthMode string
 
+	// Username is the username used to authenticate in case of using
+	// basic or Cosmos DB key auth.
+	Username string
+
+	// Password is the password used to authenticate in case of using
+	// basic or Cosmos DB key auth.
+	Password string
+
 	// AWSRegion is the AWS region in case of using Neptune.
 	AWSRegion string
 
+	// AWSRoleARN is the ARN of the IAM role assumed to access Neptune.
+	// If empty, the default AWS credentials are used.
+	AWSRoleARN string
+
+	// AWSExternalID is the external ID used to assume AWSRoleARN.
+	AWSExternalID string
+
+	// TLSCAFile is the path of a PEM encoded CA bundle used to verify
+	// the certificate of the Gremlin server. If empty, the system CA
+	// bundle is used.
+	TLSCAFile string
+
+	// TLSCertFile is the path of a PEM encoded client certificate
+	// presented to the Gremlin server. It requires TLSKeyFile.
+	TLSCertFile string
+
+	// TLSKeyFile is the path of the PEM encoded private key of
+	// TLSCertFile.
+	TLSKeyFile string
+
+	// TLSServerName is the name used to verify the certificate of the
+	// Gremlin server. If empty, the host of the endpoint is used.
+	TLSServerName string
+
+	// TLSInsecureSkipVerify disables the verification of the certificate
+	// of the Gremlin server.
+	TLSInsecureSkipVerify bool
+
 	// RetryLimit is the number of retries before returning error.
 	RetryLimit int
 
 	// RetryDuration is the time to wait between retries.
 	RetryDuration time.Duration
+
+	// PoolSize is the maximum number of open connections with the
+	// Gremlin server. If zero, [DefaultPoolSize] is used.
+	PoolSize int
+
+	// PoolIdleTimeout is the time after which an idle connection is
+	// closed. If zero, idle connections are not closed.
+	PoolIdleTimeout time.Duration
+
+	// PoolHealthCheckInterval is the time between health checks of
+	// idle connections. If zero, idle connections are not checked.
+	PoolHealthCheckInterval time.Duration
 }
 
 // connHandler is called to create a connection with a Gremlin server.
-type connHandler func(cfg Config) (*gremlingo.DriverRemoteConnection, error)
+type connHandler func(ctx context.Context, cfg Config) (*gremlingo.DriverRemoteConnection, error)
 
 // A Connection handles the connection with the Gremlin server. This includes
-// authentication, reconnections and retries.
+// authentication, reconnections and retries. Connections with the Gremlin
+// server are pooled and reused across queries. A Connection is safe for
+// concurrent use and must be closed when it is no longer needed.
 type Connection struct {
-	cfg Config
-	h   connHandler
+	cfg  Config
+	pool *pool
 }
 
 // NewConnection creates a [Connection] with the provided configuration.
@@ -60,112 +171,553 @@ func NewConnection(cfg Config) (Connection, error) {
 	switch cfg.AuthMode {
 	case "plain":
 		connHandler = connectPlain
+	case "basic":
+		if cfg.Username == "" || cfg.Password == "" {
+			return Connection{}, errors.New("missing basic auth credentials")
+		}
+		connHandler = connectBasic
+	case "cosmos_key":
+		if cfg.Username == "" || cfg.Password == "" {
+			return Connection{}, errors.New("missing Cosmos DB credentials")
+		}
+		if _, err := newTLSConfig(cfg); err != nil {
+			return Connection{}, fmt.Errorf("invalid TLS config: %w", err)
+		}
+		proxy, err := newCosmosProxy()
+		if err != nil {
+			return Connection{}, fmt.Errorf("could not start Cosmos DB proxy: %w", err)
+		}
+		conn := newConnection(cfg, proxy.connect)
+		conn.pool.onClose = proxy.close
+		return conn, nil
 	case "neptune_iam":
-		connHandler = connectNeptuneIam
+		connHandler = newNeptuneAuth(cfg).connect
+	case "memory":
+		srv, err := acquireMemoryServer(cfg.Endpoint)
+		if err != nil {
+			return Connection{}, fmt.Errorf("could not start in-memory Gremlin server: %w", err)
+		}
+		conn := newConnection(cfg, srv.connect)
+		conn.pool.onClose = srv.release
+		return conn, nil
 	default:
 		return Connection{}, errors.New("invalid auth mode")
 	}
 
+	// The TLS configuration is loaded every time a connection is
+	// created, so renewed certificates are picked up. Load it now to
+	// report configuration errors early.
+	if _, err := newTLSConfig(cfg); err != nil {
+		return Connection{}, fmt.Errorf("invalid TLS config: %w", err)
+	}
+
+	return newConnection(cfg, connHandler), nil
+}
+
+// newConnection creates a [Connection] that uses h to connect to the Gremlin
+// server.
+func newConnection(cfg Config, h connHandler) Connection {
 	conn := Connection{
-		cfg: cfg,
-		h:   connHandler,
+		cfg:  cfg,
+		pool: newPool(cfg, h),
 	}
-	return conn, nil
+	return conn
+}
+
+// Close closes all the connections with the Gremlin server. Connections in
+// use by running queries are closed once the queries finish.
+func (conn Connection) Close() {
+	conn.pool.close()
 }
 
-// connectNeptuneIam is a [connHandler] for Neptune that creates an
-// authenticated connection using IAM.
-func connectNeptuneIam(cfg Config) (*gremlingo.DriverRemoteConnection, error) {
-	auth, err := getNeptuneAuth(context.Background(), cfg.Endpoint, cfg.AWSRegion)
+// connectPlain is a [connHandler] for Gremlin server that creates an
+// unauthenticated connection.
+func connectPlain(ctx context.Context, cfg Config) (*gremlingo.DriverRemoteConnection, error) {
+	tlsCfg, err := newTLSConfig(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("error getting AWS auth headers: %v", err)
+		return nil, fmt.Errorf("error loading TLS config: %w", err)
 	}
 
-	log.Debug.Printf("graph-intel-api: gremlin: connecting to Neptune")
+	log.FromContext(ctx).Debug.Printf("graph-intel-api: gremlin: connecting to Gremlin server")
 	conn, err := gremlingo.NewDriverRemoteConnection(cfg.Endpoint, func(settings *gremlingo.DriverRemoteConnectionSettings) {
-		settings.AuthInfo = gremlingo.HeaderAuthInfo(auth)
+		settings.TlsConfig = tlsCfg
 		settings.LogVerbosity = gremlingo.Off
 	})
 	return conn, err
 }
 
-// connectPlain is a [connHandler] for Gremlin server that creates an
-// unauthenticated connection.
-func connectPlain(cfg Config) (*gremlingo.DriverRemoteConnection, error) {
-	log.Debug.Printf("graph-intel-api: gremlin: connecting to Gremlin server")
+// connectBasic is a [connHandler] for Gremlin server that creates a
+// connection authenticated with username and password using SASL PLAIN.
+func connectBasic(ctx context.Context, cfg Config) (*gremlingo.DriverRemoteConnection, error) {
+	tlsCfg, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error loading TLS config: %w", err)
+	}
+
+	log.FromContext(ctx).Debug.Printf("graph-intel-api: gremlin: connecting to Gremlin server using basic auth")
 	conn, err := gremlingo.NewDriverRemoteConnection(cfg.Endpoint, func(settings *gremlingo.DriverRemoteConnectionSettings) {
+		settings.AuthInfo = gremlingo.BasicAuthInfo(cfg.Username, cfg.Password)
+		settings.TlsConfig = tlsCfg
 		settings.LogVerbosity = gremlingo.Off
 	})
 	return conn, err
 }
 
-// getNeptuneAuth returns the AWS auth headers required to interact with
-// Neptune.
-func getNeptuneAuth(ctx context.Context, endpoint, region string) (http.Header, error) {
-	log.Debug.Printf("graph-intel-api: gremlin: getting Neptune auth")
+// QueryFunc represents a Gremlin query in the context of a [Connection]. It is
+// executed by [Connectino.Query].
+type QueryFunc func(*gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error)
 
-	cfg, err := config.LoadDefaultConfig(ctx)
+// Query executes cf taking care of the authentication, reconnections and
+// retries. If ctx is done before the query finishes, Query returns the
+// context error and the query results are discarded. kind identifies the
+// query in the exported metrics.
+func (conn Connection) Query(ctx context.Context, kind string, cf QueryFunc) ([]*gremlingo.Result, error) {
+	return conn.query(ctx, kind, cf, conn.cfg.RetryLimit)
+}
+
+// QueryOnce executes cf like [Connection.Query] but without retries. It must
+// be used for queries that are not idempotent, like the ones adding vertices
+// with explicit IDs, because a query that fails could have been partially
+// applied.
+func (conn Connection) QueryOnce(ctx context.Context, kind string, cf QueryFunc) ([]*gremlingo.Result, error) {
+	return conn.query(ctx, kind, cf, 0)
+}
+
+// query executes cf retrying up to retryLimit times.
+func (conn Connection) query(ctx context.Context, kind string, cf QueryFunc, retryLimit int) (results []*gremlingo.Result, err error) {
+	start := time.Now()
+	defer func() {
+		result := "ok"
+		switch {
+		case errors.Is(err, ErrTimeout):
+			result = "timeout"
+		case err != nil:
+			result = "error"
+		}
+		queryDuration.WithLabelValues(kind, result).Observe(time.Since(start).Seconds())
+	}()
+
+	logger := log.FromContext(ctx).With("query_kind", kind)
+	for i := 0; i < retryLimit+1; i++ {
+		if i > 0 {
+			queryRetries.WithLabelValues(kind).Inc()
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		results, err = conn.execQuery(ctx, kind, cf)
+		if err == nil {
+			return results, nil
+		}
+
+		logger.Debug.Printf("graph-intel-api: gremlin: error executing query (%v/%v): %v", i+1, retryLimit+1, err)
+
+		if strings.Contains(err.Error(), `"code":"TimeLimitExceededException"`) {
+			queryTimeouts.WithLabelValues(kind).Inc()
+			return nil, ErrTimeout
+		}
+
+		if errors.Is(err, ErrClosed) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		if i < retryLimit {
+			jitter := time.Duration(rand.Int63n(1000)) * time.Millisecond
+			t := conn.cfg.RetryDuration + jitter
+
+			logger.Debug.Printf("graph-intel-api: gremlin: retrying in %v", t)
+
+			timer := time.NewTimer(t)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+
+	if retryLimit == 0 {
+		return nil, err
+	}
+	return nil, fmt.Errorf("max retries exceeded: %w", err)
+}
+
+// Check executes cf once using a dedicated remote connection that is closed
+// afterwards. Unlike [Connection.Query], it does not retry and does not wait
+// for a slot in the connection pool, so it reports the status of the Gremlin
+// server even if all the pooled connections are in use, along with the error
+// that made the query fail. It is meant for health checks. kind identifies
+// the query in the exported metrics.
+func (conn Connection) Check(ctx context.Context, kind string, cf QueryFunc) (results []*gremlingo.Result, err error) {
+	start := time.Now()
+	defer func() {
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+		queryDuration.WithLabelValues(kind, result).Observe(time.Since(start).Seconds())
+	}()
+
+	p := conn.pool
+
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	rc, err := p.h(ctx, p.cfg)
 	if err != nil {
-		return nil, fmt.Errorf("could not load AWS config: %w", err)
+		return nil, fmt.Errorf("could not connect: %w", err)
+	}
+	connectionsCreated.Inc()
+
+	type queryResult struct {
+		results []*gremlingo.Result
+		err     error
 	}
 
-	creds, err := cfg.Credentials.Retrieve(ctx)
+	ch := make(chan queryResult, 1)
+	go func() {
+		g := gremlingo.Traversal_().WithRemote(rc)
+		results, err := cf(g)
+		ch <- queryResult{results, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		// Closing the connection aborts the query.
+		go rc.Close()
+		return nil, fmt.Errorf("no response from Gremlin server: %w", ctx.Err())
+	case qr := <-ch:
+		rc.Close()
+		return qr.results, qr.err
+	}
+}
+
+// execQuery executes cf in the context of a pooled remote Gremlin connection.
+// If the query fails because of a transport or connection error, the remote
+// connection is discarded. Errors reported by the Gremlin server, like
+// evaluation errors or timeouts, leave the connection usable. If ctx is done
+// before the query finishes, execQuery returns the context error and the
+// remote connection is discarded, which aborts the query and frees its slot
+// in the pool right away. kind identifies the query in the exported metrics.
+func (conn Connection) execQuery(ctx context.Context, kind string, cf QueryFunc) ([]*gremlingo.Result, error) {
+	pc, err := conn.pool.get(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("could not get AWS credentials: %w", err)
+		return nil, fmt.Errorf("error getting driver remote connection: %w", err)
+	}
+
+	type queryResult struct {
+		results []*gremlingo.Result
+		err     error
+	}
+
+	// The connection is released either by the query when it finishes
+	// or by the caller when ctx is done, whichever happens first.
+	var once sync.Once
+
+	ch := make(chan queryResult, 1)
+	go func() {
+		g := gremlingo.Traversal_().WithRemote(pc.rc)
+		results, err := cf(g)
+		once.Do(func() { conn.pool.put(pc, err == nil || isServerError(err)) })
+		ch <- queryResult{results, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		once.Do(func() {
+			queriesAbandoned.WithLabelValues(kind).Inc()
+			conn.pool.discard(pc)
+		})
+		return nil, ctx.Err()
+	case qr := <-ch:
+		return qr.results, qr.err
+	}
+}
+
+// isServerError reports whether err is an error status returned by the
+// Gremlin server in response to a query. gremlin-go reports these errors with
+// the code E0502, while transport, connection and serialization errors use
+// other codes.
+func isServerError(err error) bool {
+	return strings.HasPrefix(err.Error(), "E0502:")
+}
+
+// pool is a pool of remote connections with a Gremlin server. Idle
+// connections are closed after [Config.PoolIdleTimeout] and checked every
+// [Config.PoolHealthCheckInterval].
+type pool struct {
+	cfg Config
+	h   connHandler
+
+	// sem limits the number of connections in use.
+	sem chan struct{}
+
+	// done is closed when the pool is closed.
+	done chan struct{}
+
+	// wg tracks the maintenance goroutine.
+	wg sync.WaitGroup
+
+	// onClose, if not nil, is called when the pool is closed.
+	onClose func()
+
+	mu     sync.Mutex
+	idle   []*poolConn
+	closed bool
+}
+
+// poolConn is a remote connection managed by a [pool].
+type poolConn struct {
+	rc       *gremlingo.DriverRemoteConnection
+	lastUsed time.Time
+}
+
+// newPool returns a [pool] that uses h to create new connections.
+func newPool(cfg Config, h connHandler) *pool {
+	size := cfg.PoolSize
+	if size <= 0 {
+		size = DefaultPoolSize
+	}
+
+	p := &pool{
+		cfg:  cfg,
+		h:    h,
+		sem:  make(chan struct{}, size),
+		done: make(chan struct{}),
+	}
+
+	if cfg.PoolHealthCheckInterval > 0 {
+		p.wg.Add(1)
+		go p.maintain()
+	}
+
+	return p
+}
+
+// get returns an idle connection or creates a new one if there are no idle
+// connections. It blocks until a connection is available or ctx is done if
+// the maximum number of connections are in use. The returned connection must
+// be returned to the pool calling [pool.put].
+func (p *pool) get(ctx context.Context) (*poolConn, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case p.sem <- struct{}{}:
+	}
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			<-p.sem
+			return nil, ErrClosed
+		}
+
+		n := len(p.idle)
+		if n == 0 {
+			p.mu.Unlock()
+			break
+		}
+
+		pc := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+
+		if p.expired(pc) {
+			log.FromContext(ctx).Debug.Printf("graph-intel-api: gremlin: closing idle connection")
+			pc.rc.Close()
+			continue
+		}
+
+		return pc, nil
 	}
 
-	req, err := http.NewRequest("GET", endpoint, nil)
+	rc, err := p.h(ctx, p.cfg)
 	if err != nil {
-		return nil, fmt.Errorf("could not create request: %w", err)
+		<-p.sem
+		return nil, err
 	}
+	connectionsCreated.Inc()
+	return &poolConn{rc: rc}, nil
+}
+
+// put returns pc to the pool. If healthy is false or the pool already has
+// [Config.PoolSize] idle connections, the connection is closed.
+func (p *pool) put(pc *poolConn, healthy bool) {
+	defer func() { <-p.sem }()
 
-	signer := v4.NewSigner()
-	if err := signer.SignHTTP(ctx, creds, req, emptyStringSHA256, "neptune-db", region, time.Now()); err != nil {
-		return nil, fmt.Errorf("could not sign request: %w", err)
+	p.mu.Lock()
+	if !healthy || p.closed || len(p.idle) >= cap(p.sem) {
+		p.mu.Unlock()
+		pc.rc.Close()
+		return
 	}
+	pc.lastUsed = time.Now()
+	p.idle = append(p.idle, pc)
+	p.mu.Unlock()
+}
 
-	return req.Header, nil
+// discard closes pc and frees its slot in the pool. The connection is closed
+// asynchronously, so a query still running on it does not block the caller.
+func (p *pool) discard(pc *poolConn) {
+	<-p.sem
+	go pc.rc.Close()
 }
 
-// QueryFunc represents a Gremlin query in the context of a [Connection]. It is
-// executed by [Connectino.Query].
-type QueryFunc func(*gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error)
+// expired reports whether pc has been idle for longer than
+// [Config.PoolIdleTimeout].
+func (p *pool) expired(pc *poolConn) bool {
+	return p.cfg.PoolIdleTimeout > 0 && time.Since(pc.lastUsed) > p.cfg.PoolIdleTimeout
+}
 
-// Query executes cf taking care of the authentication, reconnections and
-// retries.
-func (conn Connection) Query(cf QueryFunc) (results []*gremlingo.Result, err error) {
-	for i := 0; i < conn.cfg.RetryLimit+1; i++ {
-		results, err = conn.execQuery(cf)
-		if err == nil {
-			return results, nil
+// maintain periodically closes the idle connections that have expired or
+// are not healthy.
+func (p *pool) maintain() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cfg.PoolHealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.checkIdle()
 		}
+	}
+}
 
-		log.Debug.Printf("graph-intel-api: gremlin: error executing query (%v/%v): %v", i+1, conn.cfg.RetryLimit+1, err)
+// checkIdle closes the idle connections that have expired or are not
+// healthy. Connections are checked one at a time. A connection being checked
+// counts as in use, so the check is skipped if the maximum number of
+// connections are in use.
+func (p *pool) checkIdle() {
+	p.mu.Lock()
+	idle := make([]*poolConn, len(p.idle))
+	copy(idle, p.idle)
+	p.mu.Unlock()
+
+	for _, pc := range idle {
+		select {
+		case <-p.done:
+			return
+		case p.sem <- struct{}{}:
+		default:
+			return
+		}
 
-		if strings.Contains(err.Error(), `"code":"TimeLimitExceededException"`) {
-			return nil, ErrTimeout
+		if !p.take(pc) {
+			// The connection is in use or has been closed.
+			<-p.sem
+			continue
 		}
 
-		if i < conn.cfg.RetryLimit {
-			jitter := time.Duration(rand.Int63n(1000)) * time.Millisecond
-			t := conn.cfg.RetryDuration + jitter
+		p.check(pc)
+		<-p.sem
+	}
+}
+
+// take removes pc from the idle connections. It returns false if pc is not
+// idle.
+func (p *pool) take(pc *poolConn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-			log.Debug.Printf("graph-intel-api: gremlin: retrying in %v", t)
-			time.Sleep(t)
+	for i, ipc := range p.idle {
+		if ipc == pc {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			return true
 		}
 	}
+	return false
+}
 
-	return nil, fmt.Errorf("max retries exceeded: %w", err)
+// check closes pc if it has expired or is not healthy. Otherwise, it returns
+// pc to the idle connections preserving its last use.
+func (p *pool) check(pc *poolConn) {
+	if p.expired(pc) {
+		log.Debug.Printf("graph-intel-api: gremlin: closing idle connection")
+		pc.rc.Close()
+		return
+	}
+
+	if err := p.probe(pc); err != nil {
+		log.Debug.Printf("graph-intel-api: gremlin: closing unhealthy connection: %v", err)
+		// The probe could still be running, so the connection is
+		// closed asynchronously to avoid blocking the pool.
+		go pc.rc.Close()
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed || len(p.idle) >= cap(p.sem) {
+		pc.rc.Close()
+		return
+	}
+
+	// The idle connections are ordered by last use, so pc goes before
+	// the connections used while it was being checked.
+	i := 0
+	for i < len(p.idle) && p.idle[i].lastUsed.Before(pc.lastUsed) {
+		i++
+	}
+	p.idle = append(p.idle[:i], append([]*poolConn{pc}, p.idle[i:]...)...)
 }
 
-// execQuery executes cf in the context of a new remote Gremlin connection.
-func (conn Connection) execQuery(cf QueryFunc) ([]*gremlingo.Result, error) {
-	rc, err := conn.h(conn.cfg)
-	if err != nil {
-		return nil, fmt.Errorf("error creating driver remote connection: %v", err)
+// probe executes a trivial query using pc. It returns error if the query
+// fails, does not finish within [healthCheckTimeout] or the pool is closed.
+func (p *pool) probe(pc *poolConn) error {
+	ch := make(chan error, 1)
+	go func() {
+		g := gremlingo.Traversal_().WithRemote(pc.rc)
+		_, err := g.Inject(1).ToList()
+		ch <- err
+	}()
+
+	timer := time.NewTimer(healthCheckTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-ch:
+		return err
+	case <-timer.C:
+		return errors.New("health check timeout")
+	case <-p.done:
+		return ErrClosed
+	}
+}
+
+// close closes the pool and its idle connections. Connections in use are
+// closed when they are returned to the pool.
+func (p *pool) close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
 	}
-	defer rc.Close()
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	close(p.done)
+	p.wg.Wait()
 
-	g := gremlingo.Traversal_().WithRemote(rc)
-	return cf(g)
+	for _, pc := range idle {
+		pc.rc.Close()
+	}
+
+	if p.onClose != nil {
+		p.onClose()
+	}
 }
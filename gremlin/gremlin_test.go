@@ -1,35 +1,75 @@
 package gremlin
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
 	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
-const gremlinEndpoint = "ws://127.0.0.1:8182/gremlin"
+// gremlinEndpoint and gremlinAuthMode configure the Gremlin backend used by
+// the tests. By default, the tests use an in-memory graph. If the
+// GREMLIN_TEST_ENDPOINT environment variable is set, they use the Gremlin
+// server listening on that endpoint.
+var gremlinEndpoint, gremlinAuthMode = gremlinTestBackend()
+
+func gremlinTestBackend() (endpoint, authMode string) {
+	if endpoint := os.Getenv("GREMLIN_TEST_ENDPOINT"); endpoint != "" {
+		return endpoint, "plain"
+	}
+	return "", "memory"
+}
+
+// testConnHandler returns a [connHandler] for the Gremlin backend used by the
+// tests.
+func testConnHandler(t *testing.T) connHandler {
+	if gremlinAuthMode != "memory" {
+		return connectPlain
+	}
+
+	srv, err := acquireMemoryServer(gremlinEndpoint)
+	if err != nil {
+		t.Fatalf("error starting in-memory Gremlin server: %v", err)
+	}
+	t.Cleanup(srv.release)
+	return srv.connect
+}
 
 var wantVertices = []string{"v1", "v2"}
 
 func setupGraph() error {
-	conn, err := gremlingo.NewDriverRemoteConnection(gremlinEndpoint, func(settings *gremlingo.DriverRemoteConnectionSettings) {
-		settings.LogVerbosity = gremlingo.Off
-	})
+	cfg := Config{
+		Endpoint: gremlinEndpoint,
+		AuthMode: gremlinAuthMode,
+	}
+	conn, err := NewConnection(cfg)
 	if err != nil {
-		return fmt.Errorf("could not connect to gremlin-server: %w", err)
+		return fmt.Errorf("could not create connection: %w", err)
 	}
 	defer conn.Close()
 
-	g := gremlingo.Traversal_().WithRemote(conn)
+	_, err = conn.Query(context.Background(), "setup", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		<-g.V().Drop().Iterate()
 
-	<-g.V().Drop().Iterate()
+		for _, id := range wantVertices {
+			<-g.AddV(id).Iterate()
+		}
 
-	for _, id := range wantVertices {
-		<-g.AddV(id).Iterate()
-	}
-
-	return nil
+		return nil, nil
+	})
+	return err
 }
 
 func TestNewConnection_InvalidAuthMode(t *testing.T) {
@@ -39,6 +79,30 @@ func TestNewConnection_InvalidAuthMode(t *testing.T) {
 	}
 }
 
+func TestNewConnection_MissingBasicAuthCredentials(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{
+			name: "missing username",
+			cfg:  Config{AuthMode: "basic", Password: "password"},
+		},
+		{
+			name: "missing password",
+			cfg:  Config{AuthMode: "basic", Username: "user"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewConnection(tt.cfg); err == nil {
+				t.Error("unexpected nil error")
+			}
+		})
+	}
+}
+
 func TestConnectionQuery(t *testing.T) {
 	if err := setupGraph(); err != nil {
 		t.Fatalf("error setting up graph: %v", err)
@@ -46,14 +110,15 @@ func TestConnectionQuery(t *testing.T) {
 
 	cfg := Config{
 		Endpoint: gremlinEndpoint,
-		AuthMode: "plain",
+		AuthMode: gremlinAuthMode,
 	}
 	conn, err := NewConnection(cfg)
 	if err != nil {
 		t.Fatalf("error creating connection: %v", err)
 	}
+	defer conn.Close()
 
-	results, err := conn.Query(func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+	results, err := conn.Query(context.Background(), "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
 		return g.V().Label().ToList()
 	})
 	if err != nil {
@@ -69,3 +134,494 @@ func TestConnectionQuery(t *testing.T) {
 		t.Errorf("vertices mismatch (-want +got):\n%v", diff)
 	}
 }
+
+func TestConnectionQuery_Pool(t *testing.T) {
+	const (
+		poolSize = 2
+		nqueries = 10
+	)
+
+	if err := setupGraph(); err != nil {
+		t.Fatalf("error setting up graph: %v", err)
+	}
+
+	var (
+		mu     sync.Mutex
+		nconns int
+	)
+	connect := testConnHandler(t)
+	h := func(ctx context.Context, cfg Config) (*gremlingo.DriverRemoteConnection, error) {
+		mu.Lock()
+		nconns++
+		mu.Unlock()
+		return connect(ctx, cfg)
+	}
+
+	cfg := Config{
+		Endpoint: gremlinEndpoint,
+		AuthMode: gremlinAuthMode,
+		PoolSize: poolSize,
+	}
+	conn := newConnection(cfg, h)
+
+	var wg sync.WaitGroup
+	for i := 0; i < nqueries; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := conn.Query(context.Background(), "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V().Label().ToList()
+			})
+			if err != nil {
+				t.Errorf("query error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if nconns < 1 || nconns > poolSize {
+		t.Errorf("unexpected number of connections: got=%v want=[1, %v]", nconns, poolSize)
+	}
+
+	conn.Close()
+
+	_, err := conn.Query(context.Background(), "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return g.V().Label().ToList()
+	})
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("unexpected error: got=%v want=%v", err, ErrClosed)
+	}
+}
+
+func TestConnectionQuery_ServerError(t *testing.T) {
+	if err := setupGraph(); err != nil {
+		t.Fatalf("error setting up graph: %v", err)
+	}
+
+	var (
+		mu     sync.Mutex
+		nconns int
+	)
+	connect := testConnHandler(t)
+	h := func(ctx context.Context, cfg Config) (*gremlingo.DriverRemoteConnection, error) {
+		mu.Lock()
+		nconns++
+		mu.Unlock()
+		return connect(ctx, cfg)
+	}
+
+	cfg := Config{
+		Endpoint: gremlinEndpoint,
+		AuthMode: gremlinAuthMode,
+	}
+	conn := newConnection(cfg, h)
+	defer conn.Close()
+
+	// The query fails in the server, which must not discard the
+	// connection.
+	_, err := conn.Query(context.Background(), "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return g.V().Math("_ + 1").ToList()
+	})
+	if err == nil {
+		t.Fatal("unexpected nil error")
+	}
+
+	_, err = conn.Query(context.Background(), "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return g.V().Label().ToList()
+	})
+	if err != nil {
+		t.Fatalf("query error: %v", err)
+	}
+
+	if nconns != 1 {
+		t.Errorf("unexpected number of connections: got=%v want=%v", nconns, 1)
+	}
+}
+
+func TestConnectionQueryOnce(t *testing.T) {
+	cfg := Config{
+		Endpoint:      gremlinEndpoint,
+		AuthMode:      gremlinAuthMode,
+		RetryLimit:    3,
+		RetryDuration: time.Millisecond,
+	}
+	conn, err := NewConnection(cfg)
+	if err != nil {
+		t.Fatalf("error creating connection: %v", err)
+	}
+	defer conn.Close()
+
+	errQuery := errors.New("query error")
+
+	var calls int
+	_, err = conn.QueryOnce(context.Background(), "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		calls++
+		return nil, errQuery
+	})
+	if !errors.Is(err, errQuery) {
+		t.Errorf("unexpected error: got=%v want=%v", err, errQuery)
+	}
+
+	if calls != 1 {
+		t.Errorf("unexpected number of calls: got=%v want=%v", calls, 1)
+	}
+}
+
+func TestConnectionQuery_Context(t *testing.T) {
+	cfg := Config{
+		Endpoint:      "ws://127.0.0.1:1/gremlin",
+		AuthMode:      "plain",
+		RetryLimit:    5,
+		RetryDuration: time.Hour,
+	}
+	conn, err := NewConnection(cfg)
+	if err != nil {
+		t.Fatalf("error creating connection: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = conn.Query(ctx, "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return g.V().Label().ToList()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error: got=%v want=%v", err, context.DeadlineExceeded)
+	}
+}
+
+// saslServer is a Gremlin server that requires SASL PLAIN authentication
+// before forwarding the requests to an in-memory Gremlin server.
+type saslServer struct {
+	srv      *memoryServer
+	username string
+	password string
+}
+
+// ServeHTTP handles a websocket connection. The first request is answered
+// with an authentication challenge. Once the client is authenticated, the
+// pending request is evaluated.
+func (ss saslServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var upgrader websocket.Upgrader
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	var (
+		authenticated bool
+		pending       []byte
+		pendingID     [16]byte
+	)
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		req, err := decodeRequest(msg)
+		if err != nil {
+			return
+		}
+
+		var resp []byte
+		switch {
+		case authenticated:
+			resp = ss.srv.handle(msg)
+		case req.op == "authentication":
+			want := "\x00" + ss.username + "\x00" + ss.password
+			if sasl, _ := req.args["sasl"].(string); sasl != base64.StdEncoding.EncodeToString([]byte(want)) {
+				resp, _ = encodeResponse(pendingID, http.StatusUnauthorized, "invalid credentials", nil)
+				break
+			}
+			authenticated = true
+			resp = ss.srv.handle(pending)
+		default:
+			pending, pendingID = msg, req.id
+			resp, _ = encodeResponse(req.id, http.StatusProxyAuthRequired, "", nil)
+		}
+
+		if err := ws.WriteMessage(websocket.BinaryMessage, resp); err != nil {
+			return
+		}
+	}
+}
+
+func TestConnectionQuery_BasicAuth(t *testing.T) {
+	ts := httptest.NewServer(saslServer{
+		srv:      &memoryServer{g: newMemGraph()},
+		username: "user",
+		password: "password",
+	})
+	defer ts.Close()
+
+	endpoint := strings.Replace(ts.URL, "http://", "ws://", 1) + "/gremlin"
+
+	tests := []struct {
+		name       string
+		cfg        Config
+		wantNilErr bool
+	}{
+		{
+			name: "valid credentials",
+			cfg: Config{
+				Endpoint: endpoint,
+				AuthMode: "basic",
+				Username: "user",
+				Password: "password",
+			},
+			wantNilErr: true,
+		},
+		{
+			name: "invalid credentials",
+			cfg: Config{
+				Endpoint: endpoint,
+				AuthMode: "basic",
+				Username: "user",
+				Password: "invalid",
+			},
+			wantNilErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := NewConnection(tt.cfg)
+			if err != nil {
+				t.Fatalf("error creating connection: %v", err)
+			}
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			results, err := conn.Query(ctx, "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.Inject(1).ToList()
+			})
+			if (err == nil) != tt.wantNilErr {
+				t.Fatalf("unexpected error: wantNilErr=%v, got=%v", tt.wantNilErr, err)
+			}
+
+			if err == nil && (len(results) != 1 || results[0].GetInterface() != int32(1)) {
+				t.Errorf("unexpected results: %v", results)
+			}
+		})
+	}
+}
+
+func TestPoolPut_MaxIdle(t *testing.T) {
+	const poolSize = 2
+
+	if err := setupGraph(); err != nil {
+		t.Fatalf("error setting up graph: %v", err)
+	}
+
+	connect := testConnHandler(t)
+
+	cfg := Config{
+		Endpoint: gremlinEndpoint,
+		AuthMode: gremlinAuthMode,
+		PoolSize: poolSize,
+	}
+	p := newPool(cfg, connect)
+	defer p.close()
+
+	var pcs []*poolConn
+	for i := 0; i < poolSize; i++ {
+		pc, err := p.get(context.Background())
+		if err != nil {
+			t.Fatalf("error getting connection: %v", err)
+		}
+		pcs = append(pcs, pc)
+	}
+
+	// Simulate an idle connection returned to the pool while the
+	// others were in use.
+	rc, err := connect(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("error creating connection: %v", err)
+	}
+	p.mu.Lock()
+	p.idle = append(p.idle, &poolConn{rc: rc, lastUsed: time.Now()})
+	p.mu.Unlock()
+
+	for _, pc := range pcs {
+		p.put(pc, true)
+	}
+
+	if n := len(p.idle); n != poolSize {
+		t.Errorf("unexpected number of idle connections: got=%v want=%v", n, poolSize)
+	}
+}
+
+// hungServer is a Gremlin server that accepts websocket connections but
+// never answers the requests.
+type hungServer struct{}
+
+// ServeHTTP handles a websocket connection, discarding every request.
+func (hungServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var upgrader websocket.Upgrader
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	for {
+		if _, _, err := ws.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func TestPoolClose_HungHealthCheck(t *testing.T) {
+	ts := httptest.NewServer(hungServer{})
+	defer ts.Close()
+
+	cfg := Config{
+		Endpoint:                strings.Replace(ts.URL, "http://", "ws://", 1) + "/gremlin",
+		AuthMode:                "plain",
+		PoolHealthCheckInterval: 10 * time.Millisecond,
+	}
+	p := newPool(cfg, connectPlain)
+
+	rc, err := connectPlain(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("error creating connection: %v", err)
+	}
+	p.mu.Lock()
+	p.idle = append(p.idle, &poolConn{rc: rc, lastUsed: time.Now()})
+	p.mu.Unlock()
+
+	// Wait for the health check to start.
+	time.Sleep(100 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		p.close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("pool close blocked by health check")
+	}
+}
+
+func TestConnectionQuery_Abandoned(t *testing.T) {
+	ts := httptest.NewServer(hungServer{})
+	defer ts.Close()
+
+	cfg := Config{
+		Endpoint: strings.Replace(ts.URL, "http://", "ws://", 1) + "/gremlin",
+		AuthMode: "plain",
+		PoolSize: 1,
+	}
+	conn, err := NewConnection(cfg)
+	if err != nil {
+		t.Fatalf("error creating connection: %v", err)
+	}
+	defer conn.Close()
+
+	abandoned := testutil.ToFloat64(queriesAbandoned.WithLabelValues("abandoned"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = conn.Query(ctx, "abandoned", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return g.Inject(1).ToList()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: got=%v want=%v", err, context.DeadlineExceeded)
+	}
+
+	if n := testutil.ToFloat64(queriesAbandoned.WithLabelValues("abandoned")) - abandoned; n != 1 {
+		t.Errorf("unexpected number of abandoned queries: got=%v want=%v", n, 1)
+	}
+
+	// The abandoned query must not hold the only slot of the pool.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	pc, err := conn.pool.get(ctx)
+	if err != nil {
+		t.Fatalf("error getting connection: %v", err)
+	}
+	conn.pool.put(pc, true)
+}
+
+func TestConnectionCheck(t *testing.T) {
+	if err := setupGraph(); err != nil {
+		t.Fatalf("error setting up graph: %v", err)
+	}
+
+	cfg := Config{
+		Endpoint: gremlinEndpoint,
+		AuthMode: gremlinAuthMode,
+		PoolSize: 1,
+	}
+	conn, err := NewConnection(cfg)
+	if err != nil {
+		t.Fatalf("error creating connection: %v", err)
+	}
+	defer conn.Close()
+
+	// Take the only slot of the pool, so the check cannot use it.
+	pc, err := conn.pool.get(context.Background())
+	if err != nil {
+		t.Fatalf("error getting connection: %v", err)
+	}
+	defer conn.pool.put(pc, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	results, err := conn.Check(ctx, "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return g.V().Label().ToList()
+	})
+	if err != nil {
+		t.Fatalf("check error: %v", err)
+	}
+
+	var got []string
+	for _, r := range results {
+		got = append(got, r.GetString())
+	}
+
+	if diff := cmp.Diff(wantVertices, got); diff != "" {
+		t.Errorf("vertices mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestConnectionCheck_Error(t *testing.T) {
+	cfg := Config{
+		Endpoint:      "ws://127.0.0.1:1/gremlin",
+		AuthMode:      "plain",
+		RetryLimit:    5,
+		RetryDuration: time.Hour,
+	}
+	conn, err := NewConnection(cfg)
+	if err != nil {
+		t.Fatalf("error creating connection: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The check must not be retried, so it reports the connection
+	// error instead of timing out.
+	_, err = conn.Check(ctx, "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return g.Inject(1).ToList()
+	})
+	if err == nil {
+		t.Fatal("unexpected nil error")
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
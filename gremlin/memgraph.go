@@ -0,0 +1,490 @@
+package gremlin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memGraph is an in-memory property graph. Vertices and edges are iterated
+// in insertion order. IDs are normalized with [normalizeID].
+type memGraph struct {
+	mu sync.RWMutex
+
+	vertices []*memVertex
+	vindex   map[any]*memVertex
+
+	edges  []*memEdge
+	eindex map[any]*memEdge
+
+	// nextID is the next ID assigned to the elements created without
+	// an explicit ID.
+	nextID int64
+}
+
+// memVertex is a vertex of a [memGraph].
+type memVertex struct {
+	id    any
+	label string
+	props []memProperty
+	out   []*memEdge
+	in    []*memEdge
+}
+
+// memEdge is an edge of a [memGraph].
+type memEdge struct {
+	id    any
+	label string
+	props []memProperty
+	out   *memVertex
+	in    *memVertex
+}
+
+// memProperty is a property of a vertex or an edge.
+type memProperty struct {
+	key   string
+	value any
+}
+
+// newMemGraph returns an empty [memGraph].
+func newMemGraph() *memGraph {
+	return &memGraph{
+		vindex: make(map[any]*memVertex),
+		eindex: make(map[any]*memEdge),
+	}
+}
+
+// normalizeID returns the representation of an element ID used to index
+// the elements of a [memGraph]. Integer IDs are converted to int64.
+func normalizeID(id any) any {
+	switch v := id.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case vertexRef:
+		return normalizeID(v.id)
+	case *memVertex:
+		return v.id
+	case *memEdge:
+		return v.id
+	}
+	return id
+}
+
+// newID returns an ID that is not used by any element of the graph.
+func (g *memGraph) newID() int64 {
+	for {
+		id := g.nextID
+		g.nextID++
+		if g.vindex[id] == nil && g.eindex[id] == nil {
+			return id
+		}
+	}
+}
+
+// addVertex adds a vertex to the graph. If id is nil, an ID is assigned
+// automatically.
+func (g *memGraph) addVertex(id any, label string) (*memVertex, error) {
+	if id == nil {
+		id = g.newID()
+	}
+	id = normalizeID(id)
+	if g.vindex[id] != nil {
+		return nil, fmt.Errorf("vertex with id %v already exists", id)
+	}
+
+	v := &memVertex{id: id, label: label}
+	g.vertices = append(g.vertices, v)
+	g.vindex[id] = v
+	return v, nil
+}
+
+// addEdge adds an edge from out to in. If id is nil, an ID is assigned
+// automatically.
+func (g *memGraph) addEdge(id any, label string, out, in *memVertex) (*memEdge, error) {
+	if id == nil {
+		id = g.newID()
+	}
+	id = normalizeID(id)
+	if g.eindex[id] != nil {
+		return nil, fmt.Errorf("edge with id %v already exists", id)
+	}
+
+	e := &memEdge{id: id, label: label, out: out, in: in}
+	g.edges = append(g.edges, e)
+	g.eindex[id] = e
+	out.out = append(out.out, e)
+	in.in = append(in.in, e)
+	return e, nil
+}
+
+// setVertexID changes the ID of a vertex.
+func (g *memGraph) setVertexID(v *memVertex, id any) error {
+	id = normalizeID(id)
+	if id == v.id {
+		return nil
+	}
+	if g.vindex[id] != nil {
+		return fmt.Errorf("vertex with id %v already exists", id)
+	}
+	delete(g.vindex, v.id)
+	v.id = id
+	g.vindex[id] = v
+	return nil
+}
+
+// remove removes the provided vertices and edges from the graph. The edges
+// of the removed vertices are also removed.
+func (g *memGraph) remove(vertices []*memVertex, edges []*memEdge) {
+	if len(vertices) == 0 && len(edges) == 0 {
+		return
+	}
+
+	rv := make(map[*memVertex]bool, len(vertices))
+	for _, v := range vertices {
+		rv[v] = true
+		delete(g.vindex, v.id)
+	}
+
+	re := make(map[*memEdge]bool, len(edges))
+	for _, e := range edges {
+		re[e] = true
+	}
+	for _, e := range g.edges {
+		if rv[e.out] || rv[e.in] {
+			re[e] = true
+		}
+	}
+	for e := range re {
+		delete(g.eindex, e.id)
+	}
+
+	g.vertices = filterElements(g.vertices, func(v *memVertex) bool { return !rv[v] })
+	g.edges = filterElements(g.edges, func(e *memEdge) bool { return !re[e] })
+
+	// Only the edges of the vertices adjacent to a removed edge change.
+	adjacent := make(map[*memVertex]bool)
+	for e := range re {
+		adjacent[e.out] = true
+		adjacent[e.in] = true
+	}
+	for v := range adjacent {
+		if rv[v] {
+			continue
+		}
+		v.out = filterElements(v.out, func(e *memEdge) bool { return !re[e] })
+		v.in = filterElements(v.in, func(e *memEdge) bool { return !re[e] })
+	}
+}
+
+// filterElements returns the elements of s for which keep returns true.
+func filterElements[T any](s []T, keep func(T) bool) []T {
+	var out []T
+	for _, e := range s {
+		if keep(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// setProperty sets the value of a property. If multi is false, the
+// existing values of the property are replaced.
+func setProperty(props []memProperty, key string, value any, multi bool) []memProperty {
+	if !multi {
+		props = filterElements(props, func(p memProperty) bool { return p.key != key })
+	}
+	return append(props, memProperty{key: key, value: value})
+}
+
+// propertyValues returns the values of a property.
+func propertyValues(props []memProperty, key string) []any {
+	var values []any
+	for _, p := range props {
+		if p.key == key {
+			values = append(values, p.value)
+		}
+	}
+	return values
+}
+
+// loadGraphSON returns a [memGraph] with the elements of a GraphSON file.
+// The file must use the adjacency list format written by the TinkerPop
+// GraphSONWriter, that is, one JSON object per line describing a vertex
+// along with its properties and its outgoing edges. For instance, the file
+// written by the query
+//
+//	g.io("graph.json").with(IO.writer, IO.graphson).write()
+//
+// GraphSON 1.0, 2.0 and 3.0 are supported.
+func loadGraphSON(path string) (*memGraph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open GraphSON file: %w", err)
+	}
+	defer f.Close()
+
+	type graphsonEdge struct {
+		ID         json.RawMessage            `json:"id"`
+		InV        json.RawMessage            `json:"inV"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+
+	type graphsonVertex struct {
+		ID         json.RawMessage                     `json:"id"`
+		Label      string                              `json:"label"`
+		OutE       map[string][]graphsonEdge           `json:"outE"`
+		Properties map[string][]graphsonVertexProperty `json:"properties"`
+	}
+
+	g := newMemGraph()
+
+	var vertices []graphsonVertex
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 64*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		var gv graphsonVertex
+		if err := json.Unmarshal(b, &gv); err != nil {
+			return nil, fmt.Errorf("invalid vertex in line %v: %w", line, err)
+		}
+
+		id, err := graphsonValue(gv.ID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vertex id in line %v: %w", line, err)
+		}
+		label := gv.Label
+		if label == "" {
+			label = "vertex"
+		}
+		v, err := g.addVertex(id, label)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vertex in line %v: %w", line, err)
+		}
+		for _, key := range sortedKeys(gv.Properties) {
+			for _, vp := range gv.Properties[key] {
+				value, err := graphsonValue(vp.Value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid property %q of vertex %v: %w", key, id, err)
+				}
+				v.props = setProperty(v.props, key, value, true)
+			}
+		}
+
+		vertices = append(vertices, gv)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("could not read GraphSON file: %w", err)
+	}
+
+	// Edges are created once all the vertices exist. Only the outgoing
+	// edges are considered, because every edge is also reported as an
+	// incoming edge of its in vertex.
+	for i, gv := range vertices {
+		out := g.vertices[i]
+		for _, label := range sortedKeys(gv.OutE) {
+			for _, ge := range gv.OutE[label] {
+				id, err := graphsonValue(ge.ID)
+				if err != nil {
+					return nil, fmt.Errorf("invalid edge id of vertex %v: %w", out.id, err)
+				}
+				inID, err := graphsonValue(ge.InV)
+				if err != nil {
+					return nil, fmt.Errorf("invalid in vertex of edge %v: %w", id, err)
+				}
+				in := g.vindex[normalizeID(inID)]
+				if in == nil {
+					return nil, fmt.Errorf("unknown in vertex %v of edge %v", inID, id)
+				}
+				e, err := g.addEdge(id, label, out, in)
+				if err != nil {
+					return nil, err
+				}
+				for _, key := range sortedKeys(ge.Properties) {
+					value, err := graphsonValue(ge.Properties[key])
+					if err != nil {
+						return nil, fmt.Errorf("invalid property %q of edge %v: %w", key, id, err)
+					}
+					e.props = setProperty(e.props, key, value, false)
+				}
+			}
+		}
+	}
+
+	return g, nil
+}
+
+// sortedKeys returns the keys of m in lexicographical order, so the elements
+// of a GraphSON file are always loaded in the same order.
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// graphsonVertexProperty is a vertex property in a GraphSON file.
+type graphsonVertexProperty struct {
+	Value json.RawMessage `json:"value"`
+}
+
+// UnmarshalJSON unmarshals a vertex property. GraphSON 2.0 and 3.0 wrap
+// vertex properties in a typed object.
+func (vp *graphsonVertexProperty) UnmarshalJSON(b []byte) error {
+	var typed struct {
+		Type  string          `json:"@type"`
+		Value json.RawMessage `json:"@value"`
+	}
+	if err := json.Unmarshal(b, &typed); err != nil {
+		return err
+	}
+	if typed.Type == "g:VertexProperty" {
+		b = typed.Value
+	}
+
+	var raw struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	vp.Value = raw.Value
+	return nil
+}
+
+// graphsonValue decodes a GraphSON value. Typed values are converted to the
+// Go types used by the GraphBinary serializer. Untyped integers are
+// decoded as int64 and the rest of untyped numbers as float64.
+func graphsonValue(raw json.RawMessage) (any, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("missing value")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return convertGraphSON(v)
+}
+
+// convertGraphSON converts a decoded GraphSON value.
+func convertGraphSON(v any) (any, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case []any:
+		l := make([]any, 0, len(v))
+		for _, e := range v {
+			ce, err := convertGraphSON(e)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, ce)
+		}
+		return l, nil
+	case map[string]any:
+		typ, ok := v["@type"].(string)
+		if !ok {
+			m := make(map[any]any, len(v))
+			for k, e := range v {
+				ce, err := convertGraphSON(e)
+				if err != nil {
+					return nil, err
+				}
+				m[k] = ce
+			}
+			return m, nil
+		}
+		return convertTypedGraphSON(typ, v["@value"])
+	}
+	return v, nil
+}
+
+// convertTypedGraphSON converts a typed GraphSON value.
+func convertTypedGraphSON(typ string, v any) (any, error) {
+	switch typ {
+	case "g:Int32":
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("invalid %v value", typ)
+		}
+		i, err := n.Int64()
+		return int32(i), err
+	case "g:Int64":
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("invalid %v value", typ)
+		}
+		return n.Int64()
+	case "g:Float":
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("invalid %v value", typ)
+		}
+		f, err := n.Float64()
+		return float32(f), err
+	case "g:Double":
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("invalid %v value", typ)
+		}
+		return n.Float64()
+	case "g:Date", "g:Timestamp":
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("invalid %v value", typ)
+		}
+		ms, err := n.Int64()
+		return time.UnixMilli(ms), err
+	case "g:List", "g:Set":
+		return convertGraphSON(v)
+	case "g:Map":
+		l, ok := v.([]any)
+		if !ok || len(l)%2 != 0 {
+			return nil, fmt.Errorf("invalid %v value", typ)
+		}
+		m := make(map[any]any, len(l)/2)
+		for i := 0; i < len(l); i += 2 {
+			k, err := convertGraphSON(l[i])
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case []any, map[any]any:
+				return nil, fmt.Errorf("unsupported %v key", typ)
+			}
+			e, err := convertGraphSON(l[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m[k] = e
+		}
+		return m, nil
+	}
+	// Other types, like g:UUID, are represented by their value.
+	return convertGraphSON(v)
+}
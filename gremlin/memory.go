@@ -0,0 +1,263 @@
+package gremlin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adevinta/graph-intel-api/log"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+	"github.com/gorilla/websocket"
+)
+
+// Response status codes returned by the in-memory Gremlin server and the
+// Cosmos DB proxy.
+const (
+	statusSuccess          = 200
+	statusNoContent        = 204
+	statusMalformedRequest = 499
+	statusServerError      = 500
+	statusSerializeError   = 599
+	statusTimeout          = 598
+)
+
+// memoryGraphs contains the in-memory graphs indexed by the GraphSON file
+// they were loaded from. Connections with the same endpoint share the same
+// graph, which lives until the process exits. memoryServers contains the
+// running in-memory Gremlin servers of these graphs. A server is shut down
+// when the last [Connection] using it is closed.
+var (
+	memoryServersMu sync.Mutex
+	memoryGraphs    = make(map[string]*memGraph)
+	memoryServers   = make(map[string]*memoryServer)
+)
+
+// acquireMemoryServer returns the in-memory Gremlin server of a GraphSON file,
+// starting it if it is not running yet. If path is empty, the graph is
+// initially empty. The server must be released calling
+// [memoryServer.release] when it is no longer needed.
+func acquireMemoryServer(path string) (*memoryServer, error) {
+	memoryServersMu.Lock()
+	defer memoryServersMu.Unlock()
+
+	if srv, ok := memoryServers[path]; ok {
+		srv.refs++
+		return srv, nil
+	}
+
+	g, ok := memoryGraphs[path]
+	if !ok {
+		g = newMemGraph()
+		if path != "" {
+			var err error
+			if g, err = loadGraphSON(path); err != nil {
+				return nil, err
+			}
+		}
+		memoryGraphs[path] = g
+	}
+
+	srv, err := newMemoryServer(g)
+	if err != nil {
+		return nil, err
+	}
+	srv.path = path
+	srv.refs = 1
+	memoryServers[path] = srv
+	return srv, nil
+}
+
+// release releases a server returned by [acquireMemoryServer]. Once it has
+// been released by all its users, the server is shut down. The graph is kept,
+// so it is served again by the next call to [acquireMemoryServer].
+func (srv *memoryServer) release() {
+	memoryServersMu.Lock()
+	defer memoryServersMu.Unlock()
+
+	srv.refs--
+	if srv.refs > 0 {
+		return
+	}
+
+	delete(memoryServers, srv.path)
+	if err := srv.hs.Close(); err != nil {
+		log.Error.Printf("graph-intel-api: gremlin: error closing in-memory Gremlin server: %v", err)
+	}
+}
+
+// connect is a [connHandler] that creates a connection with the in-memory
+// Gremlin server.
+func (srv *memoryServer) connect(ctx context.Context, cfg Config) (*gremlingo.DriverRemoteConnection, error) {
+	log.FromContext(ctx).Debug.Printf("graph-intel-api: gremlin: connecting to in-memory Gremlin server")
+	conn, err := gremlingo.NewDriverRemoteConnection(srv.url, func(settings *gremlingo.DriverRemoteConnectionSettings) {
+		settings.LogVerbosity = gremlingo.Off
+	})
+	return conn, err
+}
+
+// memoryServer is a Gremlin server that listens on the loopback interface
+// and evaluates bytecode traversals against a [memGraph]. It only supports
+// the GraphBinary serializer.
+type memoryServer struct {
+	g        *memGraph
+	url      string
+	upgrader websocket.Upgrader
+	hs       *http.Server
+
+	// path and refs are the key of the server in memoryServers and its
+	// number of users. They are protected by memoryServersMu.
+	path string
+	refs int
+}
+
+// newMemoryServer starts a Gremlin server for g on a random local port.
+func newMemoryServer(g *memGraph) (*memoryServer, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("could not listen: %w", err)
+	}
+
+	srv := &memoryServer{
+		g:   g,
+		url: fmt.Sprintf("ws://%v/gremlin", ln.Addr()),
+	}
+	srv.hs = &http.Server{Handler: srv}
+	go func() {
+		if err := srv.hs.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			log.Error.Printf("graph-intel-api: gremlin: in-memory Gremlin server error: %v", err)
+		}
+	}()
+
+	return srv, nil
+}
+
+// ServeHTTP handles a websocket connection. Requests are processed
+// concurrently.
+func (srv *memoryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := srv.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	serveWebsocket(ws, func(msg []byte) ([]byte, error) {
+		return srv.handle(msg), nil
+	})
+}
+
+// serveWebsocket reads the requests sent through ws and writes the responses
+// returned by handle. Requests are processed concurrently. If handle returns
+// an error, ws is closed, so the client stops using the connection. It
+// returns when ws is closed.
+func serveWebsocket(ws *websocket.Conn, handle func(msg []byte) ([]byte, error)) {
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	defer wg.Wait()
+
+	for {
+		typ, msg, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		if typ != websocket.BinaryMessage {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, err := handle(msg)
+			if err != nil {
+				ws.Close()
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			ws.WriteMessage(websocket.BinaryMessage, resp) //nolint:errcheck
+		}()
+	}
+}
+
+// handle processes a request and returns the response.
+func (srv *memoryServer) handle(msg []byte) []byte {
+	req, err := decodeRequest(msg)
+	if err != nil {
+		return gremlinResponse(req.id, statusMalformedRequest, fmt.Sprintf("invalid request: %v", err), nil)
+	}
+	if req.op != "bytecode" {
+		return gremlinResponse(req.id, statusMalformedRequest, fmt.Sprintf("unsupported operation %q", req.op), nil)
+	}
+
+	bc, ok := req.args["gremlin"].(*bytecode)
+	if !ok {
+		return gremlinResponse(req.id, statusMalformedRequest, "missing bytecode", nil)
+	}
+	t, err := compileTraversal(bc)
+	if err != nil {
+		return gremlinResponse(req.id, statusMalformedRequest, fmt.Sprintf("invalid bytecode: %v", err), nil)
+	}
+
+	ctx := context.Background()
+	if timeout, ok := toInt(req.args["evaluationTimeout"]); ok && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+		defer cancel()
+	}
+
+	results, err := srv.eval(ctx, t)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return timeoutResponse(req.id)
+	case err != nil:
+		return gremlinResponse(req.id, statusServerError, err.Error(), nil)
+	case len(results) == 0:
+		return gremlinResponse(req.id, statusNoContent, "", nil)
+	}
+	return gremlinResponse(req.id, statusSuccess, "", results)
+}
+
+// eval evaluates a traversal. Traversals that modify the graph are
+// serialized.
+func (srv *memoryServer) eval(ctx context.Context, t *memTraversal) ([]any, error) {
+	if t.mutates() {
+		srv.g.mu.Lock()
+		defer srv.g.mu.Unlock()
+	} else {
+		srv.g.mu.RLock()
+		defer srv.g.mu.RUnlock()
+	}
+
+	return evaluator{ctx: ctx, g: srv.g}.eval(t)
+}
+
+// gremlinResponse returns an encoded response. If the data cannot be encoded,
+// a serialization error is returned instead.
+func gremlinResponse(id [16]byte, code int, msg string, data []any) []byte {
+	var v any
+	if data != nil {
+		v = data
+	}
+
+	resp, err := encodeResponse(id, code, strings.TrimSpace(msg), v)
+	if err != nil {
+		resp, _ = encodeResponse(id, statusSerializeError, fmt.Sprintf("could not serialize result: %v", err), nil)
+	}
+	return resp
+}
+
+// timeoutResponse returns an encoded timeout response. Timeouts are reported
+// like Neptune does, so they are detected by [Connection.Query].
+func timeoutResponse(id [16]byte) []byte {
+	msg := fmt.Sprintf(`{"code":"TimeLimitExceededException","detailedMessage":%q}`, "A timeout occurred during traversal evaluation")
+	return gremlinResponse(id, statusTimeout, msg, nil)
+}
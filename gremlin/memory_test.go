@@ -0,0 +1,226 @@
+package gremlin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConnectionQuery_Memory(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      QueryFunc
+		want       []any
+		wantNilErr bool
+	}{
+		{
+			name: "count",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V().Count().ToList()
+			},
+			want:       []any{int64(4)},
+			wantNilErr: true,
+		},
+		{
+			name: "out",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V(1).Out().Values("name").ToList()
+			},
+			want:       []any{"lop", "vadas", "josh"},
+			wantNilErr: true,
+		},
+		{
+			name: "predicate",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V().Has("age", gremlingo.P.Gt(28)).Values("name").ToList()
+			},
+			want:       []any{"marko", "josh"},
+			wantNilErr: true,
+		},
+		{
+			name: "edge property",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.E().Has("weight", 1.0).OutV().Values("name").ToList()
+			},
+			want:       []any{"marko"},
+			wantNilErr: true,
+		},
+		{
+			name: "repeat",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V(1).Repeat(gremlingo.T__.Out()).Times(2).Path().Count(gremlingo.Scope.Local).ToList()
+			},
+			want:       []any{int64(3)},
+			wantNilErr: true,
+		},
+		{
+			name: "order and project",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V().HasLabel("person").
+					Order().By("age", gremlingo.Order.Desc).
+					Project("name", "knows").
+					By(gremlingo.T__.Values("name")).
+					By(gremlingo.T__.Out("knows").Count()).
+					ToList()
+			},
+			want: []any{
+				map[any]any{"name": "josh", "knows": int64(0)},
+				map[any]any{"name": "marko", "knows": int64(2)},
+				map[any]any{"name": "vadas", "knows": int64(0)},
+			},
+			wantNilErr: true,
+		},
+		{
+			name: "coalesce",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V().HasLabel("software").Coalesce(gremlingo.T__.Values("age"), gremlingo.T__.Values("lang")).ToList()
+			},
+			want:       []any{"java"},
+			wantNilErr: true,
+		},
+		{
+			name: "no results",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V("unknown").ToList()
+			},
+			want:       nil,
+			wantNilErr: true,
+		},
+		{
+			name: "unsupported step",
+			query: func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.V().Sack().ToList()
+			},
+			want:       nil,
+			wantNilErr: false,
+		},
+	}
+
+	cfg := Config{
+		Endpoint: "testdata/graph.json",
+		AuthMode: "memory",
+	}
+	conn, err := NewConnection(cfg)
+	if err != nil {
+		t.Fatalf("error creating connection: %v", err)
+	}
+	defer conn.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := conn.Query(context.Background(), "test", tt.query)
+			if (err == nil) != tt.wantNilErr {
+				t.Fatalf("unexpected error: wantNilErr=%v, got=%v", tt.wantNilErr, err)
+			}
+
+			var got []any
+			for _, r := range results {
+				got = append(got, r.GetInterface())
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("results mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestConnectionQuery_MemoryTimeout(t *testing.T) {
+	cfg := Config{
+		Endpoint: "testdata/graph.json",
+		AuthMode: "memory",
+	}
+	conn, err := NewConnection(cfg)
+	if err != nil {
+		t.Fatalf("error creating connection: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Query(context.Background(), "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		// The traversal never ends, because the graph has cycles.
+		return g.With("evaluationTimeout", 10).V().Repeat(gremlingo.T__.Both()).ToList()
+	})
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("unexpected error: got=%v want=%v", err, ErrTimeout)
+	}
+}
+
+func TestNewConnection_MemoryInvalidFile(t *testing.T) {
+	cfg := Config{
+		Endpoint: "testdata/missing.json",
+		AuthMode: "memory",
+	}
+	if _, err := NewConnection(cfg); err == nil {
+		t.Error("unexpected nil error")
+	}
+}
+
+func TestConnectionClose_Memory(t *testing.T) {
+	b, err := os.ReadFile("testdata/graph.json")
+	if err != nil {
+		t.Fatalf("error reading GraphSON file: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "graph.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("error writing GraphSON file: %v", err)
+	}
+
+	cfg := Config{
+		Endpoint: path,
+		AuthMode: "memory",
+	}
+	conn, err := NewConnection(cfg)
+	if err != nil {
+		t.Fatalf("error creating connection: %v", err)
+	}
+
+	_, err = conn.Query(context.Background(), "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return nil, <-g.AddV("test").Iterate()
+	})
+	if err != nil {
+		t.Fatalf("query error: %v", err)
+	}
+
+	memoryServersMu.Lock()
+	srv := memoryServers[path]
+	memoryServersMu.Unlock()
+
+	conn.Close()
+
+	memoryServersMu.Lock()
+	_, running := memoryServers[path]
+	memoryServersMu.Unlock()
+	if running {
+		t.Error("in-memory Gremlin server is still registered")
+	}
+
+	addr := strings.TrimSuffix(strings.TrimPrefix(srv.url, "ws://"), "/gremlin")
+	if c, err := net.Dial("tcp", addr); err == nil {
+		c.Close()
+		t.Error("in-memory Gremlin server is still listening")
+	}
+
+	// The graph outlives the server.
+	conn, err = NewConnection(cfg)
+	if err != nil {
+		t.Fatalf("error creating connection: %v", err)
+	}
+	defer conn.Close()
+
+	results, err := conn.Query(context.Background(), "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return g.V().HasLabel("test").Count().ToList()
+	})
+	if err != nil {
+		t.Fatalf("query error: %v", err)
+	}
+	if len(results) != 1 || results[0].GetInterface() != int64(1) {
+		t.Errorf("unexpected results: %v", results)
+	}
+}
@@ -0,0 +1,1677 @@
+package gremlin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// errNoValue is returned when a modulating traversal does not return any
+// value.
+var errNoValue = errors.New("the provided traverser does not map to a value")
+
+// memTraversal is a compiled Gremlin bytecode that can be evaluated
+// against a [memGraph].
+type memTraversal struct {
+	steps []*memStep
+}
+
+// memStep is a step of a [memTraversal] along with its modulators. Nested
+// bytecodes in the arguments of the step and its modulators are compiled
+// into [*memTraversal] values.
+type memStep struct {
+	name string
+	args []any
+
+	// mods are the modulators following the step, like by() or
+	// times().
+	mods []instruction
+
+	// pre are the modulators preceding a repeat step, like emit() in
+	// emit().repeat().
+	pre []instruction
+}
+
+// modulators are the bytecode instructions that modulate the preceding
+// step instead of being steps themselves.
+var modulators = map[string]bool{
+	"by":     true,
+	"emit":   true,
+	"from":   true,
+	"option": true,
+	"times":  true,
+	"to":     true,
+	"until":  true,
+	"with":   true,
+}
+
+// mutatingSteps are the steps that modify the graph.
+var mutatingSteps = map[string]bool{
+	"addE":     true,
+	"addV":     true,
+	"drop":     true,
+	"property": true,
+}
+
+// compileTraversal compiles a bytecode. Source instructions are ignored.
+func compileTraversal(bc *bytecode) (*memTraversal, error) {
+	var (
+		t   memTraversal
+		pre []instruction
+	)
+	for _, inst := range bc.steps {
+		args, err := compileArgs(inst.args)
+		if err != nil {
+			return nil, err
+		}
+		inst = instruction{name: inst.name, args: args}
+
+		if !modulators[inst.name] {
+			t.steps = append(t.steps, &memStep{name: inst.name, args: inst.args, pre: pre})
+			pre = nil
+			continue
+		}
+
+		var last *memStep
+		if len(t.steps) > 0 {
+			last = t.steps[len(t.steps)-1]
+		}
+
+		switch {
+		case last != nil && last.name == "repeat":
+			last.mods = append(last.mods, inst)
+		case inst.name == "emit" || inst.name == "until" || inst.name == "times":
+			pre = append(pre, inst)
+		case last != nil:
+			last.mods = append(last.mods, inst)
+		default:
+			return nil, fmt.Errorf("unexpected modulator %q", inst.name)
+		}
+	}
+	if len(pre) > 0 {
+		return nil, errors.New("repeat modulators without repeat step")
+	}
+	return &t, nil
+}
+
+// compileArgs compiles the nested bytecodes of the arguments of an
+// instruction.
+func compileArgs(args []any) ([]any, error) {
+	out := make([]any, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case *bytecode:
+			t, err := compileTraversal(v)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = t
+		case []any:
+			l, err := compileArgs(v)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = l
+		default:
+			out[i] = arg
+		}
+	}
+	return out, nil
+}
+
+// mutates reports whether the traversal modifies the graph.
+func (t *memTraversal) mutates() bool {
+	for _, s := range t.steps {
+		if mutatingSteps[s.name] {
+			return true
+		}
+		for _, arg := range s.args {
+			if nt, ok := arg.(*memTraversal); ok && nt.mutates() {
+				return true
+			}
+		}
+		for _, inst := range append(s.pre, s.mods...) {
+			for _, arg := range inst.args {
+				if nt, ok := arg.(*memTraversal); ok && nt.mutates() {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// pathNode is an element of the path followed by a traverser. Paths are
+// immutable and share their common prefix.
+type pathNode struct {
+	parent *pathNode
+	obj    any
+	labels []string
+	size   int
+}
+
+// memPath is the materialized path of a traverser.
+type memPath struct {
+	labels  [][]string
+	objects []any
+}
+
+// materialize returns the objects and labels of the path.
+func (p *pathNode) materialize() memPath {
+	if p == nil {
+		return memPath{labels: [][]string{}, objects: []any{}}
+	}
+	path := memPath{
+		labels:  make([][]string, p.size),
+		objects: make([]any, p.size),
+	}
+	for n := p; n != nil; n = n.parent {
+		labels := n.labels
+		if labels == nil {
+			labels = []string{}
+		}
+		path.labels[n.size-1] = labels
+		path.objects[n.size-1] = n.obj
+	}
+	return path
+}
+
+// traverser represents an object being processed by a traversal along with
+// the path followed to reach it.
+type traverser struct {
+	obj   any
+	path  *pathNode
+	loops int
+}
+
+// split returns a traverser with obj that extends the path of t.
+func (t *traverser) split(obj any) *traverser {
+	size := 1
+	if t.path != nil {
+		size = t.path.size + 1
+	}
+	return &traverser{
+		obj:   obj,
+		path:  &pathNode{parent: t.path, obj: obj, size: size},
+		loops: t.loops,
+	}
+}
+
+// root is the object of the traverser that starts a traversal.
+type root struct{}
+
+// isRoot reports whether t is the traverser that starts a traversal.
+func (t *traverser) isRoot() bool {
+	_, ok := t.obj.(root)
+	return ok
+}
+
+// evaluator evaluates traversals against a graph.
+type evaluator struct {
+	ctx context.Context
+	g   *memGraph
+}
+
+// eval evaluates a traversal and returns its results.
+func (ev evaluator) eval(t *memTraversal) ([]any, error) {
+	ts, err := ev.run(t, []*traverser{{obj: root{}}})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []any
+	for _, t := range ts {
+		if t.isRoot() {
+			continue
+		}
+		results = append(results, t.obj)
+	}
+	return results, nil
+}
+
+// run evaluates a traversal starting from the provided traversers.
+func (ev evaluator) run(t *memTraversal, ts []*traverser) ([]*traverser, error) {
+	for _, s := range t.steps {
+		if err := ev.ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var err error
+		if ts, err = ev.step(s, ts); err != nil {
+			return nil, fmt.Errorf("%v(): %w", s.name, err)
+		}
+	}
+	return ts, nil
+}
+
+// first returns the first object returned by a traversal evaluated from t.
+// If the traversal returns no values, it returns errNoValue.
+func (ev evaluator) first(nt *memTraversal, t *traverser) (any, error) {
+	ts, err := ev.run(nt, []*traverser{t})
+	if err != nil {
+		return nil, err
+	}
+	if len(ts) == 0 {
+		return nil, errNoValue
+	}
+	return ts[0].obj, nil
+}
+
+// any reports whether a traversal evaluated from t returns any value.
+func (ev evaluator) any(nt *memTraversal, t *traverser) (bool, error) {
+	ts, err := ev.run(nt, []*traverser{t})
+	if err != nil {
+		return false, err
+	}
+	return len(ts) > 0, nil
+}
+
+// step evaluates a step.
+func (ev evaluator) step(s *memStep, ts []*traverser) ([]*traverser, error) {
+	switch s.name {
+	// Start steps.
+	case "V":
+		return flatMap(ts, func(t *traverser) ([]any, error) { return ev.vertices(s.args), nil })
+	case "E":
+		return flatMap(ts, func(t *traverser) ([]any, error) { return ev.edges(s.args), nil })
+	case "inject":
+		return ev.inject(s, ts), nil
+
+	// Mutation steps.
+	case "addV":
+		return ev.addV(s, ts)
+	case "addE":
+		return ev.addE(s, ts)
+	case "property":
+		return ev.property(s, ts)
+	case "drop":
+		ev.drop(ts)
+		return nil, nil
+	case "none":
+		return nil, nil
+
+	// Filter steps.
+	case "has":
+		return filter(ts, func(t *traverser) (bool, error) { return has(t.obj, s.args) })
+	case "hasLabel":
+		return filter(ts, func(t *traverser) (bool, error) { return hasToken(t.obj, "label", s.args), nil })
+	case "hasId":
+		return filter(ts, func(t *traverser) (bool, error) { return hasToken(t.obj, "id", s.args), nil })
+	case "hasNot":
+		return filter(ts, func(t *traverser) (bool, error) {
+			ok, err := has(t.obj, s.args)
+			return !ok, err
+		})
+	case "is":
+		if len(s.args) != 1 {
+			return nil, errors.New("invalid arguments")
+		}
+		return filter(ts, func(t *traverser) (bool, error) { return test(t.obj, s.args[0]), nil })
+	case "where", "and", "or", "not":
+		return ev.logical(s, ts)
+	case "simplePath", "cyclicPath":
+		return filter(ts, func(t *traverser) (bool, error) {
+			return isSimplePath(t.path) == (s.name == "simplePath"), nil
+		})
+	case "dedup":
+		return ev.dedup(s, ts)
+	case "limit", "range", "skip", "tail":
+		return rangeStep(s, ts)
+
+	// Map steps.
+	case "identity", "barrier":
+		return ts, nil
+	case "as":
+		return addLabels(s.args, ts)
+	case "id", "label":
+		return mapStep(ts, func(t *traverser) (any, error) { return tokenValue(t.obj, s.name) })
+	case "constant":
+		if len(s.args) != 1 {
+			return nil, errors.New("invalid arguments")
+		}
+		return mapStep(ts, func(t *traverser) (any, error) { return s.args[0], nil })
+	case "values":
+		return flatMap(ts, func(t *traverser) ([]any, error) { return values(t.obj, s.args), nil })
+	case "valueMap", "elementMap":
+		return mapStep(ts, func(t *traverser) (any, error) { return valueMap(t.obj, s.name, s.args) })
+	case "path":
+		return mapStep(ts, func(t *traverser) (any, error) { return t.path.materialize(), nil })
+	case "select":
+		return ev.selectStep(s, ts)
+	case "project":
+		return ev.project(s, ts)
+	case "count":
+		return count(s, ts), nil
+	case "fold":
+		l := []any{}
+		for _, t := range ts {
+			l = append(l, t.obj)
+		}
+		return []*traverser{(&traverser{obj: root{}}).split(l)}, nil
+	case "unfold":
+		return flatMap(ts, func(t *traverser) ([]any, error) { return unfold(t.obj), nil })
+	case "order":
+		return ev.order(s, ts)
+
+	// Vertex steps.
+	case "out", "in", "both", "outE", "inE", "bothE":
+		return flatMap(ts, func(t *traverser) ([]any, error) { return adjacent(t.obj, s.name, s.args), nil })
+	case "outV", "inV", "bothV", "otherV":
+		return flatMap(ts, func(t *traverser) ([]any, error) { return edgeVertices(t, s.name), nil })
+
+	// Branch steps.
+	case "union", "coalesce", "optional", "local":
+		return ev.branch(s, ts)
+	case "repeat":
+		return ev.repeat(s, ts)
+	}
+	return nil, errors.New("unsupported step")
+}
+
+// flatMap returns the traversers that result of extending every traverser
+// with the objects returned by f.
+func flatMap(ts []*traverser, f func(t *traverser) ([]any, error)) ([]*traverser, error) {
+	var out []*traverser
+	for _, t := range ts {
+		objs, err := f(t)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objs {
+			out = append(out, t.split(obj))
+		}
+	}
+	return out, nil
+}
+
+// mapStep returns the traversers that result of extending every traverser
+// with the object returned by f.
+func mapStep(ts []*traverser, f func(t *traverser) (any, error)) ([]*traverser, error) {
+	out := make([]*traverser, 0, len(ts))
+	for _, t := range ts {
+		obj, err := f(t)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t.split(obj))
+	}
+	return out, nil
+}
+
+// filter returns the traversers for which f returns true.
+func filter(ts []*traverser, f func(t *traverser) (bool, error)) ([]*traverser, error) {
+	var out []*traverser
+	for _, t := range ts {
+		ok, err := f(t)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// flatten returns args with the lists they contain expanded.
+func flatten(args []any) []any {
+	var out []any
+	for _, arg := range args {
+		if l, ok := arg.([]any); ok {
+			out = append(out, flatten(l)...)
+			continue
+		}
+		out = append(out, arg)
+	}
+	return out
+}
+
+// vertices returns the vertices with the provided IDs or all the vertices
+// if no IDs are provided.
+func (ev evaluator) vertices(ids []any) []any {
+	var out []any
+	if len(ids) == 0 {
+		for _, v := range ev.g.vertices {
+			out = append(out, v)
+		}
+		return out
+	}
+	for _, id := range flatten(ids) {
+		if v := ev.g.vindex[normalizeID(id)]; v != nil {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// edges returns the edges with the provided IDs or all the edges if no IDs
+// are provided.
+func (ev evaluator) edges(ids []any) []any {
+	var out []any
+	if len(ids) == 0 {
+		for _, e := range ev.g.edges {
+			out = append(out, e)
+		}
+		return out
+	}
+	for _, id := range flatten(ids) {
+		if e := ev.g.eindex[normalizeID(id)]; e != nil {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// inject returns the injected values followed by the traversers that are
+// not the root traverser.
+func (ev evaluator) inject(s *memStep, ts []*traverser) []*traverser {
+	r := &traverser{obj: root{}}
+	var out []*traverser
+	for _, arg := range s.args {
+		out = append(out, r.split(arg))
+	}
+	for _, t := range ts {
+		if !t.isRoot() {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// addV adds a vertex for every traverser.
+func (ev evaluator) addV(s *memStep, ts []*traverser) ([]*traverser, error) {
+	label := "vertex"
+	if len(s.args) > 0 {
+		l, ok := s.args[0].(string)
+		if !ok {
+			return nil, errors.New("label is not a string")
+		}
+		label = l
+	}
+	return mapStep(ts, func(t *traverser) (any, error) { return ev.g.addVertex(nil, label) })
+}
+
+// addE adds an edge for every traverser. The vertices of the edge are set
+// by the from() and to() modulators. By default, the object of the
+// traverser is used.
+func (ev evaluator) addE(s *memStep, ts []*traverser) ([]*traverser, error) {
+	if len(s.args) != 1 {
+		return nil, errors.New("invalid arguments")
+	}
+	label, ok := s.args[0].(string)
+	if !ok {
+		return nil, errors.New("label is not a string")
+	}
+
+	var from, to []any
+	for _, mod := range s.mods {
+		switch mod.name {
+		case "from":
+			from = mod.args
+		case "to":
+			to = mod.args
+		}
+	}
+
+	return mapStep(ts, func(t *traverser) (any, error) {
+		out, err := ev.endpoint(t, from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid out vertex: %w", err)
+		}
+		in, err := ev.endpoint(t, to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid in vertex: %w", err)
+		}
+		return ev.g.addEdge(nil, label, out, in)
+	})
+}
+
+// endpoint returns the vertex selected by the arguments of a from() or
+// to() modulator.
+func (ev evaluator) endpoint(t *traverser, args []any) (*memVertex, error) {
+	obj := t.obj
+	if len(args) == 1 {
+		var err error
+		switch arg := args[0].(type) {
+		case string:
+			var ok bool
+			if obj, ok = selectValue(t, arg, "last"); !ok {
+				return nil, fmt.Errorf("unknown step label %q", arg)
+			}
+		case *memTraversal:
+			if obj, err = ev.first(arg, t); err != nil {
+				return nil, err
+			}
+		default:
+			obj = ev.g.vindex[normalizeID(arg)]
+		}
+	}
+
+	v, ok := obj.(*memVertex)
+	if !ok || v == nil {
+		return nil, errors.New("not a vertex")
+	}
+	return v, nil
+}
+
+// property sets a property of the element of every traverser.
+func (ev evaluator) property(s *memStep, ts []*traverser) ([]*traverser, error) {
+	args := s.args
+	cardinality := "single"
+	if len(args) > 0 {
+		if tok, ok := args[0].(token); ok && tok.kind == gbCardinality {
+			cardinality = tok.name
+			args = args[1:]
+		}
+	}
+	if len(args) < 2 {
+		return nil, errors.New("invalid arguments")
+	}
+
+	for _, t := range ts {
+		value := args[1]
+		if nt, ok := value.(*memTraversal); ok {
+			var err error
+			if value, err = ev.first(nt, t); err != nil {
+				return nil, err
+			}
+		}
+
+		switch key := args[0].(type) {
+		case token:
+			v, ok := t.obj.(*memVertex)
+			if !ok || key.name != "id" {
+				return nil, fmt.Errorf("cannot set %v", key.name)
+			}
+			if err := ev.g.setVertexID(v, value); err != nil {
+				return nil, err
+			}
+		case string:
+			switch e := t.obj.(type) {
+			case *memVertex:
+				if cardinality == "set" && containsValue(propertyValues(e.props, key), value) {
+					continue
+				}
+				e.props = setProperty(e.props, key, value, cardinality != "single")
+			case *memEdge:
+				e.props = setProperty(e.props, key, value, false)
+			default:
+				return nil, errors.New("not an element")
+			}
+		default:
+			return nil, errors.New("invalid property key")
+		}
+	}
+	return ts, nil
+}
+
+// drop removes the elements of the traversers from the graph.
+func (ev evaluator) drop(ts []*traverser) {
+	var (
+		vertices []*memVertex
+		edges    []*memEdge
+	)
+	for _, t := range ts {
+		switch e := t.obj.(type) {
+		case *memVertex:
+			vertices = append(vertices, e)
+		case *memEdge:
+			edges = append(edges, e)
+		}
+	}
+	ev.g.remove(vertices, edges)
+}
+
+// elementProperties returns the properties of a vertex or an edge.
+func elementProperties(obj any) ([]memProperty, bool) {
+	switch e := obj.(type) {
+	case *memVertex:
+		return e.props, true
+	case *memEdge:
+		return e.props, true
+	}
+	return nil, false
+}
+
+// tokenValue returns the ID or the label of an element.
+func tokenValue(obj any, name string) (any, error) {
+	switch e := obj.(type) {
+	case *memVertex:
+		if name == "id" {
+			return e.id, nil
+		}
+		return e.label, nil
+	case *memEdge:
+		if name == "id" {
+			return e.id, nil
+		}
+		return e.label, nil
+	}
+	return nil, fmt.Errorf("%T is not an element", obj)
+}
+
+// has evaluates the arguments of a has() step against obj.
+func has(obj any, args []any) (bool, error) {
+	props, ok := elementProperties(obj)
+	if !ok {
+		return false, nil
+	}
+
+	switch len(args) {
+	case 1:
+		if _, ok := args[0].(token); ok {
+			return true, nil
+		}
+		key, ok := args[0].(string)
+		if !ok {
+			return false, errors.New("invalid property key")
+		}
+		return len(propertyValues(props, key)) > 0, nil
+	case 2:
+		if tok, ok := args[0].(token); ok {
+			v, err := tokenValue(obj, tok.name)
+			if err != nil {
+				return false, err
+			}
+			return test(v, args[1]), nil
+		}
+		key, ok := args[0].(string)
+		if !ok {
+			return false, errors.New("invalid property key")
+		}
+		for _, v := range propertyValues(props, key) {
+			if test(v, args[1]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case 3:
+		if !hasToken(obj, "label", args[:1]) {
+			return false, nil
+		}
+		return has(obj, args[1:])
+	}
+	return false, errors.New("invalid arguments")
+}
+
+// hasToken reports whether the ID or the label of obj matches any of the
+// provided values or predicates.
+func hasToken(obj any, name string, args []any) bool {
+	v, err := tokenValue(obj, name)
+	if err != nil {
+		return false
+	}
+	for _, arg := range flatten(args) {
+		if test(v, arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// logical evaluates the where(), and(), or() and not() steps.
+func (ev evaluator) logical(s *memStep, ts []*traverser) ([]*traverser, error) {
+	var nts []*memTraversal
+	for _, arg := range s.args {
+		nt, ok := arg.(*memTraversal)
+		if !ok {
+			return nil, errors.New("only traversal arguments are supported")
+		}
+		nts = append(nts, nt)
+	}
+	if s.name != "and" && s.name != "or" && len(nts) != 1 {
+		return nil, errors.New("invalid arguments")
+	}
+
+	return filter(ts, func(t *traverser) (bool, error) {
+		for _, nt := range nts {
+			ok, err := ev.any(nt, t)
+			if err != nil {
+				return false, err
+			}
+			switch {
+			case s.name == "not":
+				return !ok, nil
+			case s.name == "or" && ok:
+				return true, nil
+			case s.name != "or" && !ok:
+				return false, nil
+			}
+		}
+		return s.name != "or", nil
+	})
+}
+
+// isSimplePath reports whether the path does not contain repeated
+// objects.
+func isSimplePath(p *pathNode) bool {
+	seen := make(map[any]bool)
+	for n := p; n != nil; n = n.parent {
+		k := hashKey(n.obj)
+		if seen[k] {
+			return false
+		}
+		seen[k] = true
+	}
+	return true
+}
+
+// dedup removes the repeated traversers. The by() modulator sets the value
+// compared.
+func (ev evaluator) dedup(s *memStep, ts []*traverser) ([]*traverser, error) {
+	if len(s.args) > 0 {
+		return nil, errors.New("arguments are not supported")
+	}
+
+	seen := make(map[any]bool)
+	return filter(ts, func(t *traverser) (bool, error) {
+		v := t.obj
+		if len(s.mods) > 0 {
+			var err error
+			if v, err = ev.by(t, s.mods[0].args); err != nil {
+				return false, err
+			}
+		}
+		k := hashKey(v)
+		if seen[k] {
+			return false, nil
+		}
+		seen[k] = true
+		return true, nil
+	})
+}
+
+// rangeStep evaluates the limit(), range(), skip() and tail() steps with
+// global scope.
+func rangeStep(s *memStep, ts []*traverser) ([]*traverser, error) {
+	args := s.args
+	if len(args) > 0 {
+		if tok, ok := args[0].(token); ok {
+			if tok.kind != gbScope || tok.name != "global" {
+				return nil, errors.New("only global scope is supported")
+			}
+			args = args[1:]
+		}
+	}
+
+	var ns []int64
+	for _, arg := range args {
+		n, ok := toInt(arg)
+		if !ok {
+			return nil, errors.New("invalid arguments")
+		}
+		ns = append(ns, n)
+	}
+
+	lo, hi := int64(0), int64(-1)
+	switch {
+	case s.name == "limit" && len(ns) == 1:
+		hi = ns[0]
+	case s.name == "range" && len(ns) == 2:
+		lo, hi = ns[0], ns[1]
+	case s.name == "skip" && len(ns) == 1:
+		lo = ns[0]
+	case s.name == "tail" && len(ns) <= 1:
+		n := int64(1)
+		if len(ns) == 1 {
+			n = ns[0]
+		}
+		lo = int64(len(ts)) - n
+	default:
+		return nil, errors.New("invalid arguments")
+	}
+
+	n := int64(len(ts))
+	if lo < 0 {
+		lo = 0
+	}
+	if lo > n {
+		lo = n
+	}
+	if hi < 0 || hi > n {
+		hi = n
+	}
+	if hi < lo {
+		hi = lo
+	}
+	return ts[lo:hi], nil
+}
+
+// addLabels adds step labels to the last object of the path of every
+// traverser.
+func addLabels(args []any, ts []*traverser) ([]*traverser, error) {
+	var labels []string
+	for _, arg := range args {
+		l, ok := arg.(string)
+		if !ok {
+			return nil, errors.New("step label is not a string")
+		}
+		labels = append(labels, l)
+	}
+
+	out := make([]*traverser, 0, len(ts))
+	for _, t := range ts {
+		if t.path == nil {
+			return nil, errors.New("no step to label")
+		}
+		p := *t.path
+		p.labels = append(append([]string(nil), p.labels...), labels...)
+		out = append(out, &traverser{obj: t.obj, path: &p, loops: t.loops})
+	}
+	return out, nil
+}
+
+// values returns the values of the provided properties of an element. If
+// no properties are provided, the values of all of them are returned.
+func values(obj any, keys []any) []any {
+	props, _ := elementProperties(obj)
+	var out []any
+	for _, p := range props {
+		if len(keys) == 0 || containsValue(keys, p.key) {
+			out = append(out, p.value)
+		}
+	}
+	return out
+}
+
+// valueMap returns the properties of an element as a map. The values of
+// the vertex properties are lists, unless elementMap() is used.
+func valueMap(obj any, name string, args []any) (any, error) {
+	props, ok := elementProperties(obj)
+	if !ok {
+		return nil, fmt.Errorf("%T is not an element", obj)
+	}
+
+	tokens := name == "elementMap"
+	if len(args) > 0 {
+		if b, ok := args[0].(bool); ok {
+			tokens = b
+			args = args[1:]
+		}
+	}
+
+	_, isVertex := obj.(*memVertex)
+	m := make(map[any]any)
+	for _, p := range props {
+		if len(args) > 0 && !containsValue(args, p.key) {
+			continue
+		}
+		if !isVertex || name == "elementMap" {
+			m[p.key] = p.value
+			continue
+		}
+		l, _ := m[p.key].([]any)
+		m[p.key] = append(l, p.value)
+	}
+
+	if tokens {
+		id, _ := tokenValue(obj, "id")
+		label, _ := tokenValue(obj, "label")
+		m[token{kind: gbT, name: "id"}] = id
+		m[token{kind: gbT, name: "label"}] = label
+	}
+	return m, nil
+}
+
+// selectValue returns the value of a map key or the object labeled with a
+// step label in the path of a traverser, according to pop.
+func selectValue(t *traverser, key string, pop string) (any, bool) {
+	if m, ok := t.obj.(map[any]any); ok {
+		if v, ok := m[key]; ok {
+			return v, true
+		}
+	}
+
+	var objs []any
+	for n := t.path; n != nil; n = n.parent {
+		if containsString(n.labels, key) {
+			objs = append([]any{n.obj}, objs...)
+		}
+	}
+	if len(objs) == 0 {
+		return nil, false
+	}
+
+	switch pop {
+	case "first":
+		return objs[0], true
+	case "all":
+		return objs, true
+	case "mixed":
+		if len(objs) == 1 {
+			return objs[0], true
+		}
+		return objs, true
+	}
+	return objs[len(objs)-1], true
+}
+
+// selectStep evaluates the select() step. Traversers without any of the
+// selected keys are filtered out.
+func (ev evaluator) selectStep(s *memStep, ts []*traverser) ([]*traverser, error) {
+	args := s.args
+	pop := "last"
+	if len(args) > 0 {
+		if tok, ok := args[0].(token); ok && tok.kind == gbPop {
+			pop = tok.name
+			args = args[1:]
+		}
+	}
+
+	var keys []string
+	for _, arg := range args {
+		k, ok := arg.(string)
+		if !ok {
+			return nil, errors.New("only string keys are supported")
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("missing keys")
+	}
+
+	var out []*traverser
+	for _, t := range ts {
+		m := make(map[any]any)
+		var v any
+		found := true
+		for i, k := range keys {
+			sv, ok := selectValue(t, k, pop)
+			if !ok {
+				found = false
+				break
+			}
+			if len(s.mods) > 0 {
+				var err error
+				st := &traverser{obj: sv, path: t.path, loops: t.loops}
+				if sv, err = ev.by(st, s.mods[i%len(s.mods)].args); err != nil {
+					return nil, err
+				}
+			}
+			m[k] = sv
+			v = sv
+		}
+		if !found {
+			continue
+		}
+		if len(keys) > 1 {
+			v = m
+		}
+		out = append(out, t.split(v))
+	}
+	return out, nil
+}
+
+// project evaluates the project() step. The by() modulators are applied in
+// order to every key.
+func (ev evaluator) project(s *memStep, ts []*traverser) ([]*traverser, error) {
+	return mapStep(ts, func(t *traverser) (any, error) {
+		m := make(map[any]any, len(s.args))
+		for i, k := range s.args {
+			var args []any
+			if len(s.mods) > 0 {
+				args = s.mods[i%len(s.mods)].args
+			}
+			v, err := ev.by(t, args)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	})
+}
+
+// by returns the value of a traverser modulated by the arguments of a by()
+// modulator.
+func (ev evaluator) by(t *traverser, args []any) (any, error) {
+	if len(args) == 0 {
+		return t.obj, nil
+	}
+
+	switch arg := args[0].(type) {
+	case *memTraversal:
+		return ev.first(arg, t)
+	case token:
+		if arg.kind == gbOrder {
+			return t.obj, nil
+		}
+		return tokenValue(t.obj, arg.name)
+	case string:
+		if m, ok := t.obj.(map[any]any); ok {
+			v, ok := m[arg]
+			if !ok {
+				return nil, errNoValue
+			}
+			return v, nil
+		}
+		props, ok := elementProperties(t.obj)
+		if !ok {
+			return nil, fmt.Errorf("%T is not an element", t.obj)
+		}
+		vs := propertyValues(props, arg)
+		if len(vs) == 0 {
+			return nil, fmt.Errorf("the property %q does not exist", arg)
+		}
+		return vs[0], nil
+	}
+	return nil, errors.New("unsupported by() modulator")
+}
+
+// count evaluates the count() step.
+func count(s *memStep, ts []*traverser) []*traverser {
+	if len(s.args) == 1 {
+		if tok, ok := s.args[0].(token); ok && tok.name == "local" {
+			out := make([]*traverser, 0, len(ts))
+			for _, t := range ts {
+				out = append(out, t.split(int64(len(unfold(t.obj)))))
+			}
+			return out
+		}
+	}
+	return []*traverser{(&traverser{obj: root{}}).split(int64(len(ts)))}
+}
+
+// unfold returns the elements of a collection. Other objects are returned
+// as is.
+func unfold(obj any) []any {
+	switch v := obj.(type) {
+	case []any:
+		return v
+	case memPath:
+		return v.objects
+	case map[any]any:
+		var out []any
+		for k, e := range v {
+			out = append(out, map[any]any{k: e})
+		}
+		return out
+	}
+	return []any{obj}
+}
+
+// order sorts the traversers. The by() modulators set the values compared
+// and the order.
+func (ev evaluator) order(s *memStep, ts []*traverser) ([]*traverser, error) {
+	if len(s.args) > 0 {
+		if tok, ok := s.args[0].(token); !ok || tok.name != "global" {
+			return nil, errors.New("only global scope is supported")
+		}
+	}
+
+	mods := s.mods
+	if len(mods) == 0 {
+		mods = []instruction{{name: "by"}}
+	}
+
+	type sortKey struct {
+		values []any
+		t      *traverser
+	}
+	keys := make([]sortKey, 0, len(ts))
+	var desc []bool
+	for i, mod := range mods {
+		args := mod.args
+		order := "asc"
+		if n := len(args); n > 0 {
+			if tok, ok := args[n-1].(token); ok && tok.kind == gbOrder {
+				order = tok.name
+				if n > 1 {
+					args = args[:n-1]
+				}
+			}
+		}
+		if order == "shuffle" {
+			rand.Shuffle(len(ts), func(i, j int) { ts[i], ts[j] = ts[j], ts[i] })
+			continue
+		}
+		desc = append(desc, order == "desc" || order == "decr")
+
+		for j, t := range ts {
+			v, err := ev.by(t, args)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				keys = append(keys, sortKey{t: t})
+			}
+			keys[j].values = append(keys[j].values, v)
+		}
+	}
+	if len(keys) == 0 {
+		return ts, nil
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		for k := range desc {
+			c := compareOrder(keys[i].values[k], keys[j].values[k])
+			if c == 0 {
+				continue
+			}
+			if desc[k] {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+
+	out := make([]*traverser, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, k.t)
+	}
+	return out, nil
+}
+
+// adjacent returns the adjacent vertices or edges of a vertex through the
+// edges with the provided labels.
+func adjacent(obj any, name string, labels []any) []any {
+	v, ok := obj.(*memVertex)
+	if !ok {
+		return nil
+	}
+
+	var edges []*memEdge
+	switch name {
+	case "out", "outE":
+		edges = v.out
+	case "in", "inE":
+		edges = v.in
+	default:
+		edges = append(append(edges, v.out...), v.in...)
+	}
+
+	var out []any
+	for _, e := range edges {
+		if len(labels) > 0 && !containsValue(labels, e.label) {
+			continue
+		}
+		switch name {
+		case "outE", "inE", "bothE":
+			out = append(out, e)
+		case "out":
+			out = append(out, e.in)
+		case "in":
+			out = append(out, e.out)
+		default:
+			if e.out == v {
+				out = append(out, e.in)
+			} else {
+				out = append(out, e.out)
+			}
+		}
+	}
+	return out
+}
+
+// edgeVertices returns the vertices of the edge of a traverser.
+func edgeVertices(t *traverser, name string) []any {
+	e, ok := t.obj.(*memEdge)
+	if !ok {
+		return nil
+	}
+
+	switch name {
+	case "outV":
+		return []any{e.out}
+	case "inV":
+		return []any{e.in}
+	case "bothV":
+		return []any{e.out, e.in}
+	}
+
+	// otherV returns the vertex of the edge that is not the previous
+	// object in the path.
+	if t.path != nil && t.path.parent != nil && t.path.parent.obj == e.out {
+		return []any{e.in}
+	}
+	return []any{e.out}
+}
+
+// branch evaluates the union(), coalesce(), optional() and local() steps.
+func (ev evaluator) branch(s *memStep, ts []*traverser) ([]*traverser, error) {
+	var nts []*memTraversal
+	for _, arg := range s.args {
+		nt, ok := arg.(*memTraversal)
+		if !ok {
+			return nil, errors.New("only traversal arguments are supported")
+		}
+		nts = append(nts, nt)
+	}
+	if s.name != "union" && s.name != "coalesce" && len(nts) != 1 {
+		return nil, errors.New("invalid arguments")
+	}
+
+	var out []*traverser
+	for _, t := range ts {
+		for _, nt := range nts {
+			res, err := ev.run(nt, []*traverser{t})
+			if err != nil {
+				return nil, err
+			}
+
+			switch s.name {
+			case "union":
+				out = append(out, res...)
+				continue
+			case "optional":
+				if len(res) == 0 {
+					out = append(out, t)
+				}
+				out = append(out, res...)
+				continue
+			}
+
+			// coalesce() and local() are flat map steps, so the
+			// path is extended only with their results.
+			for _, r := range res {
+				out = append(out, t.split(r.obj))
+			}
+			if len(res) > 0 {
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// repeat evaluates the repeat() step along with its times(), emit() and
+// until() modulators. Traversers are processed in breadth-first order.
+func (ev evaluator) repeat(s *memStep, ts []*traverser) ([]*traverser, error) {
+	if len(s.args) != 1 {
+		return nil, errors.New("invalid arguments")
+	}
+	body, ok := s.args[0].(*memTraversal)
+	if !ok {
+		return nil, errors.New("invalid arguments")
+	}
+
+	var (
+		times                 int64
+		emit, until           []any
+		emitSet, untilSet     bool
+		emitFirst, untilFirst bool
+	)
+	parse := func(mods []instruction, first bool) error {
+		for _, mod := range mods {
+			switch mod.name {
+			case "times":
+				if len(mod.args) != 1 {
+					return errors.New("invalid times() arguments")
+				}
+				n, ok := toInt(mod.args[0])
+				if !ok {
+					return errors.New("invalid times() arguments")
+				}
+				times, untilSet, untilFirst = n, false, first
+			case "emit":
+				emit, emitSet, emitFirst = mod.args, true, first
+			case "until":
+				until, untilSet, untilFirst = mod.args, true, first
+			}
+		}
+		return nil
+	}
+	if err := parse(s.pre, true); err != nil {
+		return nil, err
+	}
+	if err := parse(s.mods, false); err != nil {
+		return nil, err
+	}
+
+	// check evaluates the condition of an emit() or until() modulator.
+	check := func(t *traverser, args []any) (bool, error) {
+		if len(args) == 0 {
+			return true, nil
+		}
+		switch arg := args[0].(type) {
+		case *memTraversal:
+			return ev.any(arg, t)
+		case predicate:
+			return arg.test(t.obj), nil
+		}
+		return false, errors.New("invalid condition")
+	}
+
+	// done reports whether the traverser must leave the loop.
+	done := func(t *traverser) (bool, error) {
+		if untilSet {
+			return check(t, until)
+		}
+		return times > 0 && int64(t.loops) >= times, nil
+	}
+
+	var out []*traverser
+	emitTraverser := func(t *traverser) {
+		out = append(out, &traverser{obj: t.obj, path: t.path})
+	}
+
+	for _, start := range ts {
+		queue := []*traverser{{obj: start.obj, path: start.path}}
+		for len(queue) > 0 {
+			if err := ev.ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			t := queue[0]
+			queue = queue[1:]
+
+			if untilFirst {
+				ok, err := done(t)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					emitTraverser(t)
+					continue
+				}
+			}
+			if emitSet && emitFirst {
+				ok, err := check(t, emit)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					emitTraverser(t)
+				}
+			}
+
+			res, err := ev.run(body, []*traverser{t})
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range res {
+				r = &traverser{obj: r.obj, path: r.path, loops: t.loops + 1}
+				if !untilFirst {
+					ok, err := done(r)
+					if err != nil {
+						return nil, err
+					}
+					if ok {
+						emitTraverser(r)
+						continue
+					}
+				}
+				if emitSet && !emitFirst {
+					ok, err := check(r, emit)
+					if err != nil {
+						return nil, err
+					}
+					if ok {
+						emitTraverser(r)
+					}
+				}
+				queue = append(queue, r)
+			}
+		}
+	}
+	return out, nil
+}
+
+// test evaluates a value or a predicate against v.
+func test(v any, cond any) bool {
+	if p, ok := cond.(predicate); ok {
+		return p.test(v)
+	}
+	return equalValues(v, cond)
+}
+
+// test evaluates the predicate against v.
+func (p predicate) test(v any) bool {
+	args := flatten(p.args)
+
+	arg := func(i int) any {
+		if i < len(args) {
+			return args[i]
+		}
+		return nil
+	}
+
+	cmp := func(i int) (int, bool) {
+		return compareValues(v, arg(i))
+	}
+
+	switch p.op {
+	case "eq":
+		return equalValues(v, arg(0))
+	case "neq":
+		return !equalValues(v, arg(0))
+	case "lt":
+		c, ok := cmp(0)
+		return ok && c < 0
+	case "lte":
+		c, ok := cmp(0)
+		return ok && c <= 0
+	case "gt":
+		c, ok := cmp(0)
+		return ok && c > 0
+	case "gte":
+		c, ok := cmp(0)
+		return ok && c >= 0
+	case "inside":
+		lo, ok1 := cmp(0)
+		hi, ok2 := cmp(1)
+		return ok1 && ok2 && lo > 0 && hi < 0
+	case "outside":
+		lo, ok1 := cmp(0)
+		hi, ok2 := cmp(1)
+		return ok1 && ok2 && (lo < 0 || hi > 0)
+	case "between":
+		lo, ok1 := cmp(0)
+		hi, ok2 := cmp(1)
+		return ok1 && ok2 && lo >= 0 && hi < 0
+	case "within":
+		return containsValue(args, v)
+	case "without":
+		return !containsValue(args, v)
+	case "and", "or":
+		for _, a := range args {
+			sub, ok := a.(predicate)
+			if !ok {
+				return false
+			}
+			if sub.test(v) == (p.op == "or") {
+				return p.op == "or"
+			}
+		}
+		return p.op == "and"
+	case "not":
+		sub, ok := arg(0).(predicate)
+		return ok && !sub.test(v)
+	}
+
+	s, ok1 := v.(string)
+	a, ok2 := arg(0).(string)
+	if !ok1 || !ok2 {
+		return false
+	}
+	switch p.op {
+	case "startingWith":
+		return strings.HasPrefix(s, a)
+	case "notStartingWith":
+		return !strings.HasPrefix(s, a)
+	case "endingWith":
+		return strings.HasSuffix(s, a)
+	case "notEndingWith":
+		return !strings.HasSuffix(s, a)
+	case "containing":
+		return strings.Contains(s, a)
+	case "notContaining":
+		return !strings.Contains(s, a)
+	case "regex", "notRegex":
+		re, err := regexp.Compile(a)
+		if err != nil {
+			return false
+		}
+		return re.MatchString(s) == (p.op == "regex")
+	}
+	return false
+}
+
+// toInt returns v as an int64 if it is an integer.
+func toInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint8:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+// toFloat returns v as a float64 if it is a number.
+func toFloat(v any) (float64, bool) {
+	if n, ok := toInt(v); ok {
+		return float64(n), true
+	}
+	switch n := v.(type) {
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// compareValues compares two values of the same kind. Numbers of different
+// types are compared by value. It returns false if the values cannot be
+// compared.
+func compareValues(a, b any) (int, bool) {
+	if ia, ok := toInt(a); ok {
+		if ib, ok := toInt(b); ok {
+			return compareOrdered(ia, ib), true
+		}
+	}
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			return compareOrdered(fa, fb), true
+		}
+		return 0, false
+	}
+
+	switch va := a.(type) {
+	case string:
+		if vb, ok := b.(string); ok {
+			return compareOrdered(va, vb), true
+		}
+	case bool:
+		if vb, ok := b.(bool); ok {
+			switch {
+			case va == vb:
+				return 0, true
+			case !va:
+				return -1, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		if vb, ok := b.(time.Time); ok {
+			return compareOrdered(va.UnixNano(), vb.UnixNano()), true
+		}
+	case *memVertex, *memEdge:
+		ida, _ := tokenValue(a, "id")
+		idb, err := tokenValue(b, "id")
+		if err != nil {
+			return 0, false
+		}
+		return compareValues(ida, idb)
+	}
+	return 0, false
+}
+
+// compareOrdered compares two ordered values.
+func compareOrdered[T int64 | float64 | string](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareOrder compares two values in order to sort them. Values that
+// cannot be compared are sorted by type.
+func compareOrder(a, b any) int {
+	if c, ok := compareValues(a, b); ok {
+		return c
+	}
+	return compareOrdered(orderRank(a), orderRank(b))
+}
+
+// orderRank returns the relative order of the type of a value.
+func orderRank(v any) string {
+	if v == nil {
+		return "0"
+	}
+	if _, ok := toFloat(v); ok {
+		return "1"
+	}
+	return fmt.Sprintf("2%T", v)
+}
+
+// equalValues reports whether two values are equal. Numbers of different
+// types are compared by value.
+func equalValues(a, b any) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+	return hashKey(a) == hashKey(b)
+}
+
+// containsValue reports whether l contains a value equal to v.
+func containsValue(l []any, v any) bool {
+	for _, e := range l {
+		if equalValues(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString reports whether l contains s.
+func containsString(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// hashKey returns a comparable value that identifies v, so it can be used
+// as a map key. Equal numbers of different types have the same key.
+func hashKey(v any) any {
+	if n, ok := toInt(v); ok {
+		return n
+	}
+	switch e := v.(type) {
+	case float64:
+		if e == float64(int64(e)) {
+			return int64(e)
+		}
+		return e
+	case float32:
+		return hashKey(float64(e))
+	case nil, string, bool, *memVertex, *memEdge, [16]byte, token:
+		return v
+	case time.Time:
+		return e.UnixNano()
+	case vertexRef:
+		return fmt.Sprintf("v[%v]", normalizeID(e.id))
+	}
+	return fmt.Sprintf("%T%v", v, v)
+}
@@ -0,0 +1,166 @@
+package gremlin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adevinta/graph-intel-api/log"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// emptyStringSHA256 is the hex encoded sha256 value of an empty string.
+const emptyStringSHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+const (
+	// neptuneExpiryWindow is the time before the AWS credentials expire
+	// when they are considered expired and refreshed.
+	neptuneExpiryWindow = 5 * time.Minute
+
+	// neptuneSignatureMaxAge is the maximum age of the cached auth
+	// headers. Neptune rejects SigV4 signatures older than 5 minutes,
+	// so the headers must be signed again even if the credentials are
+	// still valid.
+	neptuneSignatureMaxAge = 4 * time.Minute
+
+	// neptuneRoleSessionName is the session name used when assuming
+	// [Config.AWSRoleARN].
+	neptuneRoleSessionName = "graph-intel-api"
+)
+
+// neptuneAuth creates connections with Neptune authenticated using IAM. The
+// AWS config and credentials provider are loaded once and the auth headers
+// are cached until they have to be signed again. A neptuneAuth is safe for
+// concurrent use.
+type neptuneAuth struct {
+	cfg Config
+
+	// now returns the current time.
+	now func() time.Time
+
+	mu       sync.Mutex
+	provider aws.CredentialsProvider
+	creds    aws.Credentials
+	header   http.Header
+	signedAt time.Time
+}
+
+// newNeptuneAuth returns a [neptuneAuth] for the provided configuration.
+func newNeptuneAuth(cfg Config) *neptuneAuth {
+	return &neptuneAuth{
+		cfg: cfg,
+		now: time.Now,
+	}
+}
+
+// connect is a [connHandler] for Neptune that creates an authenticated
+// connection using IAM.
+func (na *neptuneAuth) connect(ctx context.Context, cfg Config) (*gremlingo.DriverRemoteConnection, error) {
+	auth, err := na.getHeader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting AWS auth headers: %v", err)
+	}
+
+	tlsCfg, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error loading TLS config: %w", err)
+	}
+
+	log.FromContext(ctx).Debug.Printf("graph-intel-api: gremlin: connecting to Neptune")
+	conn, err := gremlingo.NewDriverRemoteConnection(cfg.Endpoint, func(settings *gremlingo.DriverRemoteConnectionSettings) {
+		settings.AuthInfo = gremlingo.HeaderAuthInfo(auth)
+		settings.TlsConfig = tlsCfg
+		settings.LogVerbosity = gremlingo.Off
+	})
+	return conn, err
+}
+
+// getHeader returns the AWS auth headers required to interact with Neptune.
+// The cached headers are signed again if they are older than
+// [neptuneSignatureMaxAge] or the credentials used to sign them are about to
+// expire.
+func (na *neptuneAuth) getHeader(ctx context.Context) (http.Header, error) {
+	na.mu.Lock()
+	defer na.mu.Unlock()
+
+	now := na.now()
+	if na.header != nil && !na.expired(now) {
+		return na.header.Clone(), nil
+	}
+
+	log.FromContext(ctx).Debug.Printf("graph-intel-api: gremlin: getting Neptune auth")
+
+	if na.provider == nil {
+		provider, err := na.loadProvider(ctx)
+		if err != nil {
+			return nil, err
+		}
+		na.provider = provider
+	}
+
+	creds, err := na.provider.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get AWS credentials: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", na.cfg.Endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+
+	signer := v4.NewSigner()
+	if err := signer.SignHTTP(ctx, creds, req, emptyStringSHA256, "neptune-db", na.cfg.AWSRegion, now); err != nil {
+		return nil, fmt.Errorf("could not sign request: %w", err)
+	}
+
+	na.creds = creds
+	na.header = req.Header
+	na.signedAt = now
+
+	return na.header.Clone(), nil
+}
+
+// expired reports whether the cached headers must be signed again.
+func (na *neptuneAuth) expired(now time.Time) bool {
+	if now.Sub(na.signedAt) >= neptuneSignatureMaxAge {
+		return true
+	}
+	return na.creds.CanExpire && !now.Add(neptuneExpiryWindow).Before(na.creds.Expires)
+}
+
+// loadProvider loads the default AWS config and returns its credentials
+// provider. If [Config.AWSRoleARN] is set, the returned provider assumes the
+// role using the default credentials.
+func (na *neptuneAuth) loadProvider(ctx context.Context) (aws.CredentialsProvider, error) {
+	awsCfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(na.cfg.AWSRegion),
+		config.WithCredentialsCacheOptions(func(o *aws.CredentialsCacheOptions) {
+			o.ExpiryWindow = neptuneExpiryWindow
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not load AWS config: %w", err)
+	}
+
+	if na.cfg.AWSRoleARN == "" {
+		return awsCfg.Credentials, nil
+	}
+
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(awsCfg), na.cfg.AWSRoleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = neptuneRoleSessionName
+		if na.cfg.AWSExternalID != "" {
+			o.ExternalID = aws.String(na.cfg.AWSExternalID)
+		}
+	})
+	return aws.NewCredentialsCache(provider, func(o *aws.CredentialsCacheOptions) {
+		o.ExpiryWindow = neptuneExpiryWindow
+	}), nil
+}
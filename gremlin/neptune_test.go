@@ -0,0 +1,138 @@
+package gremlin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// fakeProvider is an [aws.CredentialsProvider] that returns credentials
+// expiring at a fixed time and counts the number of retrievals.
+type fakeProvider struct {
+	expires   time.Time
+	err       error
+	retrieves int
+}
+
+func (p *fakeProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	p.retrieves++
+	if p.err != nil {
+		return aws.Credentials{}, p.err
+	}
+	return aws.Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		CanExpire:       !p.expires.IsZero(),
+		Expires:         p.expires,
+	}, nil
+}
+
+func TestNeptuneAuthGetHeader(t *testing.T) {
+	start := time.Date(2022, 11, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		expires       time.Time
+		elapsed       time.Duration
+		wantRetrieves int
+		wantDate      string
+	}{
+		{
+			name:          "cached",
+			expires:       time.Time{},
+			elapsed:       time.Minute,
+			wantRetrieves: 1,
+			wantDate:      "20221101T120000Z",
+		},
+		{
+			name:          "signature too old",
+			expires:       time.Time{},
+			elapsed:       neptuneSignatureMaxAge,
+			wantRetrieves: 2,
+			wantDate:      "20221101T120400Z",
+		},
+		{
+			name:          "credentials not expiring soon",
+			expires:       start.Add(time.Hour),
+			elapsed:       time.Minute,
+			wantRetrieves: 1,
+			wantDate:      "20221101T120000Z",
+		},
+		{
+			name:          "credentials expiring soon",
+			expires:       start.Add(neptuneExpiryWindow + 30*time.Second),
+			elapsed:       time.Minute,
+			wantRetrieves: 2,
+			wantDate:      "20221101T120100Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := start
+			provider := &fakeProvider{expires: tt.expires}
+
+			na := newNeptuneAuth(Config{
+				Endpoint:  "wss://neptune.example.com:8182/gremlin",
+				AWSRegion: "eu-west-1",
+			})
+			na.provider = provider
+			na.now = func() time.Time { return now }
+
+			header, err := na.getHeader(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if header.Get("Authorization") == "" {
+				t.Errorf("missing Authorization header")
+			}
+
+			// Modifying the returned header must not affect the
+			// cached one.
+			header.Del("Authorization")
+
+			now = now.Add(tt.elapsed)
+
+			header, err = na.getHeader(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if header.Get("Authorization") == "" {
+				t.Errorf("missing Authorization header")
+			}
+
+			if got := header.Get("X-Amz-Date"); got != tt.wantDate {
+				t.Errorf("unexpected X-Amz-Date: got=%v want=%v", got, tt.wantDate)
+			}
+
+			if provider.retrieves != tt.wantRetrieves {
+				t.Errorf("unexpected number of retrievals: got=%v want=%v", provider.retrieves, tt.wantRetrieves)
+			}
+		})
+	}
+}
+
+func TestNeptuneAuthGetHeader_Error(t *testing.T) {
+	errProvider := errors.New("provider error")
+	provider := &fakeProvider{err: errProvider}
+
+	na := newNeptuneAuth(Config{
+		Endpoint:  "wss://neptune.example.com:8182/gremlin",
+		AWSRegion: "eu-west-1",
+	})
+	na.provider = provider
+
+	// Errors must not be cached.
+	for i := 0; i < 2; i++ {
+		if _, err := na.getHeader(context.Background()); !errors.Is(err, errProvider) {
+			t.Errorf("unexpected error: got=%v want=%v", err, errProvider)
+		}
+	}
+
+	if provider.retrieves != 2 {
+		t.Errorf("unexpected number of retrievals: got=%v want=%v", provider.retrieves, 2)
+	}
+}
@@ -0,0 +1,296 @@
+package gremlin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	stdlog "log"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+)
+
+// testPKI contains the paths of the PEM files of a test CA, a server
+// certificate for "gremlin.example.com" and a client certificate, all of
+// them signed by the CA.
+type testPKI struct {
+	caFile         string
+	serverCertFile string
+	serverKeyFile  string
+	clientCertFile string
+	clientKeyFile  string
+}
+
+// newTestPKI generates a [testPKI] in a temporary directory.
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating CA key: %v", err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("error creating CA certificate: %v", err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("error parsing CA certificate: %v", err)
+	}
+
+	issue := func(serial int64, name string, usage x509.ExtKeyUsage) (certFile, keyFile string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("error generating key: %v", err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			DNSNames:     []string{name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("error creating certificate: %v", err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatalf("error marshaling key: %v", err)
+		}
+
+		certFile = writePEM(t, dir, name+".pem", "CERTIFICATE", der)
+		keyFile = writePEM(t, dir, name+"-key.pem", "EC PRIVATE KEY", keyDER)
+		return certFile, keyFile
+	}
+
+	pki := testPKI{caFile: writePEM(t, dir, "ca.pem", "CERTIFICATE", caDER)}
+	pki.serverCertFile, pki.serverKeyFile = issue(2, "gremlin.example.com", x509.ExtKeyUsageServerAuth)
+	pki.clientCertFile, pki.clientKeyFile = issue(3, "client", x509.ExtKeyUsageClientAuth)
+	return pki
+}
+
+// writePEM writes a PEM file with a single block and returns its path.
+func writePEM(t *testing.T, dir, name, typ string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("error writing %v: %v", name, err)
+	}
+	return path
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	pki := newTestPKI(t)
+
+	tests := []struct {
+		name       string
+		cfg        Config
+		wantRoots  bool
+		wantCerts  int
+		wantNilErr bool
+	}{
+		{
+			name:       "default",
+			cfg:        Config{},
+			wantRoots:  false,
+			wantCerts:  0,
+			wantNilErr: true,
+		},
+		{
+			name: "CA and client certificate",
+			cfg: Config{
+				TLSCAFile:   pki.caFile,
+				TLSCertFile: pki.clientCertFile,
+				TLSKeyFile:  pki.clientKeyFile,
+			},
+			wantRoots:  true,
+			wantCerts:  1,
+			wantNilErr: true,
+		},
+		{
+			name:       "missing CA file",
+			cfg:        Config{TLSCAFile: "testdata/missing.pem"},
+			wantNilErr: false,
+		},
+		{
+			name:       "invalid CA file",
+			cfg:        Config{TLSCAFile: "testdata/graph.json"},
+			wantNilErr: false,
+		},
+		{
+			name:       "certificate without key",
+			cfg:        Config{TLSCertFile: pki.clientCertFile},
+			wantNilErr: false,
+		},
+		{
+			name: "mismatched key",
+			cfg: Config{
+				TLSCertFile: pki.clientCertFile,
+				TLSKeyFile:  pki.serverKeyFile,
+			},
+			wantNilErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsCfg, err := newTLSConfig(tt.cfg)
+			if (err == nil) != tt.wantNilErr {
+				t.Fatalf("unexpected error: wantNilErr=%v, got=%v", tt.wantNilErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if got := tlsCfg.RootCAs != nil; got != tt.wantRoots {
+				t.Errorf("unexpected root CAs: got=%v want=%v", got, tt.wantRoots)
+			}
+			if got := len(tlsCfg.Certificates); got != tt.wantCerts {
+				t.Errorf("unexpected number of certificates: got=%v want=%v", got, tt.wantCerts)
+			}
+		})
+	}
+}
+
+func TestConnectionQuery_MutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+
+	serverCert, err := tls.LoadX509KeyPair(pki.serverCertFile, pki.serverKeyFile)
+	if err != nil {
+		t.Fatalf("error loading server certificate: %v", err)
+	}
+	serverTLSCfg, err := newTLSConfig(Config{TLSCAFile: pki.caFile})
+	if err != nil {
+		t.Fatalf("error loading CA: %v", err)
+	}
+
+	ts := httptest.NewUnstartedServer(&memoryServer{g: newMemGraph()})
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    serverTLSCfg.RootCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	ts.Config.ErrorLog = stdlog.New(io.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+
+	endpoint := strings.Replace(ts.URL, "https://", "wss://", 1) + "/gremlin"
+
+	tests := []struct {
+		name       string
+		cfg        Config
+		wantNilErr bool
+	}{
+		{
+			name: "client certificate",
+			cfg: Config{
+				Endpoint:      endpoint,
+				AuthMode:      "plain",
+				TLSCAFile:     pki.caFile,
+				TLSCertFile:   pki.clientCertFile,
+				TLSKeyFile:    pki.clientKeyFile,
+				TLSServerName: "gremlin.example.com",
+			},
+			wantNilErr: true,
+		},
+		{
+			name: "no client certificate",
+			cfg: Config{
+				Endpoint:      endpoint,
+				AuthMode:      "plain",
+				TLSCAFile:     pki.caFile,
+				TLSServerName: "gremlin.example.com",
+			},
+			wantNilErr: false,
+		},
+		{
+			name: "unknown CA",
+			cfg: Config{
+				Endpoint:      endpoint,
+				AuthMode:      "plain",
+				TLSCertFile:   pki.clientCertFile,
+				TLSKeyFile:    pki.clientKeyFile,
+				TLSServerName: "gremlin.example.com",
+			},
+			wantNilErr: false,
+		},
+		{
+			name: "wrong server name",
+			cfg: Config{
+				Endpoint:      endpoint,
+				AuthMode:      "plain",
+				TLSCAFile:     pki.caFile,
+				TLSCertFile:   pki.clientCertFile,
+				TLSKeyFile:    pki.clientKeyFile,
+				TLSServerName: "other.example.com",
+			},
+			wantNilErr: false,
+		},
+		{
+			name: "insecure skip verify",
+			cfg: Config{
+				Endpoint:              endpoint,
+				AuthMode:              "plain",
+				TLSCertFile:           pki.clientCertFile,
+				TLSKeyFile:            pki.clientKeyFile,
+				TLSInsecureSkipVerify: true,
+			},
+			wantNilErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := NewConnection(tt.cfg)
+			if err != nil {
+				t.Fatalf("error creating connection: %v", err)
+			}
+			defer conn.Close()
+
+			_, err = conn.Query(context.Background(), "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+				return g.Inject(1).ToList()
+			})
+			if (err == nil) != tt.wantNilErr {
+				t.Errorf("unexpected error: wantNilErr=%v, got=%v", tt.wantNilErr, err)
+			}
+		})
+	}
+}
+
+func TestNewConnection_InvalidTLSConfig(t *testing.T) {
+	cfg := Config{
+		Endpoint:  "wss://127.0.0.1:8182/gremlin",
+		AuthMode:  "plain",
+		TLSCAFile: "testdata/missing.pem",
+	}
+	if _, err := NewConnection(cfg); err == nil {
+		t.Error("unexpected nil error")
+	}
+}
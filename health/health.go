@@ -0,0 +1,95 @@
+// Package health provides the liveness and readiness endpoints of
+// graph-intel-api.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/adevinta/graph-intel-api/intel"
+	"github.com/adevinta/graph-intel-api/log"
+)
+
+// Checker includes the method set of [intel.API] required to check the
+// readiness of the service.
+type Checker interface {
+	// CheckDependencies returns the status of the dependencies.
+	CheckDependencies(ctx context.Context) []intel.DependencyStatus
+}
+
+// Valid values of [Resp.Status].
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Resp is the body of the health responses.
+type Resp struct {
+	// Status is the status of the service. Valid values: "ok",
+	// "unavailable".
+	Status string `json:"status"`
+
+	// Dependencies contains the status of every dependency. It is only
+	// set by the readiness endpoint.
+	Dependencies []intel.DependencyStatus `json:"dependencies,omitempty"`
+}
+
+// Liveness handles the liveness endpoint. It always reports that the service
+// is alive.
+func Liveness(w http.ResponseWriter, r *http.Request) {
+	writeResp(w, http.StatusOK, Resp{Status: StatusOK})
+}
+
+// Readiness handles the readiness endpoint. It reports whether all the
+// dependencies of the service are available.
+type Readiness struct {
+	checker Checker
+	timeout time.Duration
+}
+
+// NewReadiness returns a [Readiness] handler that checks the dependencies
+// using checker. If timeout is not zero, the dependencies are reported as
+// unavailable if the check takes longer than timeout.
+func NewReadiness(checker Checker, timeout time.Duration) Readiness {
+	return Readiness{
+		checker: checker,
+		timeout: timeout,
+	}
+}
+
+// ServeHTTP serves the readiness endpoint.
+func (rd Readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if rd.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rd.timeout)
+		defer cancel()
+	}
+
+	resp := Resp{
+		Status:       StatusOK,
+		Dependencies: rd.checker.CheckDependencies(ctx),
+	}
+
+	status := http.StatusOK
+	for _, dep := range resp.Dependencies {
+		if !dep.OK {
+			log.Error.Printf("graph-intel-api: health: dependency %q is not available: %v", dep.Name, dep.Error)
+			resp.Status = StatusUnavailable
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	writeResp(w, status, resp)
+}
+
+// writeResp writes a health response.
+func writeResp(w http.ResponseWriter, status int, resp Resp) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error.Printf("graph-intel-api: health: error generating response: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adevinta/graph-intel-api/intel"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLiveness(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(Liveness))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("request error: %v", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: got=%v want=%v", res.StatusCode, http.StatusOK)
+	}
+
+	var got Resp
+	if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+		t.Fatalf("malformed body: %v", err)
+	}
+	res.Body.Close()
+
+	if diff := cmp.Diff(Resp{Status: StatusOK}, got); diff != "" {
+		t.Errorf("responses mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		mock       checkerMock
+		timeout    time.Duration
+		wantStatus int
+		wantResp   Resp
+	}{
+		{
+			name: "ready",
+			mock: checkerMock{
+				deps: []intel.DependencyStatus{
+					{Name: "gremlin", OK: true},
+					{Name: "altimeter_universe", OK: true},
+				},
+			},
+			wantStatus: http.StatusOK,
+			wantResp: Resp{
+				Status: StatusOK,
+				Dependencies: []intel.DependencyStatus{
+					{Name: "gremlin", OK: true},
+					{Name: "altimeter_universe", OK: true},
+				},
+			},
+		},
+		{
+			name: "not ready",
+			mock: checkerMock{
+				deps: []intel.DependencyStatus{
+					{Name: "gremlin", OK: true},
+					{Name: "altimeter_universe", Error: "not found"},
+				},
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantResp: Resp{
+				Status: StatusUnavailable,
+				Dependencies: []intel.DependencyStatus{
+					{Name: "gremlin", OK: true},
+					{Name: "altimeter_universe", Error: "not found"},
+				},
+			},
+		},
+		{
+			name: "timeout",
+			mock: checkerMock{
+				deps: []intel.DependencyStatus{
+					{Name: "gremlin", OK: true},
+				},
+				wait: true,
+			},
+			timeout:    10 * time.Millisecond,
+			wantStatus: http.StatusServiceUnavailable,
+			wantResp: Resp{
+				Status: StatusUnavailable,
+				Dependencies: []intel.DependencyStatus{
+					{Name: "gremlin", Error: context.DeadlineExceeded.Error()},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(NewReadiness(tt.mock, tt.timeout))
+			defer ts.Close()
+
+			res, err := http.Get(ts.URL)
+			if err != nil {
+				t.Fatalf("request error: %v", err)
+			}
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("unexpected status: got=%v want=%v", res.StatusCode, tt.wantStatus)
+			}
+
+			var got Resp
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("malformed body: %v", err)
+			}
+			res.Body.Close()
+
+			if diff := cmp.Diff(tt.wantResp, got); diff != "" {
+				t.Errorf("responses mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+type checkerMock struct {
+	deps []intel.DependencyStatus
+	wait bool
+}
+
+func (mock checkerMock) CheckDependencies(ctx context.Context) []intel.DependencyStatus {
+	if !mock.wait {
+		return mock.deps
+	}
+
+	<-ctx.Done()
+
+	var deps []intel.DependencyStatus
+	for _, dep := range mock.deps {
+		deps = append(deps, intel.DependencyStatus{Name: dep.Name, Error: ctx.Err().Error()})
+	}
+	return deps
+}
@@ -0,0 +1,348 @@
+package intel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+)
+
+// defaultAssetMaxDepth is the maximum depth of the neighbours returned by
+// [API.Asset] used when [Config.AssetMaxDepth] is not set.
+const defaultAssetMaxDepth = 1
+
+// ErrInvalidDepth is returned when the requested depth of the neighbours of
+// an asset is out of range.
+var ErrInvalidDepth = errors.New("invalid depth")
+
+// AssetDetail represents what the Security Graph knows about a vertex.
+type AssetDetail struct {
+	// ID is the vertex ID.
+	ID string `json:"id"`
+
+	// Label is the vertex label.
+	Label string `json:"label"`
+
+	// Properties contains the properties of the vertex. Properties
+	// with a single value are not wrapped in a list.
+	Properties map[string]any `json:"properties"`
+
+	// Snapshots contains the altimeter snapshots that include the
+	// vertex, ordered by timestamp.
+	Snapshots []Snapshot `json:"snapshots"`
+
+	// Neighbors contains the neighbours of the vertex grouped by edge
+	// label.
+	Neighbors map[string][]Neighbor `json:"neighbors"`
+}
+
+// Edge directions reported in [Neighbor].
+const (
+	// DirectionOut means that the edge goes from the vertex to the
+	// neighbour.
+	DirectionOut = "out"
+
+	// DirectionIn means that the edge goes from the neighbour to the
+	// vertex.
+	DirectionIn = "in"
+)
+
+// Neighbor represents a vertex adjacent to another one.
+type Neighbor struct {
+	// ID is the vertex ID of the neighbour.
+	ID string `json:"id"`
+
+	// Label is the vertex label of the neighbour.
+	Label string `json:"label"`
+
+	// Direction is the direction of the edge. Valid values:
+	// [DirectionOut] and [DirectionIn].
+	Direction string `json:"direction"`
+
+	// Neighbors contains the neighbours of the neighbour grouped by
+	// edge label. It is only set if the requested depth has not been
+	// reached and the neighbour has not been expanded before.
+	Neighbors map[string][]Neighbor `json:"neighbors,omitempty"`
+}
+
+// snapshotEdgeLabels contains the labels of the edges that link vertices
+// with altimeter snapshots. They are reported as the snapshots of the asset
+// instead of as neighbours.
+var snapshotEdgeLabels = []any{"includes", "universe_of"}
+
+// Asset returns the label, properties and snapshots of a vertex ID along with
+// its neighbours up to the provided depth. A depth of 1 returns the direct
+// neighbours. Depth must be in the range [1, [Config.AssetMaxDepth]]. Every
+// vertex is expanded only once, so cycles are not followed.
+func (api API) Asset(ctx context.Context, vid string, depth int) (AssetDetail, error) {
+	maxDepth := api.cfg.AssetMaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultAssetMaxDepth
+	}
+	if depth < 1 || depth > maxDepth {
+		return AssetDetail{}, fmt.Errorf("%w: %v not in range [1, %v]", ErrInvalidDepth, depth, maxDepth)
+	}
+
+	detail, err := api.vertexDetail(ctx, vid)
+	if err != nil {
+		return AssetDetail{}, fmt.Errorf("could not get vertex: %w", err)
+	}
+
+	detail.Snapshots, err = api.vertexSnapshots(ctx, vid)
+	if err != nil {
+		return AssetDetail{}, fmt.Errorf("could not get snapshots: %w", err)
+	}
+
+	var (
+		edges    = make(map[string][]neighborEdge)
+		queried  = make(map[string]bool)
+		frontier = []string{vid}
+	)
+	for level := 0; level < depth && len(frontier) > 0; level++ {
+		for _, id := range frontier {
+			queried[id] = true
+		}
+
+		es, err := api.neighborEdges(ctx, frontier)
+		if err != nil {
+			return AssetDetail{}, fmt.Errorf("could not get neighbours: %w", err)
+		}
+
+		var next []string
+		for _, e := range es {
+			edges[e.source] = append(edges[e.source], e)
+			if !queried[e.neighbor.ID] {
+				next = append(next, e.neighbor.ID)
+			}
+		}
+		frontier = dedup(next)
+	}
+
+	expanded := map[string]bool{vid: true}
+	detail.Neighbors = buildNeighbors(vid, edges, expanded, 1, depth)
+
+	return detail, nil
+}
+
+// neighborEdge represents an edge between a vertex and one of its
+// neighbours.
+type neighborEdge struct {
+	source   string
+	label    string
+	neighbor Neighbor
+}
+
+// buildNeighbors returns the neighbours of vid grouped by edge label. The
+// neighbours are expanded recursively until depth is reached. Vertices in
+// expanded are not expanded again.
+func buildNeighbors(vid string, edges map[string][]neighborEdge, expanded map[string]bool, level, depth int) map[string][]Neighbor {
+	neighbors := make(map[string][]Neighbor)
+	for _, e := range edges[vid] {
+		n := e.neighbor
+		if level < depth && !expanded[n.ID] {
+			expanded[n.ID] = true
+			n.Neighbors = buildNeighbors(n.ID, edges, expanded, level+1, depth)
+		}
+		neighbors[e.label] = append(neighbors[e.label], n)
+	}
+	return neighbors
+}
+
+// vertexDetail returns the label and properties of a vertex ID.
+func (api API) vertexDetail(ctx context.Context, vid string) (AssetDetail, error) {
+	results, err := api.conn.Query(ctx, "asset", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		t := g
+
+		if api.cfg.ResolveTimeoutMs > 0 {
+			t = t.With("evaluationTimeout", api.cfg.ResolveTimeoutMs)
+		}
+
+		return t.
+			V(vid).
+			Project("id", "label", "properties").
+			By(gremlingo.T__.Id()).
+			By(gremlingo.T__.Label()).
+			By(gremlingo.T__.ValueMap()).
+			ToList()
+	})
+	if err != nil {
+		return AssetDetail{}, fmt.Errorf("query error: %w", err)
+	}
+
+	if len(results) == 0 {
+		return AssetDetail{}, ErrNotFound
+	}
+
+	m, err := resultMap(results[0])
+	if err != nil {
+		return AssetDetail{}, fmt.Errorf("invalid result: %w", err)
+	}
+
+	var detail AssetDetail
+	if detail.ID, err = mapString(m, "id"); err != nil {
+		return AssetDetail{}, fmt.Errorf("invalid result: %w", err)
+	}
+	if detail.Label, err = mapString(m, "label"); err != nil {
+		return AssetDetail{}, fmt.Errorf("invalid result: %w", err)
+	}
+
+	if detail.Properties, err = parseProperties(m["properties"]); err != nil {
+		return AssetDetail{}, fmt.Errorf("invalid result: %w", err)
+	}
+
+	return detail, nil
+}
+
+// parseProperties parses the result of a valueMap step. Properties with a
+// single value are not wrapped in a list.
+func parseProperties(v any) (map[string]any, error) {
+	m, ok := v.(map[any]any)
+	if !ok {
+		return nil, errors.New("properties is not a map")
+	}
+
+	props := make(map[string]any, len(m))
+	for k, v := range m {
+		sk, ok := k.(string)
+		if !ok {
+			return nil, errors.New("property name is not a string")
+		}
+		if vs, ok := v.([]any); ok && len(vs) == 1 {
+			v = vs[0]
+		}
+		props[sk] = v
+	}
+	return props, nil
+}
+
+// vertexSnapshots returns the altimeter snapshots that include a vertex ID,
+// ordered by timestamp.
+func (api API) vertexSnapshots(ctx context.Context, vid string) ([]Snapshot, error) {
+	results, err := api.conn.Query(ctx, "snapshots", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		t := g
+
+		if api.cfg.ResolveTimeoutMs > 0 {
+			t = t.With("evaluationTimeout", api.cfg.ResolveTimeoutMs)
+		}
+
+		return t.
+			V(vid).
+			In("includes").HasLabel("altimeter_snapshot").
+			Order().By("timestamp", gremlingo.Order.Asc).
+			Project("id", "timestamp").
+			By(gremlingo.T__.Id()).
+			By(gremlingo.T__.Values("timestamp")).
+			ToList()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	snaps := []Snapshot{}
+	for _, result := range results {
+		snap, err := parseSnapshot(result)
+		if err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		snaps = append(snaps, Snapshot{ID: snap.id, Timestamp: snap.timestamp})
+	}
+
+	return snaps, nil
+}
+
+// neighborEdges returns the edges between the provided vertex IDs and their
+// neighbours in both directions, sorted by source, edge label and
+// neighbour. The edges that link vertices with altimeter snapshots are
+// ignored.
+func (api API) neighborEdges(ctx context.Context, vids []string) ([]neighborEdge, error) {
+	results, err := api.conn.Query(ctx, "asset_neighbors", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		t := g
+
+		if api.cfg.ResolveTimeoutMs > 0 {
+			t = t.With("evaluationTimeout", api.cfg.ResolveTimeoutMs)
+		}
+
+		return t.
+			V(toAnySlice(vids)...).
+			Union(
+				gremlingo.T__.
+					OutE().Not(gremlingo.T__.HasLabel(snapshotEdgeLabels...)).
+					Project("source", "edge", "direction", "id", "label").
+					By(gremlingo.T__.OutV().Id()).
+					By(gremlingo.T__.Label()).
+					By(gremlingo.T__.Constant(DirectionOut)).
+					By(gremlingo.T__.InV().Id()).
+					By(gremlingo.T__.InV().Label()),
+				gremlingo.T__.
+					InE().Not(gremlingo.T__.HasLabel(snapshotEdgeLabels...)).
+					Project("source", "edge", "direction", "id", "label").
+					By(gremlingo.T__.InV().Id()).
+					By(gremlingo.T__.Label()).
+					By(gremlingo.T__.Constant(DirectionIn)).
+					By(gremlingo.T__.OutV().Id()).
+					By(gremlingo.T__.OutV().Label()),
+			).
+			ToList()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	var edges []neighborEdge
+	for _, result := range results {
+		m, err := resultMap(result)
+		if err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+
+		var e neighborEdge
+		if e.source, err = mapString(m, "source"); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		if e.label, err = mapString(m, "edge"); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		if e.neighbor.Direction, err = mapString(m, "direction"); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		if e.neighbor.ID, err = mapString(m, "id"); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		if e.neighbor.Label, err = mapString(m, "label"); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		edges = append(edges, e)
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		ei, ej := edges[i], edges[j]
+		if ei.source != ej.source {
+			return ei.source < ej.source
+		}
+		if ei.label != ej.label {
+			return ei.label < ej.label
+		}
+		if ei.neighbor.ID != ej.neighbor.ID {
+			return ei.neighbor.ID < ej.neighbor.ID
+		}
+		return ei.neighbor.Direction < ej.neighbor.Direction
+	})
+
+	return edges, nil
+}
+
+// dedup returns the unique elements of s keeping their order.
+func dedup(s []string) []string {
+	seen := make(map[string]bool, len(s))
+	var out []string
+	for _, v := range s {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
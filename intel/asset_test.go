@@ -0,0 +1,126 @@
+package intel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adevinta/graph-intel-api/gremlin"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAPIAsset(t *testing.T) {
+	properties := map[string]any{
+		"arn":             "arn:aws:ec2:eu-west-1:123456789012:network-interface/eni-0123456789abcdef0",
+		"public_ip":       "1.2.3.4",
+		"public_dns_name": "example.com",
+		"status":          "in-use",
+	}
+	snapshots := []Snapshot{{ID: "s0", Timestamp: time.Unix(0, 0).UTC()}}
+
+	tests := []struct {
+		name    string
+		vid     string
+		depth   int
+		want    AssetDetail
+		wantErr error
+	}{
+		{
+			name:  "direct neighbors",
+			vid:   "ni0",
+			depth: 1,
+			want: AssetDetail{
+				ID:         "ni0",
+				Label:      "ec2:network-interface",
+				Properties: properties,
+				Snapshots:  snapshots,
+				Neighbors: map[string][]Neighbor{
+					"resource_link": {
+						{ID: "sg0", Label: "ec2:security-group", Direction: DirectionOut},
+					},
+				},
+			},
+		},
+		{
+			name:  "depth 2",
+			vid:   "ni0",
+			depth: 2,
+			want: AssetDetail{
+				ID:         "ni0",
+				Label:      "ec2:network-interface",
+				Properties: properties,
+				Snapshots:  snapshots,
+				Neighbors: map[string][]Neighbor{
+					"resource_link": {
+						{
+							ID:        "sg0",
+							Label:     "ec2:security-group",
+							Direction: DirectionOut,
+							Neighbors: map[string][]Neighbor{
+								"egress_rule": {
+									{ID: "er0", Label: "egress_rule", Direction: DirectionOut},
+								},
+								"resource_link": {
+									{ID: "ni0", Label: "ec2:network-interface", Direction: DirectionIn},
+									{ID: "uigp0", Label: "user_id_group_pairs", Direction: DirectionIn},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:    "depth out of range",
+			vid:     "ni0",
+			depth:   3,
+			wantErr: ErrInvalidDepth,
+		},
+		{
+			name:    "zero depth",
+			vid:     "ni0",
+			depth:   0,
+			wantErr: ErrInvalidDepth,
+		},
+		{
+			name:    "not found",
+			vid:     "unknown",
+			depth:   1,
+			wantErr: ErrNotFound,
+		},
+	}
+
+	if err := setupBlastRadiusGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+		ResolveTimeoutMs:     60000,
+		BlastRadiusTimeoutMs: 60000,
+		AssetMaxDepth:        2,
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := intelAPI.Asset(context.Background(), tt.vid, tt.depth)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: got=%v want=%v", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("asset mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
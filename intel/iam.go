@@ -0,0 +1,259 @@
+package intel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+)
+
+// IAMModel is the name of the IAM blast radius model.
+const IAMModel = "iam"
+
+// Labels of the permissions returned by [API.iamReachableResources].
+const (
+	// iamAdminLabel is the label of the permissions that allow any
+	// action on any resource.
+	iamAdminLabel = "iam:admin"
+
+	// iamWildcardLabel is the label of the permissions that allow
+	// multiple actions using a wildcard.
+	iamWildcardLabel = "iam:action-wildcard"
+
+	// iamActionLabel is the label of the permissions that allow a
+	// single action.
+	iamActionLabel = "iam:action"
+)
+
+// iamScorer is the [Scorer] of the IAM blast radius model. Permissions
+// weight more the more actions they allow. The decay function is 1/steps.
+type iamScorer struct{}
+
+// Weight returns the weight of a permission given its label.
+func (iamScorer) Weight(label string) float64 {
+	switch label {
+	case iamAdminLabel:
+		return 10.0
+	case iamWildcardLabel:
+		return 5.0
+	case iamActionLabel:
+		return 1.0
+	}
+	return 0.0
+}
+
+// Decay returns 1/steps.
+func (iamScorer) Decay(steps int) float64 {
+	return 1.0 / float64(steps)
+}
+
+// iamReachableResources returns the permissions granted to a vertex ID by
+// IAM. The traversal follows instance → instance profile → role → managed
+// and inline policies. The asset can also be a network interface attached
+// to an instance or a role. Every returned resource represents an action
+// allowed on a resource pattern by a policy statement. Its ID is the action
+// followed by the resource pattern, its label depends on the allowed actions
+// and its steps are the steps needed to reach the policy. If an action is
+// allowed by several policies, only the shortest path is considered. If
+// withPath is true, the path followed to reach the policy is also returned.
+func (api API) iamReachableResources(ctx context.Context, vid string, withPath bool) ([]resource, error) {
+	results, err := api.conn.Query(ctx, "iam_reachable_resources", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		t := g
+
+		if api.cfg.BlastRadiusTimeoutMs > 0 {
+			t = t.With("evaluationTimeout", api.cfg.BlastRadiusTimeoutMs)
+		}
+
+		keys := []any{"id", "steps", "document"}
+		if withPath {
+			keys = append(keys, "path")
+		}
+
+		traversal := t.
+			V(vid).
+			Union(
+				gremlingo.T__.Identity(),
+				// Network interfaces attached to an instance.
+				gremlingo.T__.OutE("resource_link").InV().HasLabel("ec2:instance"),
+			).
+			Union(
+				gremlingo.T__.HasLabel("iam:role"),
+				gremlingo.T__.
+					HasLabel("ec2:instance").
+					OutE("transient_resource_link").InV().HasLabel("iam:instance-profile").
+					OutE("resource_link").InV().HasLabel("iam:role"),
+			).
+			Union(
+				gremlingo.T__.OutE("resource_link").InV().HasLabel("iam:policy"),
+				gremlingo.T__.OutE("embedded_policy").InV().HasLabel("embedded_policy"),
+			).
+			Project(keys...).
+			By(gremlingo.T__.Id()).
+			By(gremlingo.T__.Path().Count(gremlingo.Scope.Local)).
+			By(gremlingo.T__.Coalesce(
+				gremlingo.T__.Values("default_version_policy_document_text"),
+				gremlingo.T__.Values("policy_document"),
+				gremlingo.T__.Constant(""),
+			))
+
+		if withPath {
+			traversal = traversal.By(gremlingo.T__.Path())
+		}
+
+		return traversal.ToList()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	var (
+		rscs []resource
+		idxs = make(map[string]int)
+	)
+	for _, result := range results {
+		m, err := resultMap(result)
+		if err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+
+		pid, err := mapString(m, "id")
+		if err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		steps, err := mapInt(m, "steps")
+		if err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		doc, err := mapString(m, "document")
+		if err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+
+		var path []PathVertex
+		if withPath {
+			p, ok := m["path"].(*gremlingo.Path)
+			if !ok {
+				return nil, errors.New("invalid result: path is not a path")
+			}
+			if path, err = parsePath(p); err != nil {
+				return nil, fmt.Errorf("invalid result: invalid path: %w", err)
+			}
+		}
+
+		perms, err := parsePolicyDocument(doc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy document in %q: %w", pid, err)
+		}
+
+		for _, perm := range perms {
+			rsc := resource{
+				id:    perm.action + " " + perm.resource,
+				label: perm.label(),
+				steps: float64(steps),
+				path:  path,
+			}
+			rsc.key = rsc.id
+
+			if i, ok := idxs[rsc.id]; ok {
+				if rsc.steps < rscs[i].steps {
+					rscs[i] = rsc
+				}
+				continue
+			}
+			idxs[rsc.id] = len(rscs)
+			rscs = append(rscs, rsc)
+		}
+	}
+
+	return rscs, nil
+}
+
+// permission represents an action allowed on a resource pattern.
+type permission struct {
+	action   string
+	resource string
+}
+
+// label returns the label of the permission.
+func (p permission) label() string {
+	switch {
+	case p.action == "*" && p.resource == "*":
+		return iamAdminLabel
+	case strings.Contains(p.action, "*"):
+		return iamWildcardLabel
+	default:
+		return iamActionLabel
+	}
+}
+
+// policyDocument represents an IAM policy document.
+type policyDocument struct {
+	Statement oneOrMany[policyStatement] `json:"Statement"`
+}
+
+// policyStatement represents a statement of an IAM policy document.
+type policyStatement struct {
+	Effect   string            `json:"Effect"`
+	Action   oneOrMany[string] `json:"Action"`
+	Resource oneOrMany[string] `json:"Resource"`
+}
+
+// oneOrMany is a JSON value that can be either a single element or an array
+// of elements.
+type oneOrMany[T any] []T
+
+// UnmarshalJSON implements [json.Unmarshaler].
+func (v *oneOrMany[T]) UnmarshalJSON(data []byte) error {
+	var many []T
+	if err := json.Unmarshal(data, &many); err == nil {
+		*v = many
+		return nil
+	}
+
+	var one T
+	if err := json.Unmarshal(data, &one); err != nil {
+		return err
+	}
+	*v = []T{one}
+	return nil
+}
+
+// parsePolicyDocument returns the permissions allowed by an IAM policy
+// document. The document can be URL encoded, as returned by the IAM API.
+// Statements with effect "Deny" and those using "NotAction" or
+// "NotResource" are ignored.
+func parsePolicyDocument(doc string) ([]permission, error) {
+	if doc == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(strings.TrimSpace(doc), "{") {
+		unescaped, err := url.QueryUnescape(doc)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode document: %w", err)
+		}
+		doc = unescaped
+	}
+
+	var pd policyDocument
+	if err := json.Unmarshal([]byte(doc), &pd); err != nil {
+		return nil, fmt.Errorf("could not parse document: %w", err)
+	}
+
+	var perms []permission
+	for _, stmt := range pd.Statement {
+		if stmt.Effect != "Allow" {
+			continue
+		}
+		for _, action := range stmt.Action {
+			for _, rsc := range stmt.Resource {
+				perms = append(perms, permission{action: action, resource: rsc})
+			}
+		}
+	}
+	return perms, nil
+}
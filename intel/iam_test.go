@@ -0,0 +1,193 @@
+package intel
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/adevinta/graph-intel-api/gremlin"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestParsePolicyDocument(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc        string
+		want       []permission
+		wantNilErr bool
+	}{
+		{
+			name: "single statement",
+			doc:  `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}}`,
+			want: []permission{
+				{action: "s3:GetObject", resource: "arn:aws:s3:::bucket/*"},
+			},
+			wantNilErr: true,
+		},
+		{
+			name: "multiple statements",
+			doc:  `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject","s3:PutObject"],"Resource":["arn:aws:s3:::a/*","arn:aws:s3:::b/*"]},{"Effect":"Deny","Action":"*","Resource":"*"}]}`,
+			want: []permission{
+				{action: "s3:GetObject", resource: "arn:aws:s3:::a/*"},
+				{action: "s3:GetObject", resource: "arn:aws:s3:::b/*"},
+				{action: "s3:PutObject", resource: "arn:aws:s3:::a/*"},
+				{action: "s3:PutObject", resource: "arn:aws:s3:::b/*"},
+			},
+			wantNilErr: true,
+		},
+		{
+			name: "URL encoded",
+			doc:  `%7B%22Statement%22%3A%7B%22Effect%22%3A%22Allow%22%2C%22Action%22%3A%22%2A%22%2C%22Resource%22%3A%22%2A%22%7D%7D`,
+			want: []permission{
+				{action: "*", resource: "*"},
+			},
+			wantNilErr: true,
+		},
+		{
+			name:       "empty",
+			doc:        "",
+			want:       nil,
+			wantNilErr: true,
+		},
+		{
+			name:       "malformed",
+			doc:        `{"Statement":`,
+			want:       nil,
+			wantNilErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePolicyDocument(tt.doc)
+			if (err == nil) != tt.wantNilErr {
+				t.Fatalf("unexpected error: wantNilErr=%v, got=%v", tt.wantNilErr, err)
+			}
+
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(permission{})); diff != "" {
+				t.Errorf("permissions mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func setupIAMGraph() error {
+	gremlinConfig := gremlin.Config{
+		Endpoint: gremlinEndpoint,
+		AuthMode: gremlinAuthMode,
+	}
+	conn, err := gremlin.NewConnection(gremlinConfig)
+	if err != nil {
+		return fmt.Errorf("error creating Gremlin connection: %w", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Query(context.Background(), "setup", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		<-g.V().Drop().Iterate()
+		<-g.
+			AddV("Universe").Property(gremlingo.T.Id, "u0").Property("namespace", "altimeter").Property("version", 1).As("u0").
+			AddV("altimeter_snapshot").Property(gremlingo.T.Id, "s0").Property("timestamp", 0).As("s0").
+			AddV("ec2:network-interface").Property(gremlingo.T.Id, "ni0").Property("public_ip", "1.2.3.4").Property("status", "in-use").As("ni0").
+			AddV("ec2:instance").Property(gremlingo.T.Id, "i0").As("i0").
+			AddV("iam:instance-profile").Property(gremlingo.T.Id, "ip0").As("ip0").
+			AddV("iam:role").Property(gremlingo.T.Id, "role0").As("role0").
+			AddV("iam:policy").Property(gremlingo.T.Id, "p0").Property("default_version_policy_document_text", `{"Statement":{"Effect":"Allow","Action":"s3:*","Resource":"*"}}`).As("p0").
+			AddV("embedded_policy").Property(gremlingo.T.Id, "ep0").Property("policy_document", `{"Statement":{"Effect":"Allow","Action":"sqs:SendMessage","Resource":"arn:aws:sqs:eu-west-1:123456789012:queue"}}`).As("ep0").
+			AddE("universe_of").From("u0").To("s0").
+			AddE("includes").From("s0").To("ni0").
+			AddE("includes").From("s0").To("i0").
+			AddE("includes").From("s0").To("ip0").
+			AddE("includes").From("s0").To("role0").
+			AddE("includes").From("s0").To("p0").
+			AddE("includes").From("s0").To("ep0").
+			AddE("resource_link").From("ni0").To("i0").
+			AddE("transient_resource_link").From("i0").To("ip0").
+			AddE("resource_link").From("ip0").To("role0").
+			AddE("resource_link").From("role0").To("p0").
+			AddE("embedded_policy").From("role0").To("ep0").
+			Iterate()
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("error executing Gremlin query: %w", err)
+	}
+
+	return nil
+}
+
+func TestAPIBlastRadius_IAM(t *testing.T) {
+	want := BlastRadiusExplanation{
+		// Both policies are reached after 9 steps.
+		Score: 5.0/9.0 + 1.0/9.0,
+		Metadata: Metadata{
+			Model:        "iam",
+			ModelVersion: "1",
+			VertexID:     "ni0",
+			Resolution:   ResolutionDirect,
+			Snapshot:     Snapshot{ID: "s0", Timestamp: time.Unix(0, 0).UTC()},
+		},
+		Resources: []ExplainedResource{
+			{
+				ID:     "s3:* *",
+				Label:  "iam:action-wildcard",
+				Steps:  9,
+				Weight: 5,
+				Score:  5.0 / 9.0,
+				Path: []PathVertex{
+					{ID: "ni0", Label: "ec2:network-interface"},
+					{ID: "i0", Label: "ec2:instance"},
+					{ID: "ip0", Label: "iam:instance-profile"},
+					{ID: "role0", Label: "iam:role"},
+					{ID: "p0", Label: "iam:policy"},
+				},
+			},
+			{
+				ID:     "sqs:SendMessage arn:aws:sqs:eu-west-1:123456789012:queue",
+				Label:  "iam:action",
+				Steps:  9,
+				Weight: 1,
+				Score:  1.0 / 9.0,
+				Path: []PathVertex{
+					{ID: "ni0", Label: "ec2:network-interface"},
+					{ID: "i0", Label: "ec2:instance"},
+					{ID: "ip0", Label: "iam:instance-profile"},
+					{ID: "role0", Label: "iam:role"},
+					{ID: "ep0", Label: "embedded_policy"},
+				},
+			},
+		},
+	}
+
+	if err := setupIAMGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+		ResolveTimeoutMs:     60000,
+		BlastRadiusTimeoutMs: 60000,
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	got, err := intelAPI.BlastRadiusExplain(context.Background(), "IP", "1.2.3.4", BlastRadiusOptions{Model: "iam"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The score is the sum of the contributions of the resources, so it
+	// depends on the order of the floating-point additions.
+	if diff := cmp.Diff(want, got, ignoreDuration, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("explanation mismatch (-want +got):\n%v", diff)
+	}
+}
@@ -7,6 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/adevinta/graph-intel-api/gremlin"
 	"github.com/adevinta/graph-intel-api/log"
@@ -17,122 +21,1352 @@ import (
 // maxQueryDepth is the maximum depth traversed in Gremlin queries.
 const maxQueryDepth = int32(15)
 
-// ErrNotFound is returned when an entity is not found.
-var ErrNotFound = errors.New("not found")
+// DefaultBatchConcurrency is the number of assets or snapshots processed
+// concurrently when [Config.BatchConcurrency] is not set.
+const DefaultBatchConcurrency = 10
+
+// defaultTrendMaxSnapshots is the maximum number of snapshots processed by
+// [API.BlastRadiusTrend] when [Config.TrendMaxSnapshots] is not set.
+const defaultTrendMaxSnapshots = 100
+
+var (
+	// ErrNotFound is returned when an entity is not found.
+	ErrNotFound = errors.New("not found")
+
+	// ErrUnsupportedAssetType is returned when the type of an asset is not
+	// supported.
+	ErrUnsupportedAssetType = errors.New("unsupported asset type")
+
+	// ErrTooManySnapshots is returned when a time range contains more
+	// snapshots than the allowed maximum.
+	ErrTooManySnapshots = errors.New("too many snapshots")
+)
 
 // Config contains the configuration parameters.
 type Config struct {
 	// GremlinConfig is the Gremlin configuration parameters.
 	GremlinConfig gremlin.Config
 
-	// ResolveTimeoutMs is the query timeout in ms used when finding
-	// assets. If zero, no timeout is set.
-	ResolveTimeoutMs int
+	// ResolveTimeoutMs is the query timeout in ms used when finding
+	// assets. If zero, no timeout is set.
+	ResolveTimeoutMs int
+
+	// BlastRadiusTimeoutMs is the query timeout in ms used when
+	// calculating the blast radius score. If zero, no timeout is set.
+	BlastRadiusTimeoutMs int
+
+	// BatchConcurrency is the maximum number of assets processed
+	// concurrently when calculating the blast radius of a batch of
+	// assets, and the maximum number of snapshots processed
+	// concurrently when calculating a blast radius trend. If zero,
+	// [DefaultBatchConcurrency] is used.
+	BatchConcurrency int
+
+	// Models contains the configurable blast radius models available
+	// along with the built-in ones.
+	Models []ModelConfig
+
+	// RiskWeights contains the weights used to combine the components
+	// of the risk score.
+	RiskWeights RiskWeights
+
+	// AssetMaxDepth is the maximum depth of the neighbours returned by
+	// [API.Asset]. If zero, only the direct neighbours can be
+	// requested.
+	AssetMaxDepth int
+
+	// TrendMaxSnapshots is the maximum number of snapshots within the
+	// time range of [API.BlastRadiusTrend]. If zero, up to 100
+	// snapshots are allowed.
+	TrendMaxSnapshots int
+}
+
+// API implements the Intel API of the Security Graph.
+type API struct {
+	cfg      Config
+	conn     gremlin.Connection
+	resolver *net.Resolver
+	models   map[string]model
+}
+
+// NewAPI creates a new intel API using the given config.
+func NewAPI(cfg Config) (API, error) {
+	models, err := newModels(cfg.Models)
+	if err != nil {
+		return API{}, fmt.Errorf("invalid blast radius models: %w", err)
+	}
+
+	conn, err := gremlin.NewConnection(cfg.GremlinConfig)
+	if err != nil {
+		return API{}, fmt.Errorf("could not create a Gremlin connection: %w", err)
+	}
+
+	api := API{
+		cfg:      cfg,
+		conn:     conn,
+		resolver: &net.Resolver{PreferGo: true},
+		models:   models,
+	}
+	return api, nil
+}
+
+// Close closes the connections with the Gremlin server.
+func (api API) Close() {
+	api.conn.Close()
+}
+
+// DependencyStatus represents the status of a dependency of the intel API.
+type DependencyStatus struct {
+	// Name is the name of the dependency.
+	Name string `json:"name"`
+
+	// OK reports whether the dependency is available.
+	OK bool `json:"ok"`
+
+	// Error describes why the dependency is not available.
+	Error string `json:"error,omitempty"`
+}
+
+// CheckDependencies returns the status of the dependencies of the intel API.
+// That is, the connectivity with the Gremlin server and the existence of the
+// altimeter Universe vertex. The checks use dedicated Gremlin connections, so
+// they are not affected by the load of the service.
+func (api API) CheckDependencies(ctx context.Context) []DependencyStatus {
+	gremlinStatus := DependencyStatus{Name: "gremlin", OK: true}
+	_, err := api.conn.Check(ctx, "health", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return g.Inject(1).ToList()
+	})
+	if err != nil {
+		gremlinStatus = DependencyStatus{Name: "gremlin", Error: err.Error()}
+	}
+
+	universeStatus := DependencyStatus{Name: "altimeter_universe", OK: true}
+	results, err := api.conn.Check(ctx, "health", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return g.
+			V().
+			HasLabel("Universe").Has("namespace", "altimeter").Has("version", 1).
+			Limit(1).
+			Id().
+			ToList()
+	})
+	switch {
+	case err != nil:
+		universeStatus = DependencyStatus{Name: "altimeter_universe", Error: err.Error()}
+	case len(results) == 0:
+		universeStatus = DependencyStatus{Name: "altimeter_universe", Error: ErrNotFound.Error()}
+	}
+
+	return []DependencyStatus{gremlinStatus, universeStatus}
+}
+
+// BlastRadiusResult represents the result of calculating the blast radius
+// score for a given asset.
+type BlastRadiusResult struct {
+	// Score contains the blast radius score for a given asset.
+	Score float64 `json:"score"`
+
+	// Metadata contains information about how a blast radius was
+	// calculated.
+	Metadata Metadata `json:"metadata"`
+}
+
+// Resolution methods reported in [Metadata].
+const (
+	// ResolutionDirect means that the asset was found in the Security
+	// Graph using its identifier.
+	ResolutionDirect = "direct"
+
+	// ResolutionDNS means that the asset is a hostname that was not
+	// found in the Security Graph, so it was resolved using the IP
+	// addresses returned by a DNS lookup.
+	ResolutionDNS = "dns"
+)
+
+// Metadata contains information about how a blast radius was calculated.
+type Metadata struct {
+	// Model is the name of the blast radius model.
+	Model string `json:"model"`
+
+	// ModelVersion is the version of the blast radius model.
+	ModelVersion string `json:"model_version"`
+
+	// VertexID is the vertex ID the asset was resolved to.
+	VertexID string `json:"vertex_id"`
+
+	// Resolution is the method used to resolve the asset. Valid
+	// values: [ResolutionDirect] and [ResolutionDNS].
+	Resolution string `json:"resolution"`
+
+	// Snapshot is the altimeter snapshot the blast radius was
+	// calculated against.
+	Snapshot Snapshot `json:"snapshot"`
+
+	// DurationMs is the time in milliseconds spent calculating the
+	// blast radius, including the resolution of the asset.
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// BlastRadiusOptions contains the optional parameters used when calculating
+// the blast radius of an asset.
+type BlastRadiusOptions struct {
+	// At is the instant used to select the altimeter snapshot. The
+	// asset is resolved in the latest snapshot at or before At, and its
+	// blast radius is calculated from the vertices of that snapshot. If
+	// zero, the latest snapshot that contains the asset is used.
+	At time.Time
+
+	// Model is the name of the blast radius model. If empty,
+	// [DefaultModel] is used.
+	Model string
+}
+
+// model returns the blast radius model with the provided name. If name is
+// empty, it returns [DefaultModel].
+func (api API) model(name string) (model, error) {
+	if name == "" {
+		name = DefaultModel
+	}
+
+	m, ok := api.models[name]
+	if !ok {
+		return model{}, fmt.Errorf("%w: %v", ErrUnknownModel, name)
+	}
+	return m, nil
+}
+
+// BlastRadius returns the blast radius of a given asset. It returns a
+// [BlastRadiusResult] with the score and the metadata about how score was
+// calculated.
+func (api API) BlastRadius(ctx context.Context, typ, identifier string, opts BlastRadiusOptions) (BlastRadiusResult, error) {
+	start := time.Now()
+
+	m, err := api.model(opts.Model)
+	if err != nil {
+		return BlastRadiusResult{}, err
+	}
+
+	vid, method, err := api.resolveAssetAt(ctx, typ, identifier, opts.At)
+	if err != nil {
+		return BlastRadiusResult{}, fmt.Errorf("could not resolve asset: %w", err)
+	}
+
+	snap, err := api.vertexSnapshot(ctx, vid)
+	if err != nil {
+		return BlastRadiusResult{}, fmt.Errorf("could not get snapshot: %w", err)
+	}
+
+	rscs, err := m.reach(api, ctx, vid, false)
+	if err != nil {
+		return BlastRadiusResult{}, fmt.Errorf("could not calculate %v blast radius: %w", m.name, err)
+	}
+
+	result := BlastRadiusResult{
+		Score:    score(rscs, m.scorer),
+		Metadata: newMetadata(m, vid, method, snap, start),
+	}
+
+	return result, nil
+}
+
+// newMetadata returns the [Metadata] of a blast radius calculated using the
+// provided model, vertex ID, resolution method and snapshot. The duration is
+// measured since start.
+func newMetadata(m model, vid, method string, snap snapshot, start time.Time) Metadata {
+	return Metadata{
+		Model:        m.name,
+		ModelVersion: m.version,
+		VertexID:     vid,
+		Resolution:   method,
+		Snapshot:     Snapshot{ID: snap.id, Timestamp: snap.timestamp},
+		DurationMs:   time.Since(start).Milliseconds(),
+	}
+}
+
+// BlastRadiusExplanation represents the result of calculating the blast
+// radius score for a given asset, including the resources that contributed
+// to the score.
+type BlastRadiusExplanation struct {
+	// Score contains the blast radius score for a given asset.
+	Score float64 `json:"score"`
+
+	// Metadata contains information about how a blast radius was
+	// calculated.
+	Metadata Metadata `json:"metadata"`
+
+	// Resources contains the resources that contributed to the score.
+	Resources []ExplainedResource `json:"resources"`
+}
+
+// ExplainedResource represents a resource that contributed to a blast radius
+// score.
+type ExplainedResource struct {
+	// ID is the vertex ID of the resource.
+	ID string `json:"id"`
+
+	// Label is the vertex label of the resource.
+	Label string `json:"label"`
+
+	// Steps is the number of vertices and edges traversed to reach the
+	// resource, including the asset and the resource itself.
+	Steps int `json:"steps"`
+
+	// Weight is the weight of the resource.
+	Weight float64 `json:"weight"`
+
+	// Score is the contribution of the resource to the blast radius
+	// score.
+	Score float64 `json:"score"`
+
+	// Path contains the vertices traversed to reach the resource,
+	// starting with the asset and ending with the resource.
+	Path []PathVertex `json:"path"`
+}
+
+// PathVertex represents a vertex in the path followed to reach a resource.
+type PathVertex struct {
+	// ID is the vertex ID.
+	ID string `json:"id"`
+
+	// Label is the vertex label.
+	Label string `json:"label"`
+}
+
+// BlastRadiusExplain returns the blast radius of a given asset along with
+// the resources that contributed to the score and the paths that reached
+// them.
+func (api API) BlastRadiusExplain(ctx context.Context, typ, identifier string, opts BlastRadiusOptions) (BlastRadiusExplanation, error) {
+	start := time.Now()
+
+	m, err := api.model(opts.Model)
+	if err != nil {
+		return BlastRadiusExplanation{}, err
+	}
+
+	vid, method, err := api.resolveAssetAt(ctx, typ, identifier, opts.At)
+	if err != nil {
+		return BlastRadiusExplanation{}, fmt.Errorf("could not resolve asset: %w", err)
+	}
+
+	snap, err := api.vertexSnapshot(ctx, vid)
+	if err != nil {
+		return BlastRadiusExplanation{}, fmt.Errorf("could not get snapshot: %w", err)
+	}
+
+	rscs, err := m.reach(api, ctx, vid, true)
+	if err != nil {
+		return BlastRadiusExplanation{}, fmt.Errorf("could not calculate %v blast radius: %w", m.name, err)
+	}
+
+	explanation := BlastRadiusExplanation{
+		Score:     score(rscs, m.scorer),
+		Resources: []ExplainedResource{},
+	}
+
+	for _, rsc := range rscs {
+		weight := m.scorer.Weight(rsc.label)
+		if weight == 0 {
+			continue
+		}
+
+		explanation.Resources = append(explanation.Resources, ExplainedResource{
+			ID:     rsc.id,
+			Label:  rsc.label,
+			Steps:  int(rsc.steps),
+			Weight: weight,
+			Score:  m.scorer.Decay(int(rsc.steps)) * weight,
+			Path:   rsc.path,
+		})
+	}
+
+	sort.SliceStable(explanation.Resources, func(i, j int) bool {
+		ri, rj := explanation.Resources[i], explanation.Resources[j]
+		if ri.Steps != rj.Steps {
+			return ri.Steps < rj.Steps
+		}
+		return ri.ID < rj.ID
+	})
+
+	explanation.Metadata = newMetadata(m, vid, method, snap, start)
+
+	return explanation, nil
+}
+
+// BlastRadiusTrend represents the blast radius score of an asset across the
+// altimeter snapshots within a time range.
+type BlastRadiusTrend struct {
+	// Metadata contains information about how the blast radius scores
+	// were calculated.
+	Metadata TrendMetadata `json:"metadata"`
+
+	// Points contains the blast radius score of the asset in every
+	// snapshot that contains it, ordered by timestamp.
+	Points []TrendPoint `json:"points"`
+}
+
+// TrendMetadata contains information about how the blast radius scores of a
+// [BlastRadiusTrend] were calculated.
+type TrendMetadata struct {
+	// Model is the name of the blast radius model.
+	Model string `json:"model"`
+
+	// ModelVersion is the version of the blast radius model.
+	ModelVersion string `json:"model_version"`
+
+	// From is the first snapshot that contains the asset within the
+	// time range.
+	From Snapshot `json:"from"`
+
+	// To is the last snapshot that contains the asset within the time
+	// range.
+	To Snapshot `json:"to"`
+
+	// DurationMs is the time in milliseconds spent calculating the
+	// blast radius scores, including the resolution of the asset.
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// TrendPoint represents the blast radius score of an asset in an altimeter
+// snapshot.
+type TrendPoint struct {
+	// Timestamp is the timestamp of the snapshot.
+	Timestamp time.Time `json:"timestamp"`
+
+	// SnapshotID is the vertex ID of the snapshot.
+	SnapshotID string `json:"snapshot_id"`
+
+	// Score contains the blast radius score of the asset in the
+	// snapshot.
+	Score float64 `json:"score"`
+
+	// VertexID is the vertex ID the asset was resolved to in the
+	// snapshot.
+	VertexID string `json:"vertex_id"`
+
+	// Resolution is the method used to resolve the asset in the
+	// snapshot. Valid values: [ResolutionDirect] and [ResolutionDNS].
+	Resolution string `json:"resolution"`
+}
+
+// BlastRadiusTrend returns the blast radius of a given asset in every
+// altimeter snapshot between from and to, both included, calculated using
+// the blast radius model with the provided name. If the name is empty,
+// [DefaultModel] is used. Snapshots that do not contain the asset are
+// skipped. If no snapshot contains the asset, it
+// returns [ErrNotFound]. If the time range contains more than
+// [Config.TrendMaxSnapshots] snapshots, it returns [ErrTooManySnapshots]. Up to
+// [Config.BatchConcurrency] snapshots are processed concurrently.
+func (api API) BlastRadiusTrend(ctx context.Context, typ, identifier string, from, to time.Time, modelName string) (BlastRadiusTrend, error) {
+	start := time.Now()
+
+	m, err := api.model(modelName)
+	if err != nil {
+		return BlastRadiusTrend{}, err
+	}
+
+	maxSnaps := api.cfg.TrendMaxSnapshots
+	if maxSnaps <= 0 {
+		maxSnaps = defaultTrendMaxSnapshots
+	}
+
+	// Ask for one more snapshot than allowed to detect when the
+	// maximum is exceeded.
+	snaps, err := api.snapshots(ctx, from, to, maxSnaps+1)
+	if err != nil {
+		return BlastRadiusTrend{}, fmt.Errorf("could not get snapshots: %w", err)
+	}
+
+	if len(snaps) > maxSnaps {
+		return BlastRadiusTrend{}, fmt.Errorf("%w: more than %v snapshots between %v and %v", ErrTooManySnapshots, maxSnaps, from, to)
+	}
+
+	// Calculate the points concurrently. If a point fails, ctx is
+	// canceled so the pending ones are not calculated.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	points := make([]TrendPoint, len(snaps))
+	errs := make([]error, len(snaps))
+	sem := make(chan struct{}, api.batchConcurrency())
+
+	var wg sync.WaitGroup
+	for i, snap := range snaps {
+		select {
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(i int, snap snapshot) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			points[i], errs[i] = api.trendPoint(ctx, m, typ, identifier, snap)
+			if errs[i] != nil && !errors.Is(errs[i], ErrNotFound) {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = errs[i]
+				}
+				mu.Unlock()
+				cancel()
+			}
+		}(i, snap)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return BlastRadiusTrend{}, firstErr
+	}
+
+	trend := BlastRadiusTrend{
+		Points: []TrendPoint{},
+	}
+
+	for i, err := range errs {
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return BlastRadiusTrend{}, err
+		}
+		trend.Points = append(trend.Points, points[i])
+	}
+
+	if len(trend.Points) == 0 {
+		return BlastRadiusTrend{}, ErrNotFound
+	}
+
+	first, last := trend.Points[0], trend.Points[len(trend.Points)-1]
+	trend.Metadata = TrendMetadata{
+		Model:        m.name,
+		ModelVersion: m.version,
+		From:         Snapshot{ID: first.SnapshotID, Timestamp: first.Timestamp},
+		To:           Snapshot{ID: last.SnapshotID, Timestamp: last.Timestamp},
+		DurationMs:   time.Since(start).Milliseconds(),
+	}
+
+	return trend, nil
+}
+
+// trendPoint returns the blast radius of a given asset in the provided
+// snapshot, calculated using the blast radius model m. If the snapshot does
+// not contain the asset, it returns [ErrNotFound].
+func (api API) trendPoint(ctx context.Context, m model, typ, identifier string, snap snapshot) (TrendPoint, error) {
+	vid, method, err := api.resolveAsset(ctx, typ, identifier, snap.id)
+	if errors.Is(err, ErrNotFound) {
+		return TrendPoint{}, err
+	}
+	if err != nil {
+		return TrendPoint{}, fmt.Errorf("could not resolve asset in snapshot %q: %w", snap.id, err)
+	}
+
+	rscs, err := m.reach(api, ctx, vid, false)
+	if err != nil {
+		return TrendPoint{}, fmt.Errorf("could not calculate %v blast radius in snapshot %q: %w", m.name, snap.id, err)
+	}
+
+	p := TrendPoint{
+		Timestamp:  snap.timestamp,
+		SnapshotID: snap.id,
+		Score:      score(rscs, m.scorer),
+		VertexID:   vid,
+		Resolution: method,
+	}
+	return p, nil
+}
+
+// Snapshot represents an altimeter snapshot.
+type Snapshot struct {
+	// ID is the vertex ID of the snapshot.
+	ID string `json:"id"`
+
+	// Timestamp is the timestamp of the snapshot.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// BlastRadiusDiff represents the changes in the resources reachable from an
+// asset between two altimeter snapshots.
+type BlastRadiusDiff struct {
+	// From is the snapshot used as the base of the diff.
+	From Snapshot `json:"from"`
+
+	// To is the snapshot compared with the base.
+	To Snapshot `json:"to"`
+
+	// Added contains the resources that are only reachable in the
+	// snapshot To.
+	Added []DiffResource `json:"added"`
+
+	// Removed contains the resources that are only reachable in the
+	// snapshot From.
+	Removed []DiffResource `json:"removed"`
+
+	// Unchanged contains the resources that are reachable in both
+	// snapshots.
+	Unchanged []DiffResource `json:"unchanged"`
+}
+
+// DiffResource represents a reachable resource in a [BlastRadiusDiff].
+type DiffResource struct {
+	// Key identifies the resource across snapshots. It is the ARN of
+	// the resource, the CIDR of an IP range or, if none of them is
+	// available, the vertex ID.
+	Key string `json:"key"`
+
+	// ID is the vertex ID of the resource. Removed resources refer to
+	// the snapshot From and the others to the snapshot To.
+	ID string `json:"id"`
+
+	// Label is the vertex label of the resource.
+	Label string `json:"label"`
+
+	// Rules contains the security group rules traversed to reach the
+	// resource. That is, the rules that caused an added or removed
+	// resource. It is empty for unchanged resources.
+	Rules []PathVertex `json:"rules"`
+}
+
+// BlastRadiusDiff returns the changes in the resources reachable from a
+// given asset between the latest altimeter snapshots at or before from and
+// to. Only the resources that contribute to the blast radius score are
+// considered. If the asset is not contained by any of the snapshots, it
+// returns [ErrNotFound].
+func (api API) BlastRadiusDiff(ctx context.Context, typ, identifier string, from, to time.Time) (BlastRadiusDiff, error) {
+	fromSnap, err := api.snapshotAt(ctx, from)
+	if err != nil {
+		return BlastRadiusDiff{}, fmt.Errorf("could not get snapshot at %v: %w", from, err)
+	}
+
+	toSnap, err := api.snapshotAt(ctx, to)
+	if err != nil {
+		return BlastRadiusDiff{}, fmt.Errorf("could not get snapshot at %v: %w", to, err)
+	}
+
+	fromSet, fromFound, err := api.netReachableSet(ctx, typ, identifier, fromSnap.id)
+	if err != nil {
+		return BlastRadiusDiff{}, fmt.Errorf("could not get reachable resources in snapshot %q: %w", fromSnap.id, err)
+	}
+
+	toSet, toFound, err := api.netReachableSet(ctx, typ, identifier, toSnap.id)
+	if err != nil {
+		return BlastRadiusDiff{}, fmt.Errorf("could not get reachable resources in snapshot %q: %w", toSnap.id, err)
+	}
+
+	if !fromFound && !toFound {
+		return BlastRadiusDiff{}, ErrNotFound
+	}
+
+	diff := BlastRadiusDiff{
+		From:      Snapshot{ID: fromSnap.id, Timestamp: fromSnap.timestamp},
+		To:        Snapshot{ID: toSnap.id, Timestamp: toSnap.timestamp},
+		Added:     []DiffResource{},
+		Removed:   []DiffResource{},
+		Unchanged: []DiffResource{},
+	}
+
+	for key, rscs := range toSet {
+		if _, ok := fromSet[key]; ok {
+			dr := newDiffResource(rscs)
+			dr.Rules = []PathVertex{}
+			diff.Unchanged = append(diff.Unchanged, dr)
+			continue
+		}
+		diff.Added = append(diff.Added, newDiffResource(rscs))
+	}
+
+	for key, rscs := range fromSet {
+		if _, ok := toSet[key]; ok {
+			continue
+		}
+		diff.Removed = append(diff.Removed, newDiffResource(rscs))
+	}
+
+	for _, drs := range [][]DiffResource{diff.Added, diff.Removed, diff.Unchanged} {
+		sort.Slice(drs, func(i, j int) bool {
+			return drs[i].Key < drs[j].Key
+		})
+	}
+
+	return diff, nil
+}
+
+// netReachableSet returns the resources reachable through the network from
+// an asset in the altimeter snapshot sid, grouped by key. Resources that do
+// not contribute to the blast radius score are ignored. found is false if the
+// snapshot does not contain the asset.
+func (api API) netReachableSet(ctx context.Context, typ, identifier, sid string) (set map[string][]resource, found bool, err error) {
+	vid, _, err := api.resolveAsset(ctx, typ, identifier, sid)
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("could not resolve asset: %w", err)
+	}
+
+	rscs, err := api.netReachableResources(ctx, vid, true)
+	if err != nil {
+		return nil, false, err
+	}
+
+	set = make(map[string][]resource)
+	for _, rsc := range rscs {
+		if (netScorer{}).Weight(rsc.label) == 0 {
+			continue
+		}
+		set[rsc.key] = append(set[rsc.key], rsc)
+	}
+
+	return set, true, nil
+}
+
+// ruleLabels contains the labels of the security group rule vertices.
+var ruleLabels = map[string]bool{
+	"ingress_rule": true,
+	"egress_rule":  true,
+}
+
+// newDiffResource returns the [DiffResource] corresponding to the provided
+// resources. They must share the same key. The returned rules are the rule
+// vertices of all their paths.
+func newDiffResource(rscs []resource) DiffResource {
+	dr := DiffResource{
+		Key:   rscs[0].key,
+		ID:    rscs[0].id,
+		Label: rscs[0].label,
+		Rules: []PathVertex{},
+	}
+
+	seen := make(map[string]bool)
+	for _, rsc := range rscs {
+		for _, pv := range rsc.path {
+			if !ruleLabels[pv.Label] || seen[pv.ID] {
+				continue
+			}
+			seen[pv.ID] = true
+			dr.Rules = append(dr.Rules, pv)
+		}
+	}
+
+	sort.Slice(dr.Rules, func(i, j int) bool {
+		return dr.Rules[i].ID < dr.Rules[j].ID
+	})
+
+	return dr
+}
+
+// InternetExposureResult represents the internet exposure of an asset.
+type InternetExposureResult struct {
+	// Exposed reports whether the asset is reachable from the internet,
+	// either directly or through a load balancer.
+	Exposed bool `json:"exposed"`
+
+	// Public reports whether the asset has a public IP address or is an
+	// internet-facing load balancer.
+	Public bool `json:"public"`
+
+	// Rules contains the ingress rules of the asset that allow traffic
+	// from any address. They only expose the asset if it is public.
+	Rules []ExposureRule `json:"rules"`
+
+	// LoadBalancers contains the internet-facing load balancers that
+	// are allowed to send traffic to the asset and are reachable from
+	// any address.
+	LoadBalancers []ExposedLoadBalancer `json:"load_balancers"`
+}
+
+// ExposureRule represents an ingress rule that allows traffic from any
+// address.
+type ExposureRule struct {
+	// SecurityGroupID is the vertex ID of the security group.
+	SecurityGroupID string `json:"security_group_id"`
+
+	// IngressRuleID is the vertex ID of the ingress rule.
+	IngressRuleID string `json:"ingress_rule_id"`
+
+	// Protocol is the IP protocol allowed by the rule. "-1" means all
+	// protocols.
+	Protocol string `json:"protocol"`
+
+	// FromPort is the start of the port range allowed by the rule. It
+	// is -1 if the rule does not define a port range.
+	FromPort int64 `json:"from_port"`
+
+	// ToPort is the end of the port range allowed by the rule. It is
+	// -1 if the rule does not define a port range.
+	ToPort int64 `json:"to_port"`
+
+	// CIDR is the IP range allowed by the rule.
+	CIDR string `json:"cidr"`
+}
+
+// ExposedLoadBalancer represents an internet-facing load balancer that is
+// reachable from any address.
+type ExposedLoadBalancer struct {
+	// ID is the vertex ID of the load balancer.
+	ID string `json:"id"`
+
+	// Label is the vertex label of the load balancer.
+	Label string `json:"label"`
+
+	// DNSName is the DNS name of the load balancer.
+	DNSName string `json:"dns_name"`
+
+	// Rules contains the ingress rules of the load balancer that allow
+	// traffic from any address.
+	Rules []ExposureRule `json:"rules"`
+}
+
+// internetCIDRs contains the IP ranges that match any address.
+var internetCIDRs = []string{"0.0.0.0/0", "::/0"}
+
+// InternetExposure returns the internet exposure of a given asset. That is,
+// whether the asset is reachable from any address, through which ingress
+// rules and through which load balancers.
+func (api API) InternetExposure(ctx context.Context, typ, identifier string) (InternetExposureResult, error) {
+	vid, _, err := api.resolveAsset(ctx, typ, identifier, "")
+	if err != nil {
+		return InternetExposureResult{}, fmt.Errorf("could not resolve asset: %w", err)
+	}
+
+	return api.internetExposure(ctx, vid)
+}
+
+// internetExposure returns the internet exposure of a vertex ID.
+func (api API) internetExposure(ctx context.Context, vid string) (InternetExposureResult, error) {
+	public, err := api.isPublic(ctx, vid)
+	if err != nil {
+		return InternetExposureResult{}, fmt.Errorf("could not check if the asset is public: %w", err)
+	}
+
+	rules, err := api.internetIngressRules(ctx, vid)
+	if err != nil {
+		return InternetExposureResult{}, fmt.Errorf("could not get ingress rules: %w", err)
+	}
+
+	lbs, err := api.frontingLoadBalancers(ctx, vid)
+	if err != nil {
+		return InternetExposureResult{}, fmt.Errorf("could not get load balancers: %w", err)
+	}
+
+	result := InternetExposureResult{
+		Exposed:       public && len(rules) > 0,
+		Public:        public,
+		Rules:         rules,
+		LoadBalancers: []ExposedLoadBalancer{},
+	}
+
+	for _, lb := range lbs {
+		lb.Rules, err = api.internetIngressRules(ctx, lb.ID)
+		if err != nil {
+			return InternetExposureResult{}, fmt.Errorf("could not get ingress rules of load balancer %q: %w", lb.ID, err)
+		}
+
+		if len(lb.Rules) == 0 {
+			continue
+		}
+
+		result.Exposed = true
+		result.LoadBalancers = append(result.LoadBalancers, lb)
+	}
+
+	return result, nil
+}
+
+// isPublic reports whether the vertex has a public IP address or is an
+// internet-facing load balancer.
+func (api API) isPublic(ctx context.Context, vid string) (bool, error) {
+	results, err := api.conn.Query(ctx, "is_public", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		t := g
+
+		if api.cfg.ResolveTimeoutMs > 0 {
+			t = t.With("evaluationTimeout", api.cfg.ResolveTimeoutMs)
+		}
+
+		return t.
+			V(vid).
+			Or(
+				gremlingo.T__.Has("public_ip"),
+				gremlingo.T__.Has("public_ip_address"),
+				gremlingo.T__.Has("scheme", "internet-facing"),
+			).
+			Id().
+			ToList()
+	})
+	if err != nil {
+		return false, fmt.Errorf("query error: %w", err)
+	}
+
+	return len(results) > 0, nil
+}
+
+// internetIngressRules returns the ingress rules of the security groups of a
+// vertex that allow traffic from any address.
+func (api API) internetIngressRules(ctx context.Context, vid string) ([]ExposureRule, error) {
+	results, err := api.conn.Query(ctx, "internet_ingress_rules", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		t := g
+
+		if api.cfg.BlastRadiusTimeoutMs > 0 {
+			t = t.With("evaluationTimeout", api.cfg.BlastRadiusTimeoutMs)
+		}
+
+		return t.
+			V(vid).
+			Union(
+				// The asset can be a security group itself.
+				gremlingo.T__.Identity(),
+				gremlingo.T__.OutE("resource_link").InV(),
+				gremlingo.T__.OutE("transient_resource_link").InV(),
+			).
+			HasLabel("ec2:security-group").As("sg").
+			OutE("ingress_rule").InV().HasLabel("ingress_rule").As("rule").
+			Union(
+				gremlingo.T__.
+					OutE("ip_range").InV().HasLabel("ip_range").
+					Values("cidr_ip"),
+				gremlingo.T__.
+					OutE("ipv6_range").InV().HasLabel("ipv6_range").
+					Values("cidr_ipv6"),
+			).
+			Is(gremlingo.P.Within(toAnySlice(internetCIDRs)...)).
+			Project("security_group_id", "ingress_rule_id", "protocol", "from_port", "to_port", "cidr").
+			By(gremlingo.T__.Select("sg").Id()).
+			By(gremlingo.T__.Select("rule").Id()).
+			By(gremlingo.T__.Select("rule").Coalesce(gremlingo.T__.Values("ip_protocol"), gremlingo.T__.Constant("-1"))).
+			By(gremlingo.T__.Select("rule").Coalesce(gremlingo.T__.Values("from_port"), gremlingo.T__.Constant(-1))).
+			By(gremlingo.T__.Select("rule").Coalesce(gremlingo.T__.Values("to_port"), gremlingo.T__.Constant(-1))).
+			By(gremlingo.T__.Identity()).
+			ToList()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	rules := []ExposureRule{}
+	for _, result := range results {
+		m, err := resultMap(result)
+		if err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+
+		var rule ExposureRule
+		if rule.SecurityGroupID, err = mapString(m, "security_group_id"); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		if rule.IngressRuleID, err = mapString(m, "ingress_rule_id"); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		if rule.Protocol, err = mapString(m, "protocol"); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		if rule.FromPort, err = mapInt(m, "from_port"); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		if rule.ToPort, err = mapInt(m, "to_port"); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		if rule.CIDR, err = mapString(m, "cidr"); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].IngressRuleID != rules[j].IngressRuleID {
+			return rules[i].IngressRuleID < rules[j].IngressRuleID
+		}
+		return rules[i].CIDR < rules[j].CIDR
+	})
+
+	return rules, nil
+}
+
+// frontingLoadBalancers returns the internet-facing load balancers whose
+// security groups are allowed to send traffic to a vertex by its ingress
+// rules. The returned load balancers do not include their rules.
+func (api API) frontingLoadBalancers(ctx context.Context, vid string) ([]ExposedLoadBalancer, error) {
+	results, err := api.conn.Query(ctx, "fronting_load_balancers", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		t := g
 
-	// BlastRadiusTimeoutMs is the query timeout in ms used when
-	// calculating the blast radius score. If zero, no timeout is set.
-	BlastRadiusTimeoutMs int
+		if api.cfg.BlastRadiusTimeoutMs > 0 {
+			t = t.With("evaluationTimeout", api.cfg.BlastRadiusTimeoutMs)
+		}
+
+		return t.
+			V(vid).
+			Union(
+				gremlingo.T__.Identity(),
+				gremlingo.T__.OutE("resource_link").InV(),
+				gremlingo.T__.OutE("transient_resource_link").InV(),
+			).
+			HasLabel("ec2:security-group").
+			OutE("ingress_rule").InV().HasLabel("ingress_rule").
+			OutE("user_id_group_pairs").InV().HasLabel("user_id_group_pairs").
+			OutE("resource_link").InV().HasLabel("ec2:security-group").
+			InE("resource_link").OutV().HasLabel("elbv1:loadbalancer", "elbv2:loadbalancer").
+			Has("scheme", "internet-facing").
+			Dedup().
+			Project("id", "label", "dns_name").
+			By(gremlingo.T__.Id()).
+			By(gremlingo.T__.Label()).
+			By(gremlingo.T__.Coalesce(gremlingo.T__.Values("dns_name"), gremlingo.T__.Constant(""))).
+			ToList()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	var lbs []ExposedLoadBalancer
+	for _, result := range results {
+		m, err := resultMap(result)
+		if err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+
+		var lb ExposedLoadBalancer
+		if lb.ID, err = mapString(m, "id"); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		if lb.Label, err = mapString(m, "label"); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		if lb.DNSName, err = mapString(m, "dns_name"); err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		lbs = append(lbs, lb)
+	}
+
+	sort.Slice(lbs, func(i, j int) bool {
+		return lbs[i].ID < lbs[j].ID
+	})
+
+	return lbs, nil
 }
 
-// API implements the Intel API of the Security Graph.
-type API struct {
-	cfg      Config
-	conn     gremlin.Connection
-	resolver *net.Resolver
+// Asset identifies an asset by its type and identifier.
+type Asset struct {
+	// Type is the type of the asset.
+	Type string `json:"asset_type"`
+
+	// Identifier is the identifier of the asset.
+	Identifier string `json:"asset_identifier"`
 }
 
-// NewAPI creates a new intel API using the given config.
-func NewAPI(cfg Config) (API, error) {
-	conn, err := gremlin.NewConnection(cfg.GremlinConfig)
+// BlastRadiusBatchResult represents the result of calculating the blast
+// radius score of an asset that is part of a batch.
+type BlastRadiusBatchResult struct {
+	// Asset is the asset.
+	Asset Asset
+
+	// Result contains the blast radius of the asset. It is only valid
+	// if Err is nil.
+	Result BlastRadiusResult
+
+	// Err is the error returned when calculating the blast radius of
+	// the asset.
+	Err error
+}
+
+// BlastRadiusBatch returns the blast radius of every provided asset. The
+// returned results are in the same order as assets. The blast radius of up to
+// [Config.BatchConcurrency] assets is calculated concurrently. If ctx is done,
+// the assets that have not been processed yet report the context error.
+func (api API) BlastRadiusBatch(ctx context.Context, assets []Asset) []BlastRadiusBatchResult {
+	results := make([]BlastRadiusBatchResult, len(assets))
+	sem := make(chan struct{}, api.batchConcurrency())
+
+	var wg sync.WaitGroup
+	for i, asset := range assets {
+		select {
+		case <-ctx.Done():
+			results[i] = BlastRadiusBatchResult{
+				Asset: asset,
+				Err:   ctx.Err(),
+			}
+			continue
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(i int, asset Asset) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			br, err := api.BlastRadius(ctx, asset.Type, asset.Identifier, BlastRadiusOptions{})
+			results[i] = BlastRadiusBatchResult{
+				Asset:  asset,
+				Result: br,
+				Err:    err,
+			}
+		}(i, asset)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// batchConcurrency returns the maximum number of assets or snapshots
+// processed concurrently by the batch and trend requests.
+func (api API) batchConcurrency() int {
+	if api.cfg.BatchConcurrency <= 0 {
+		return DefaultBatchConcurrency
+	}
+	return api.cfg.BatchConcurrency
+}
+
+// resourceIDLabels maps the prefixes of the supported AWS resource IDs to the
+// label of the corresponding vertices.
+var resourceIDLabels = map[string]string{
+	"i-":   "ec2:instance",
+	"eni-": "ec2:network-interface",
+	"sg-":  "ec2:security-group",
+}
+
+// resolveAssetAt returns the vertex ID of an asset identified by its type and
+// identifier in the latest altimeter snapshot at or before at, along with the
+// method used to resolve it. If at is zero, the asset is resolved in the
+// latest snapshot that contains it.
+func (api API) resolveAssetAt(ctx context.Context, typ, identifier string, at time.Time) (vid, method string, err error) {
+	if at.IsZero() {
+		return api.resolveAsset(ctx, typ, identifier, "")
+	}
+
+	snap, err := api.snapshotAt(ctx, at)
 	if err != nil {
-		return API{}, fmt.Errorf("could not create a Gremlin connection: %w", err)
+		return "", "", fmt.Errorf("could not get snapshot at %v: %w", at, err)
 	}
 
-	api := API{
-		conn:     conn,
-		resolver: &net.Resolver{PreferGo: true},
+	return api.resolveAsset(ctx, typ, identifier, snap.id)
+}
+
+// snapshotAt returns the latest altimeter snapshot at or before the provided
+// instant. Snapshot timestamps are Unix times in seconds.
+func (api API) snapshotAt(ctx context.Context, at time.Time) (snapshot, error) {
+	results, err := api.conn.Query(ctx, "snapshot", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		t := g
+
+		if api.cfg.ResolveTimeoutMs > 0 {
+			t = t.With("evaluationTimeout", api.cfg.ResolveTimeoutMs)
+		}
+
+		return t.
+			V().
+			HasLabel("Universe").Has("namespace", "altimeter").Has("version", 1).
+			Out("universe_of").HasLabel("altimeter_snapshot").
+			Has("timestamp", gremlingo.P.Lte(at.Unix())).
+			Order().By("timestamp", gremlingo.Order.Desc).
+			Limit(1).
+			Project("id", "timestamp").
+			By(gremlingo.T__.Id()).
+			By(gremlingo.T__.Values("timestamp")).
+			ToList()
+	})
+	if err != nil {
+		return snapshot{}, fmt.Errorf("query error: %w", err)
 	}
-	return api, nil
+
+	if len(results) == 0 {
+		return snapshot{}, ErrNotFound
+	}
+
+	snap, err := parseSnapshot(results[0])
+	if err != nil {
+		return snapshot{}, fmt.Errorf("invalid result: %w", err)
+	}
+
+	return snap, nil
 }
 
-// BlastRadiusResult represents the result of calculating the blast radius
-// score for a given asset.
-type BlastRadiusResult struct {
-	// Score contains the blast radius score for a given asset.
-	Score float64 `json:"score"`
+// vertexSnapshot returns the altimeter snapshot that includes a vertex ID.
+// If several snapshots include it, the latest one is returned.
+func (api API) vertexSnapshot(ctx context.Context, vid string) (snapshot, error) {
+	results, err := api.conn.Query(ctx, "snapshot", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		t := g
 
-	// Metadata contains information about how a blast radius was
-	// calculated.
-	Metadata string `json:"metadata"`
+		if api.cfg.ResolveTimeoutMs > 0 {
+			t = t.With("evaluationTimeout", api.cfg.ResolveTimeoutMs)
+		}
+
+		return t.
+			V(vid).
+			In("includes").HasLabel("altimeter_snapshot").
+			Order().By("timestamp", gremlingo.Order.Desc).
+			Limit(1).
+			Project("id", "timestamp").
+			By(gremlingo.T__.Id()).
+			By(gremlingo.T__.Values("timestamp")).
+			ToList()
+	})
+	if err != nil {
+		return snapshot{}, fmt.Errorf("query error: %w", err)
+	}
+
+	if len(results) == 0 {
+		return snapshot{}, ErrNotFound
+	}
+
+	snap, err := parseSnapshot(results[0])
+	if err != nil {
+		return snapshot{}, fmt.Errorf("invalid result: %w", err)
+	}
+
+	return snap, nil
 }
 
-// BlastRadius returns the blast radius of a given asset. It returns a
-// [BlastRadiusResult] with the score and the metadata about how score was
-// calculated.
-func (api API) BlastRadius(typ, identifier string) (BlastRadiusResult, error) {
-	vid, err := api.resolveAsset(typ, identifier)
+// snapshot represents an altimeter snapshot.
+type snapshot struct {
+	id        string
+	timestamp time.Time
+}
+
+// snapshots returns up to limit altimeter snapshots between from and to, both
+// included, ordered by timestamp.
+func (api API) snapshots(ctx context.Context, from, to time.Time, limit int) ([]snapshot, error) {
+	results, err := api.conn.Query(ctx, "snapshots", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		t := g
+
+		if api.cfg.ResolveTimeoutMs > 0 {
+			t = t.With("evaluationTimeout", api.cfg.ResolveTimeoutMs)
+		}
+
+		return t.
+			V().
+			HasLabel("Universe").Has("namespace", "altimeter").Has("version", 1).
+			Out("universe_of").HasLabel("altimeter_snapshot").
+			Has("timestamp", gremlingo.P.Gte(from.Unix())).
+			Has("timestamp", gremlingo.P.Lte(to.Unix())).
+			Order().By("timestamp", gremlingo.Order.Asc).
+			Limit(limit).
+			Project("id", "timestamp").
+			By(gremlingo.T__.Id()).
+			By(gremlingo.T__.Values("timestamp")).
+			ToList()
+	})
 	if err != nil {
-		return BlastRadiusResult{}, fmt.Errorf("could not resolve asset: %w", err)
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	var snaps []snapshot
+	for _, result := range results {
+		snap, err := parseSnapshot(result)
+		if err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		snaps = append(snaps, snap)
 	}
 
-	score, err := api.netBlastRadius(vid)
+	return snaps, nil
+}
+
+// parseSnapshot parses a Gremlin result that contains the id and timestamp
+// of an altimeter snapshot.
+func parseSnapshot(result *gremlingo.Result) (snapshot, error) {
+	m, err := resultMap(result)
 	if err != nil {
-		return BlastRadiusResult{}, fmt.Errorf("could not calculate net blast radius: %w", err)
+		return snapshot{}, err
 	}
 
-	result := BlastRadiusResult{
-		Score:    score,
-		Metadata: `net`,
+	id, err := mapString(m, "id")
+	if err != nil {
+		return snapshot{}, err
+	}
+	ts, err := mapInt(m, "timestamp")
+	if err != nil {
+		return snapshot{}, err
 	}
 
-	return result, nil
+	return snapshot{id: id, timestamp: time.Unix(ts, 0).UTC()}, nil
 }
 
 // resolveAsset returns the vertex ID of an asset identified by its type and
-// identifier.
-func (api API) resolveAsset(typ, identifier string) (vid string, err error) {
+// identifier, along with the method used to resolve it. If sid is not empty,
+// the asset is resolved in the altimeter snapshot with that vertex ID.
+// Otherwise, the latest snapshot that contains the asset is used.
+func (api API) resolveAsset(ctx context.Context, typ, identifier, sid string) (vid, method string, err error) {
 	switch typ {
 	case "IP":
-		return api.resolveIP(identifier)
+		vid, err = api.resolveIP(ctx, identifier, sid)
 	case "Hostname":
-		vid, err = api.resolveHostname(identifier)
+		vid, err = api.resolveHostname(ctx, identifier, sid)
 		if err == nil {
-			return vid, nil
+			return vid, ResolutionDirect, nil
 		}
 
-		log.Debug.Printf("graph-intel-api: intel: could not find hostname %q: fallback to DNS lookup", identifier)
+		log.FromContext(ctx).Debug.Printf("graph-intel-api: intel: could not find hostname %q: fallback to DNS lookup", identifier)
 
-		ips, err := api.resolver.LookupHost(context.Background(), identifier)
+		ips, err := api.resolver.LookupHost(ctx, identifier)
 		if err != nil {
-			return "", fmt.Errorf("DNS lookup error for %q: %w", identifier, err)
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+				return "", "", fmt.Errorf("%w: DNS lookup for %q: %v", ErrNotFound, identifier, err)
+			}
+			return "", "", fmt.Errorf("DNS lookup error for %q: %w", identifier, err)
 		}
 
 		for _, ip := range ips {
-			vid, err = api.resolveIP(ip)
+			vid, err = api.resolveIP(ctx, ip, sid)
 			if err == nil {
-				return vid, nil
+				return vid, ResolutionDNS, nil
 			}
-			if err != ErrNotFound {
+			if !errors.Is(err, ErrNotFound) {
 				// Unexpected error. Abort DNS fallback.
 				break
 			}
 		}
-		return vid, err
+		return vid, "", err
+	case "ARN":
+		vid, err = api.resolveARN(ctx, identifier, sid)
+	case "ResourceID":
+		vid, err = api.resolveResourceID(ctx, identifier, sid)
 	default:
-		return "", fmt.Errorf("unsupported asset type: %v", typ)
+		return "", "", fmt.Errorf("%w: %v", ErrUnsupportedAssetType, typ)
+	}
+	if err != nil {
+		return "", "", err
 	}
+	return vid, ResolutionDirect, nil
 }
 
 // resolveHostname returns de vertex ID of a given hostname.
-func (api API) resolveHostname(hostname string) (vid string, err error) {
-	results, err := api.conn.Query(func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
-		t := g
+func (api API) resolveHostname(ctx context.Context, hostname, sid string) (vid string, err error) {
+	return api.resolveVertex(ctx, sid, hostnameTraversal(hostname))
+}
 
-		if api.cfg.ResolveTimeoutMs > 0 {
-			t = t.With("evaluationTimeout", api.cfg.ResolveTimeoutMs)
-		}
+// resolveIP returns de vertex ID of a given IP.
+func (api API) resolveIP(ctx context.Context, ip, sid string) (vid string, err error) {
+	return api.resolveVertex(ctx, sid, ipTraversal(ip))
+}
 
-		return t.
+// resolveARN returns de vertex ID of a given AWS ARN.
+func (api API) resolveARN(ctx context.Context, arn, sid string) (vid string, err error) {
+	return api.resolveVertex(ctx, sid, arnTraversal(arn))
+}
+
+// resolveResourceID returns de vertex ID of a given AWS resource ID (e.g.
+// "i-0123456789abcdef0"). The supported resource IDs are defined by
+// [resourceIDLabels].
+func (api API) resolveResourceID(ctx context.Context, id, sid string) (vid string, err error) {
+	tf, err := resourceIDTraversal(id)
+	if err != nil {
+		return "", err
+	}
+	return api.resolveVertex(ctx, sid, tf)
+}
+
+// hostnameTraversal returns a function that selects the vertices with a
+// given hostname.
+func hostnameTraversal(hostname string) func(g *gremlingo.GraphTraversalSource) *gremlingo.GraphTraversal {
+	return func(g *gremlingo.GraphTraversalSource) *gremlingo.GraphTraversal {
+		return g.
 			V().
 			HasLabel(
 				"ec2:instance",
@@ -150,46 +1384,86 @@ func (api API) resolveHostname(hostname string) (vid string, err error) {
 				gremlingo.T__.Has("dns_name", hostname).HasLabel("elbv1:loadbalancer"),
 
 				gremlingo.T__.Has("dns_name", hostname).HasLabel("elbv2:loadbalancer"),
-			).
-			As("assets").
-			In("includes").HasLabel("altimeter_snapshot").As("snapshots").
-			In("universe_of").HasLabel("Universe").Has("namespace", "altimeter").Has("version", 1).
-			Select("assets").
-			Order().By(gremlingo.T__.Select("snapshots").Values("timestamp"), gremlingo.Order.Desc).
-			Limit(1).
-			Id().
-			ToList()
-	})
-	if err != nil {
-		return "", fmt.Errorf("query error: %w", err)
+			)
 	}
+}
 
-	if len(results) == 0 {
-		return "", ErrNotFound
+// ipTraversal returns a function that selects the vertices with a given IP.
+func ipTraversal(ip string) func(g *gremlingo.GraphTraversalSource) *gremlingo.GraphTraversal {
+	return func(g *gremlingo.GraphTraversalSource) *gremlingo.GraphTraversal {
+		return g.
+			V().
+			HasLabel("ec2:network-interface").
+			Has("status", "in-use").
+			Or(
+				gremlingo.T__.Has("public_ip", ip),
+				gremlingo.T__.Has("private_ip_address", ip),
+			)
 	}
+}
 
-	return results[0].GetString(), nil
+// arnTraversal returns a function that selects the vertices with a given
+// AWS ARN.
+func arnTraversal(arn string) func(g *gremlingo.GraphTraversalSource) *gremlingo.GraphTraversal {
+	return func(g *gremlingo.GraphTraversalSource) *gremlingo.GraphTraversal {
+		return g.
+			V().
+			Has("arn", arn)
+	}
 }
 
-// resolveIP returns de vertex ID of a given IP.
-func (api API) resolveIP(ip string) (vid string, err error) {
-	results, err := api.conn.Query(func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+// resourceIDTraversal returns a function that selects the vertices with a
+// given AWS resource ID. If the resource ID is not supported, it returns
+// [ErrUnsupportedAssetType].
+func resourceIDTraversal(id string) (func(g *gremlingo.GraphTraversalSource) *gremlingo.GraphTraversal, error) {
+	var label string
+	for prefix, l := range resourceIDLabels {
+		if strings.HasPrefix(id, prefix) {
+			label = l
+			break
+		}
+	}
+	if label == "" {
+		return nil, fmt.Errorf("%w: unknown resource ID %q", ErrUnsupportedAssetType, id)
+	}
+
+	tf := func(g *gremlingo.GraphTraversalSource) *gremlingo.GraphTraversal {
+		// ARNs of the supported resources end with
+		// "<resource-type>/<resource-id>".
+		return g.
+			V().
+			HasLabel(label).
+			Has("arn", gremlingo.TextP.EndingWith("/"+id))
+	}
+	return tf, nil
+}
+
+// resolveVertex returns the vertex ID of the asset selected by the traversal
+// returned by tf. If sid is not empty, only the assets included in the
+// altimeter snapshot with that vertex ID are considered. If the traversal
+// selects assets in multiple altimeter snapshots, the asset in the latest
+// snapshot is returned.
+//
+// Altimeter does not share vertices between snapshots, so traversals starting
+// from the returned vertex only visit vertices of its snapshot.
+func (api API) resolveVertex(ctx context.Context, sid string, tf func(g *gremlingo.GraphTraversalSource) *gremlingo.GraphTraversal) (vid string, err error) {
+	results, err := api.conn.Query(ctx, "resolve", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
 		t := g
 
 		if api.cfg.ResolveTimeoutMs > 0 {
 			t = t.With("evaluationTimeout", api.cfg.ResolveTimeoutMs)
 		}
 
-		return t.
-			V().
-			HasLabel("ec2:network-interface").
-			Has("status", "in-use").
-			Or(
-				gremlingo.T__.Has("public_ip", ip),
-				gremlingo.T__.Has("private_ip_address", ip),
-			).
+		traversal := tf(t).
 			As("assets").
-			In("includes").HasLabel("altimeter_snapshot").As("snapshots").
+			In("includes").HasLabel("altimeter_snapshot")
+
+		if sid != "" {
+			traversal = traversal.HasId(sid)
+		}
+
+		return traversal.
+			As("snapshots").
 			In("universe_of").HasLabel("Universe").Has("namespace", "altimeter").Has("version", 1).
 			Select("assets").
 			Order().By(gremlingo.T__.Select("snapshots").Values("timestamp"), gremlingo.Order.Desc).
@@ -208,19 +1482,28 @@ func (api API) resolveIP(ip string) (vid string, err error) {
 	return results[0].GetString(), nil
 }
 
-// netBlastRadius returns the Network Blast Radius score calculated from a
-// vertex ID.
-func (api API) netBlastRadius(vid string) (float64, error) {
-	results, err := api.conn.Query(func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+// netReachableResources returns the resources reachable through the network
+// from a vertex ID. If withPath is true, the path followed to reach every
+// resource is also returned. A resource is returned once per path reaching
+// it.
+func (api API) netReachableResources(ctx context.Context, vid string, withPath bool) ([]resource, error) {
+	results, err := api.conn.Query(ctx, "net_reachable_resources", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
 		t := g
 
 		if api.cfg.BlastRadiusTimeoutMs > 0 {
 			t = t.With("evaluationTimeout", api.cfg.BlastRadiusTimeoutMs)
 		}
 
-		return t.
+		keys := []any{"id", "label", "key", "steps"}
+		if withPath {
+			keys = append(keys, "path")
+		}
+
+		traversal := t.
 			V(vid).
 			Union(
+				// The asset can be a security group itself.
+				gremlingo.T__.Identity(),
 				gremlingo.T__.OutE("resource_link").InV(),
 				gremlingo.T__.OutE("transient_resource_link").InV(),
 			).
@@ -249,38 +1532,46 @@ func (api API) netBlastRadius(vid string) (float64, error) {
 			).
 			Times(maxQueryDepth).
 			Emit().
-			Project("id", "label", "steps").
+			Project(keys...).
 			By(gremlingo.T__.Id()).
 			By(gremlingo.T__.Label()).
-			By(gremlingo.T__.Path().Count(gremlingo.Scope.Local)).
-			ToList()
+			By(gremlingo.T__.Coalesce(
+				gremlingo.T__.Values("arn"),
+				gremlingo.T__.Values("cidr_ip"),
+				gremlingo.T__.Values("cidr_ipv6"),
+				gremlingo.T__.Id(),
+			)).
+			By(gremlingo.T__.Path().Count(gremlingo.Scope.Local))
+
+		if withPath {
+			traversal = traversal.By(gremlingo.T__.Path())
+		}
+
+		return traversal.ToList()
 	})
 	if err != nil {
-		return 0, fmt.Errorf("query error: %w", err)
+		return nil, fmt.Errorf("query error: %w", err)
 	}
 
-	score := 0.0
+	var rscs []resource
 	for _, result := range results {
 		rsc, err := parseResource(result)
 		if err != nil {
-			return 0, fmt.Errorf("invalid result: %w", err)
-		}
-
-		weight := 1.0
-		if rsc.label == "ec2:security-group" {
-			weight = 0.0
+			return nil, fmt.Errorf("invalid result: %w", err)
 		}
-		score += (1.0 / rsc.steps) * weight
+		rscs = append(rscs, rsc)
 	}
 
-	return score, nil
+	return rscs, nil
 }
 
 // resource represents a parsed result of the Blast Radius query.
 type resource struct {
 	id    string
 	label string
+	key   string
 	steps float64
+	path  []PathVertex
 }
 
 // parseResource parses a Gremlin result returned by the Blast Radius query.
@@ -313,12 +1604,28 @@ func parseResource(result *gremlingo.Result) (resource, error) {
 				return resource{}, errors.New("label is not a string")
 			}
 			r.label = label
+		case "key":
+			key, ok := v.(string)
+			if !ok {
+				return resource{}, errors.New("key is not a string")
+			}
+			r.key = key
 		case "steps":
 			steps, ok := v.(int64)
 			if !ok {
 				return resource{}, errors.New("steps is not an int64")
 			}
 			r.steps = float64(steps)
+		case "path":
+			path, ok := v.(*gremlingo.Path)
+			if !ok {
+				return resource{}, errors.New("path is not a path")
+			}
+			pvs, err := parsePath(path)
+			if err != nil {
+				return resource{}, fmt.Errorf("invalid path: %w", err)
+			}
+			r.path = pvs
 		default:
 			return resource{}, fmt.Errorf("unknown key %q", sk)
 		}
@@ -326,3 +1633,76 @@ func parseResource(result *gremlingo.Result) (resource, error) {
 
 	return r, nil
 }
+
+// parsePath returns the vertices contained in a Gremlin path. Edges are
+// ignored.
+func parsePath(path *gremlingo.Path) ([]PathVertex, error) {
+	var pvs []PathVertex
+	for _, obj := range path.Objects {
+		v, ok := obj.(*gremlingo.Vertex)
+		if !ok {
+			continue
+		}
+
+		id, ok := v.Id.(string)
+		if !ok {
+			return nil, errors.New("vertex id is not a string")
+		}
+		pvs = append(pvs, PathVertex{ID: id, Label: v.Label})
+	}
+	return pvs, nil
+}
+
+// resultMap returns the map contained in a Gremlin result.
+func resultMap(result *gremlingo.Result) (map[string]any, error) {
+	obj := result.GetInterface()
+
+	m, ok := obj.(map[any]any)
+	if !ok {
+		return nil, errors.New("invalid result type")
+	}
+
+	sm := make(map[string]any, len(m))
+	for k, v := range m {
+		sk, ok := k.(string)
+		if !ok {
+			return nil, errors.New("key is not a string")
+		}
+		sm[sk] = v
+	}
+	return sm, nil
+}
+
+// mapString returns the string value of key in m.
+func mapString(m map[string]any, key string) (string, error) {
+	v, ok := m[key].(string)
+	if !ok {
+		return "", fmt.Errorf("%s is not a string", key)
+	}
+	return v, nil
+}
+
+// mapInt returns the integer value of key in m.
+func mapInt(m map[string]any, key string) (int64, error) {
+	switch v := m[key].(type) {
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("%s is not an integer", key)
+	}
+}
+
+// toAnySlice converts a slice of strings into a slice of empty interfaces.
+func toAnySlice(s []string) []any {
+	as := make([]any, len(s))
+	for i, v := range s {
+		as[i] = v
+	}
+	return as
+}
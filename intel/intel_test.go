@@ -1,67 +1,873 @@
 package intel
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/adevinta/graph-intel-api/gremlin"
+	"github.com/adevinta/graph-intel-api/scenario"
 
 	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
-const gremlinEndpoint = "ws://127.0.0.1:8182/gremlin"
+// gremlinEndpoint and gremlinAuthMode configure the Gremlin backend used by
+// the tests. By default, the tests use an in-memory graph. If the
+// GREMLIN_TEST_ENDPOINT environment variable is set, they use the Gremlin
+// server listening on that endpoint.
+var gremlinEndpoint, gremlinAuthMode = gremlinTestBackend()
+
+func gremlinTestBackend() (endpoint, authMode string) {
+	if endpoint := os.Getenv("GREMLIN_TEST_ENDPOINT"); endpoint != "" {
+		return endpoint, "plain"
+	}
+	return "", "memory"
+}
 
 var wantBlastRadiusResult = BlastRadiusResult{
-	Score:    0.3106893106893107,
-	Metadata: "net",
+	Score: 0.3106893106893107,
+	Metadata: Metadata{
+		Model:        "net",
+		ModelVersion: "1",
+		VertexID:     "ni0",
+		Resolution:   ResolutionDirect,
+		Snapshot:     Snapshot{ID: "s0", Timestamp: time.Unix(0, 0).UTC()},
+	},
 }
 
+// ignoreDuration ignores the query duration reported in the metadata of the
+// results, which is not deterministic.
+var ignoreDuration = cmpopts.IgnoreFields(Metadata{}, "DurationMs")
+
 func setupBlastRadiusGraph() error {
+	return setupScenario("testdata/blast-radius.yaml")
+}
+
+// setupScenario replaces the graph with the scenario in the provided file.
+func setupScenario(path string) error {
+	sc, err := scenario.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading scenario: %w", err)
+	}
+
+	gremlinConfig := gremlin.Config{
+		Endpoint: gremlinEndpoint,
+		AuthMode: gremlinAuthMode,
+	}
+	conn, err := gremlin.NewConnection(gremlinConfig)
+	if err != nil {
+		return fmt.Errorf("error creating Gremlin connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := scenario.Reset(context.Background(), conn); err != nil {
+		return fmt.Errorf("error resetting graph: %w", err)
+	}
+	if err := scenario.Seed(context.Background(), conn, sc); err != nil {
+		return fmt.Errorf("error seeding graph: %w", err)
+	}
+
+	return nil
+}
+
+func TestAPICheckDependencies(t *testing.T) {
+	if err := setupBlastRadiusGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	want := []DependencyStatus{
+		{Name: "gremlin", OK: true},
+		{Name: "altimeter_universe", OK: true},
+	}
+
+	got := intelAPI.CheckDependencies(context.Background())
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("dependencies mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestAPIBlastRadius(t *testing.T) {
+	tests := []struct {
+		name       string
+		typ        string
+		identifier string
+		wantNilErr bool
+	}{
+		{
+			name:       "IP",
+			typ:        "IP",
+			identifier: "1.2.3.4",
+			wantNilErr: true,
+		},
+		{
+			name:       "Hostname",
+			typ:        "Hostname",
+			identifier: "example.com",
+			wantNilErr: true,
+		},
+		{
+			name:       "ARN",
+			typ:        "ARN",
+			identifier: "arn:aws:ec2:eu-west-1:123456789012:network-interface/eni-0123456789abcdef0",
+			wantNilErr: true,
+		},
+		{
+			name:       "ResourceID",
+			typ:        "ResourceID",
+			identifier: "eni-0123456789abcdef0",
+			wantNilErr: true,
+		},
+		{
+			name:       "unsupported ResourceID",
+			typ:        "ResourceID",
+			identifier: "unknown-0123456789abcdef0",
+			wantNilErr: false,
+		},
+		{
+			name:       "not found",
+			typ:        "Hostname",
+			identifier: "unknown",
+			wantNilErr: false,
+		},
+		{
+			name:       "invalid type",
+			typ:        "inunknown",
+			identifier: "1.2.3.4",
+			wantNilErr: false,
+		},
+	}
+
+	if err := setupBlastRadiusGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+		ResolveTimeoutMs:     60000,
+		BlastRadiusTimeoutMs: 60000,
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := intelAPI.BlastRadius(context.Background(), tt.typ, tt.identifier, BlastRadiusOptions{})
+
+			if (err == nil) != tt.wantNilErr {
+				t.Fatalf("unexpected error: wantNilErr=%v, got=%v", tt.wantNilErr, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if diff := cmp.Diff(wantBlastRadiusResult, got, ignoreDuration); diff != "" {
+				t.Errorf("Blast Radius scores mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPIBlastRadius_Model(t *testing.T) {
+	tests := []struct {
+		name    string
+		model   string
+		want    BlastRadiusResult
+		wantErr error
+	}{
+		{
+			name:  "default model",
+			model: "",
+			want:  wantBlastRadiusResult,
+		},
+		{
+			name:  "net model",
+			model: "net",
+			want:  wantBlastRadiusResult,
+		},
+		{
+			name:  "configurable model",
+			model: "ip_ranges",
+			want: BlastRadiusResult{
+				Score: 2.0/7.0 + 2.0/13.0,
+				Metadata: Metadata{
+					Model:        "ip_ranges",
+					ModelVersion: "1",
+					VertexID:     "ni0",
+					Resolution:   ResolutionDirect,
+					Snapshot:     Snapshot{ID: "s0", Timestamp: time.Unix(0, 0).UTC()},
+				},
+			},
+		},
+		{
+			name:    "unknown model",
+			model:   "unknown",
+			wantErr: ErrUnknownModel,
+		},
+	}
+
+	if err := setupBlastRadiusGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+		ResolveTimeoutMs:     60000,
+		BlastRadiusTimeoutMs: 60000,
+		Models: []ModelConfig{
+			{
+				Name:    "ip_ranges",
+				Weights: map[string]float64{"ip_range": 2},
+			},
+		},
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := intelAPI.BlastRadius(context.Background(), "IP", "1.2.3.4", BlastRadiusOptions{Model: tt.model})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: got=%v want=%v", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, got, ignoreDuration); diff != "" {
+				t.Errorf("Blast Radius scores mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPIBlastRadiusExplain(t *testing.T) {
+	want := BlastRadiusExplanation{
+		Score:    0.3106893106893107,
+		Metadata: wantBlastRadiusResult.Metadata,
+		Resources: []ExplainedResource{
+			{
+				ID:     "r0",
+				Label:  "ip_range",
+				Steps:  7,
+				Weight: 1,
+				Score:  1.0 / 7.0,
+				Path: []PathVertex{
+					{ID: "ni0", Label: "ec2:network-interface"},
+					{ID: "sg0", Label: "ec2:security-group"},
+					{ID: "er0", Label: "egress_rule"},
+					{ID: "r0", Label: "ip_range"},
+				},
+			},
+			{
+				ID:     "i0",
+				Label:  "ec2:instance",
+				Steps:  11,
+				Weight: 1,
+				Score:  1.0 / 11.0,
+				Path: []PathVertex{
+					{ID: "ni0", Label: "ec2:network-interface"},
+					{ID: "sg0", Label: "ec2:security-group"},
+					{ID: "uigp0", Label: "user_id_group_pairs"},
+					{ID: "ir0", Label: "ingress_rule"},
+					{ID: "sg1", Label: "ec2:security-group"},
+					{ID: "i0", Label: "ec2:instance"},
+				},
+			},
+			{
+				ID:     "r1",
+				Label:  "ip_range",
+				Steps:  13,
+				Weight: 1,
+				Score:  1.0 / 13.0,
+				Path: []PathVertex{
+					{ID: "ni0", Label: "ec2:network-interface"},
+					{ID: "sg0", Label: "ec2:security-group"},
+					{ID: "uigp0", Label: "user_id_group_pairs"},
+					{ID: "ir0", Label: "ingress_rule"},
+					{ID: "sg1", Label: "ec2:security-group"},
+					{ID: "er1", Label: "egress_rule"},
+					{ID: "r1", Label: "ip_range"},
+				},
+			},
+		},
+	}
+
+	if err := setupBlastRadiusGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+		ResolveTimeoutMs:     60000,
+		BlastRadiusTimeoutMs: 60000,
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	got, err := intelAPI.BlastRadiusExplain(context.Background(), "IP", "1.2.3.4", BlastRadiusOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if diff := cmp.Diff(want, got, ignoreDuration); diff != "" {
+		t.Errorf("explanation mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func setupHistoricalGraph() error {
 	gremlinConfig := gremlin.Config{
 		Endpoint: gremlinEndpoint,
-		AuthMode: "plain",
+		AuthMode: gremlinAuthMode,
 	}
 	conn, err := gremlin.NewConnection(gremlinConfig)
 	if err != nil {
 		return fmt.Errorf("error creating Gremlin connection: %w", err)
 	}
+	defer conn.Close()
 
-	_, err = conn.Query(func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+	_, err = conn.Query(context.Background(), "setup", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		<-g.V().Drop().Iterate()
+		<-g.
+			AddV("Universe").Property(gremlingo.T.Id, "u0").Property("namespace", "altimeter").Property("version", 1).As("u0").
+			// Snapshot s0 only contains the network interface and
+			// its security group.
+			AddV("altimeter_snapshot").Property(gremlingo.T.Id, "s0").Property("timestamp", 1000).As("s0").
+			AddV("ec2:network-interface").Property(gremlingo.T.Id, "s0-ni0").Property("public_ip", "1.2.3.4").Property("status", "in-use").As("s0-ni0").
+			AddV("ec2:security-group").Property(gremlingo.T.Id, "s0-sg0").As("s0-sg0").
+			AddV("egress_rule").Property(gremlingo.T.Id, "s0-er0").As("s0-er0").
+			AddV("ip_range").Property(gremlingo.T.Id, "s0-r0").Property("cidr_ip", "10.0.0.0/8").As("s0-r0").
+			// Snapshot s1 contains a new ingress rule that allows
+			// traffic from the instance i0.
+			AddV("altimeter_snapshot").Property(gremlingo.T.Id, "s1").Property("timestamp", 2000).As("s1").
+			AddV("ec2:network-interface").Property(gremlingo.T.Id, "s1-ni0").Property("public_ip", "1.2.3.4").Property("status", "in-use").As("s1-ni0").
+			AddV("ec2:security-group").Property(gremlingo.T.Id, "s1-sg0").As("s1-sg0").
+			AddV("egress_rule").Property(gremlingo.T.Id, "s1-er0").As("s1-er0").
+			AddV("ip_range").Property(gremlingo.T.Id, "s1-r0").Property("cidr_ip", "10.0.0.0/8").As("s1-r0").
+			AddV("user_id_group_pairs").Property(gremlingo.T.Id, "s1-uigp0").As("s1-uigp0").
+			AddV("ingress_rule").Property(gremlingo.T.Id, "s1-ir0").As("s1-ir0").
+			AddV("ec2:security-group").Property(gremlingo.T.Id, "s1-sg1").As("s1-sg1").
+			AddV("ec2:instance").Property(gremlingo.T.Id, "s1-i0").Property("arn", "arn:aws:ec2:eu-west-1:123456789012:instance/i-0123456789abcdef0").As("s1-i0").
+			AddV("egress_rule").Property(gremlingo.T.Id, "s1-er1").As("s1-er1").
+			AddV("ip_range").Property(gremlingo.T.Id, "s1-r1").Property("cidr_ip", "0.0.0.0/0").As("s1-r1").
+			AddE("universe_of").From("u0").To("s0").
+			AddE("includes").From("s0").To("s0-ni0").
+			AddE("includes").From("s0").To("s0-sg0").
+			AddE("includes").From("s0").To("s0-er0").
+			AddE("includes").From("s0").To("s0-r0").
+			AddE("resource_link").From("s0-ni0").To("s0-sg0").
+			AddE("egress_rule").From("s0-sg0").To("s0-er0").
+			AddE("ip_range").From("s0-er0").To("s0-r0").
+			AddE("universe_of").From("u0").To("s1").
+			AddE("includes").From("s1").To("s1-ni0").
+			AddE("includes").From("s1").To("s1-sg0").
+			AddE("includes").From("s1").To("s1-er0").
+			AddE("includes").From("s1").To("s1-r0").
+			AddE("includes").From("s1").To("s1-uigp0").
+			AddE("includes").From("s1").To("s1-ir0").
+			AddE("includes").From("s1").To("s1-sg1").
+			AddE("includes").From("s1").To("s1-i0").
+			AddE("includes").From("s1").To("s1-er1").
+			AddE("includes").From("s1").To("s1-r1").
+			AddE("resource_link").From("s1-ni0").To("s1-sg0").
+			AddE("egress_rule").From("s1-sg0").To("s1-er0").
+			AddE("ip_range").From("s1-er0").To("s1-r0").
+			AddE("resource_link").From("s1-uigp0").To("s1-sg0").
+			AddE("user_id_group_pairs").From("s1-ir0").To("s1-uigp0").
+			AddE("ingress_rule").From("s1-sg1").To("s1-ir0").
+			AddE("transient_resource_link").From("s1-i0").To("s1-sg1").
+			AddE("egress_rule").From("s1-sg1").To("s1-er1").
+			AddE("ip_range").From("s1-er1").To("s1-r1").
+			Iterate()
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("error executing Gremlin query: %w", err)
+	}
+
+	return nil
+}
+
+func TestAPIBlastRadius_At(t *testing.T) {
+	s0 := BlastRadiusResult{
+		Score: 1.0 / 7.0,
+		Metadata: Metadata{
+			Model:        "net",
+			ModelVersion: "1",
+			VertexID:     "s0-ni0",
+			Resolution:   ResolutionDirect,
+			Snapshot:     Snapshot{ID: "s0", Timestamp: time.Unix(1000, 0).UTC()},
+		},
+	}
+	s1 := BlastRadiusResult{
+		Score: wantBlastRadiusResult.Score,
+		Metadata: Metadata{
+			Model:        "net",
+			ModelVersion: "1",
+			VertexID:     "s1-ni0",
+			Resolution:   ResolutionDirect,
+			Snapshot:     Snapshot{ID: "s1", Timestamp: time.Unix(2000, 0).UTC()},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		at      time.Time
+		want    BlastRadiusResult
+		wantErr error
+	}{
+		{
+			name: "latest snapshot",
+			at:   time.Time{},
+			want: s1,
+		},
+		{
+			name: "between snapshots",
+			at:   time.Unix(1500, 0),
+			want: s0,
+		},
+		{
+			name: "at snapshot",
+			at:   time.Unix(2000, 0),
+			want: s1,
+		},
+		{
+			name:    "before first snapshot",
+			at:      time.Unix(500, 0),
+			wantErr: ErrNotFound,
+		},
+	}
+
+	if err := setupHistoricalGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+		ResolveTimeoutMs:     60000,
+		BlastRadiusTimeoutMs: 60000,
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := intelAPI.BlastRadius(context.Background(), "IP", "1.2.3.4", BlastRadiusOptions{At: tt.at})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: got=%v want=%v", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, got, ignoreDuration); diff != "" {
+				t.Errorf("Blast Radius scores mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPIBlastRadiusTrend(t *testing.T) {
+	s0 := Snapshot{ID: "s0", Timestamp: time.Unix(1000, 0).UTC()}
+	s1 := Snapshot{ID: "s1", Timestamp: time.Unix(2000, 0).UTC()}
+
+	tests := []struct {
+		name    string
+		from    time.Time
+		to      time.Time
+		model   string
+		want    BlastRadiusTrend
+		wantErr error
+	}{
+		{
+			name: "all snapshots",
+			from: time.Unix(0, 0),
+			to:   time.Unix(3000, 0),
+			want: BlastRadiusTrend{
+				Metadata: TrendMetadata{
+					Model:        "net",
+					ModelVersion: "1",
+					From:         s0,
+					To:           s1,
+				},
+				Points: []TrendPoint{
+					{
+						Timestamp:  s0.Timestamp,
+						SnapshotID: "s0",
+						Score:      1.0 / 7.0,
+						VertexID:   "s0-ni0",
+						Resolution: ResolutionDirect,
+					},
+					{
+						Timestamp:  s1.Timestamp,
+						SnapshotID: "s1",
+						Score:      wantBlastRadiusResult.Score,
+						VertexID:   "s1-ni0",
+						Resolution: ResolutionDirect,
+					},
+				},
+			},
+		},
+		{
+			name: "last snapshot",
+			from: time.Unix(2000, 0),
+			to:   time.Unix(3000, 0),
+			want: BlastRadiusTrend{
+				Metadata: TrendMetadata{
+					Model:        "net",
+					ModelVersion: "1",
+					From:         s1,
+					To:           s1,
+				},
+				Points: []TrendPoint{
+					{
+						Timestamp:  s1.Timestamp,
+						SnapshotID: "s1",
+						Score:      wantBlastRadiusResult.Score,
+						VertexID:   "s1-ni0",
+						Resolution: ResolutionDirect,
+					},
+				},
+			},
+		},
+		{
+			name:  "iam model",
+			from:  time.Unix(2000, 0),
+			to:    time.Unix(3000, 0),
+			model: "iam",
+			want: BlastRadiusTrend{
+				Metadata: TrendMetadata{
+					Model:        "iam",
+					ModelVersion: "1",
+					From:         s1,
+					To:           s1,
+				},
+				Points: []TrendPoint{
+					{
+						Timestamp:  s1.Timestamp,
+						SnapshotID: "s1",
+						Score:      0,
+						VertexID:   "s1-ni0",
+						Resolution: ResolutionDirect,
+					},
+				},
+			},
+		},
+		{
+			name:    "unknown model",
+			from:    time.Unix(0, 0),
+			to:      time.Unix(3000, 0),
+			model:   "unknown",
+			wantErr: ErrUnknownModel,
+		},
+		{
+			name:    "no snapshots",
+			from:    time.Unix(0, 0),
+			to:      time.Unix(500, 0),
+			wantErr: ErrNotFound,
+		},
+	}
+
+	if err := setupHistoricalGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+		ResolveTimeoutMs:     60000,
+		BlastRadiusTimeoutMs: 60000,
+		BatchConcurrency:     2,
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := intelAPI.BlastRadiusTrend(context.Background(), "IP", "1.2.3.4", tt.from, tt.to, tt.model)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: got=%v want=%v", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, got, cmpopts.IgnoreFields(TrendMetadata{}, "DurationMs")); diff != "" {
+				t.Errorf("trend mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPIBlastRadiusTrend_TooManySnapshots(t *testing.T) {
+	if err := setupHistoricalGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+		ResolveTimeoutMs:     60000,
+		BlastRadiusTimeoutMs: 60000,
+		TrendMaxSnapshots:    1,
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	_, err = intelAPI.BlastRadiusTrend(context.Background(), "IP", "1.2.3.4", time.Unix(0, 0), time.Unix(3000, 0), "")
+	if !errors.Is(err, ErrTooManySnapshots) {
+		t.Errorf("unexpected error: got=%v want=%v", err, ErrTooManySnapshots)
+	}
+}
+
+func TestAPIBlastRadiusDiff(t *testing.T) {
+	s0 := Snapshot{ID: "s0", Timestamp: time.Unix(1000, 0).UTC()}
+	s1 := Snapshot{ID: "s1", Timestamp: time.Unix(2000, 0).UTC()}
+
+	i0 := DiffResource{
+		Key:   "arn:aws:ec2:eu-west-1:123456789012:instance/i-0123456789abcdef0",
+		ID:    "s1-i0",
+		Label: "ec2:instance",
+		Rules: []PathVertex{
+			{ID: "s1-ir0", Label: "ingress_rule"},
+		},
+	}
+	r1 := DiffResource{
+		Key:   "0.0.0.0/0",
+		ID:    "s1-r1",
+		Label: "ip_range",
+		Rules: []PathVertex{
+			{ID: "s1-er1", Label: "egress_rule"},
+			{ID: "s1-ir0", Label: "ingress_rule"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		from    time.Time
+		to      time.Time
+		want    BlastRadiusDiff
+		wantErr error
+	}{
+		{
+			name: "added resources",
+			from: time.Unix(1000, 0),
+			to:   time.Unix(2000, 0),
+			want: BlastRadiusDiff{
+				From:    s0,
+				To:      s1,
+				Added:   []DiffResource{r1, i0},
+				Removed: []DiffResource{},
+				Unchanged: []DiffResource{
+					{Key: "10.0.0.0/8", ID: "s1-r0", Label: "ip_range", Rules: []PathVertex{}},
+				},
+			},
+		},
+		{
+			name: "removed resources",
+			from: time.Unix(2000, 0),
+			to:   time.Unix(1000, 0),
+			want: BlastRadiusDiff{
+				From:    s1,
+				To:      s0,
+				Added:   []DiffResource{},
+				Removed: []DiffResource{r1, i0},
+				Unchanged: []DiffResource{
+					{Key: "10.0.0.0/8", ID: "s0-r0", Label: "ip_range", Rules: []PathVertex{}},
+				},
+			},
+		},
+		{
+			name:    "no snapshot",
+			from:    time.Unix(500, 0),
+			to:      time.Unix(2000, 0),
+			wantErr: ErrNotFound,
+		},
+	}
+
+	if err := setupHistoricalGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+		ResolveTimeoutMs:     60000,
+		BlastRadiusTimeoutMs: 60000,
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := intelAPI.BlastRadiusDiff(context.Background(), "IP", "1.2.3.4", tt.from, tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: got=%v want=%v", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("diff mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPIBlastRadiusBatch(t *testing.T) {
+	assets := []Asset{
+		{Type: "IP", Identifier: "1.2.3.4"},
+		{Type: "Hostname", Identifier: "example.com"},
+		{Type: "Hostname", Identifier: "unknown"},
+		{Type: "unknown", Identifier: "1.2.3.4"},
+	}
+
+	wantErrs := []error{nil, nil, ErrNotFound, ErrUnsupportedAssetType}
+
+	if err := setupBlastRadiusGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+		ResolveTimeoutMs:     60000,
+		BlastRadiusTimeoutMs: 60000,
+		BatchConcurrency:     2,
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	results := intelAPI.BlastRadiusBatch(context.Background(), assets)
+
+	if len(results) != len(assets) {
+		t.Fatalf("unexpected number of results: got=%v want=%v", len(results), len(assets))
+	}
+
+	for i, result := range results {
+		if result.Asset != assets[i] {
+			t.Errorf("unexpected asset: got=%v want=%v", result.Asset, assets[i])
+		}
+
+		if !errors.Is(result.Err, wantErrs[i]) {
+			t.Errorf("unexpected error for asset %v: got=%v want=%v", assets[i], result.Err, wantErrs[i])
+		}
+
+		if result.Err != nil {
+			continue
+		}
+
+		if diff := cmp.Diff(wantBlastRadiusResult, result.Result, ignoreDuration); diff != "" {
+			t.Errorf("Blast Radius scores mismatch (-want +got):\n%v", diff)
+		}
+	}
+}
+
+func setupInternetExposureGraph() error {
+	gremlinConfig := gremlin.Config{
+		Endpoint: gremlinEndpoint,
+		AuthMode: gremlinAuthMode,
+	}
+	conn, err := gremlin.NewConnection(gremlinConfig)
+	if err != nil {
+		return fmt.Errorf("error creating Gremlin connection: %w", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Query(context.Background(), "setup", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
 		<-g.V().Drop().Iterate()
 		<-g.
 			AddV("Universe").Property(gremlingo.T.Id, "u0").Property("namespace", "altimeter").Property("version", 1).As("u0").
 			AddV("altimeter_snapshot").Property(gremlingo.T.Id, "s0").Property("timestamp", 0).As("s0").
-			AddV("ec2:network-interface").Property(gremlingo.T.Id, "ni0").Property("public_ip", "1.2.3.4").Property("public_dns_name", "example.com").Property("status", "in-use").As("ni0").
+			AddV("ec2:network-interface").Property(gremlingo.T.Id, "ni0").Property("public_ip", "1.2.3.4").Property("status", "in-use").As("ni0").
+			AddV("ec2:network-interface").Property(gremlingo.T.Id, "ni1").Property("private_ip_address", "10.0.0.1").Property("status", "in-use").As("ni1").
 			AddV("ec2:security-group").Property(gremlingo.T.Id, "sg0").As("sg0").
-			AddV("egress_rule").Property(gremlingo.T.Id, "er0").As("er0").
-			AddV("ip_range").Property(gremlingo.T.Id, "r0").As("r0").
+			AddV("ingress_rule").Property(gremlingo.T.Id, "ir0").Property("ip_protocol", "tcp").Property("from_port", 22).Property("to_port", 22).As("ir0").
+			AddV("ip_range").Property(gremlingo.T.Id, "r0").Property("cidr_ip", "0.0.0.0/0").As("r0").
+			AddV("ingress_rule").Property(gremlingo.T.Id, "ir1").Property("ip_protocol", "tcp").Property("from_port", 5432).Property("to_port", 5432).As("ir1").
+			AddV("ip_range").Property(gremlingo.T.Id, "r1").Property("cidr_ip", "10.0.0.0/8").As("r1").
+			AddV("ingress_rule").Property(gremlingo.T.Id, "ir2").Property("ip_protocol", "tcp").Property("from_port", 80).Property("to_port", 80).As("ir2").
 			AddV("user_id_group_pairs").Property(gremlingo.T.Id, "uigp0").As("uigp0").
-			AddV("ingress_rule").Property(gremlingo.T.Id, "ir0").As("ir0").
 			AddV("ec2:security-group").Property(gremlingo.T.Id, "sg1").As("sg1").
-			AddV("ec2:instance").Property(gremlingo.T.Id, "i0").As("i0").
-			AddV("egress_rule").Property(gremlingo.T.Id, "er1").As("er1").
-			AddV("ip_range").Property(gremlingo.T.Id, "r1").As("r1").
+			AddV("elbv2:loadbalancer").Property(gremlingo.T.Id, "lb0").Property("scheme", "internet-facing").Property("dns_name", "lb.example.com").As("lb0").
+			AddV("ingress_rule").Property(gremlingo.T.Id, "ir3").Property("ip_protocol", "tcp").Property("from_port", 443).Property("to_port", 443).As("ir3").
+			AddV("ipv6_range").Property(gremlingo.T.Id, "r2").Property("cidr_ipv6", "::/0").As("r2").
 			AddE("universe_of").From("u0").To("s0").
 			AddE("includes").From("s0").To("ni0").
+			AddE("includes").From("s0").To("ni1").
 			AddE("includes").From("s0").To("sg0").
-			AddE("includes").From("s0").To("er0").
+			AddE("includes").From("s0").To("ir0").
 			AddE("includes").From("s0").To("r0").
+			AddE("includes").From("s0").To("ir1").
+			AddE("includes").From("s0").To("r1").
+			AddE("includes").From("s0").To("ir2").
 			AddE("includes").From("s0").To("uigp0").
-			AddE("includes").From("s0").To("ir0").
 			AddE("includes").From("s0").To("sg1").
-			AddE("includes").From("s0").To("i0").
-			AddE("includes").From("s0").To("er1").
-			AddE("includes").From("s0").To("r1").
+			AddE("includes").From("s0").To("lb0").
+			AddE("includes").From("s0").To("ir3").
+			AddE("includes").From("s0").To("r2").
 			AddE("resource_link").From("ni0").To("sg0").
-			AddE("egress_rule").From("sg0").To("er0").
-			AddE("ip_range").From("er0").To("r0").
-			AddE("resource_link").From("uigp0").To("sg0").
-			AddE("user_id_group_pairs").From("ir0").To("uigp0").
-			AddE("ingress_rule").From("sg1").To("ir0").
-			AddE("transient_resource_link").From("i0").To("sg1").
-			AddE("egress_rule").From("sg1").To("er1").
-			AddE("ip_range").From("er1").To("r1").
+			AddE("resource_link").From("ni1").To("sg0").
+			AddE("ingress_rule").From("sg0").To("ir0").
+			AddE("ip_range").From("ir0").To("r0").
+			AddE("ingress_rule").From("sg0").To("ir1").
+			AddE("ip_range").From("ir1").To("r1").
+			AddE("ingress_rule").From("sg0").To("ir2").
+			AddE("user_id_group_pairs").From("ir2").To("uigp0").
+			AddE("resource_link").From("uigp0").To("sg1").
+			AddE("resource_link").From("lb0").To("sg1").
+			AddE("ingress_rule").From("sg1").To("ir3").
+			AddE("ipv6_range").From("ir3").To("r2").
 			Iterate()
 		return nil, nil
 	})
@@ -72,47 +878,83 @@ func setupBlastRadiusGraph() error {
 	return nil
 }
 
-func TestAPIBlastRadius(t *testing.T) {
+func TestAPIInternetExposure(t *testing.T) {
+	wantRules := []ExposureRule{
+		{
+			SecurityGroupID: "sg0",
+			IngressRuleID:   "ir0",
+			Protocol:        "tcp",
+			FromPort:        22,
+			ToPort:          22,
+			CIDR:            "0.0.0.0/0",
+		},
+	}
+
+	wantLoadBalancers := []ExposedLoadBalancer{
+		{
+			ID:      "lb0",
+			Label:   "elbv2:loadbalancer",
+			DNSName: "lb.example.com",
+			Rules: []ExposureRule{
+				{
+					SecurityGroupID: "sg1",
+					IngressRuleID:   "ir3",
+					Protocol:        "tcp",
+					FromPort:        443,
+					ToPort:          443,
+					CIDR:            "::/0",
+				},
+			},
+		},
+	}
+
 	tests := []struct {
 		name       string
 		typ        string
 		identifier string
+		want       InternetExposureResult
 		wantNilErr bool
 	}{
 		{
-			name:       "IP",
+			name:       "public asset",
 			typ:        "IP",
 			identifier: "1.2.3.4",
+			want: InternetExposureResult{
+				Exposed:       true,
+				Public:        true,
+				Rules:         wantRules,
+				LoadBalancers: wantLoadBalancers,
+			},
 			wantNilErr: true,
 		},
 		{
-			name:       "Hostname",
-			typ:        "Hostname",
-			identifier: "example.com",
+			name:       "private asset",
+			typ:        "IP",
+			identifier: "10.0.0.1",
+			want: InternetExposureResult{
+				Exposed:       true,
+				Public:        false,
+				Rules:         wantRules,
+				LoadBalancers: wantLoadBalancers,
+			},
 			wantNilErr: true,
 		},
 		{
 			name:       "not found",
-			typ:        "Hostname",
-			identifier: "unknown",
-			wantNilErr: false,
-		},
-		{
-			name:       "invalid type",
-			typ:        "inunknown",
-			identifier: "1.2.3.4",
+			typ:        "IP",
+			identifier: "4.3.2.1",
 			wantNilErr: false,
 		},
 	}
 
-	if err := setupBlastRadiusGraph(); err != nil {
+	if err := setupInternetExposureGraph(); err != nil {
 		t.Fatalf("error setting up the initial graph: %v", err)
 	}
 
 	cfg := Config{
 		GremlinConfig: gremlin.Config{
 			Endpoint: gremlinEndpoint,
-			AuthMode: "plain",
+			AuthMode: gremlinAuthMode,
 		},
 		ResolveTimeoutMs:     60000,
 		BlastRadiusTimeoutMs: 60000,
@@ -121,10 +963,11 @@ func TestAPIBlastRadius(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error creating intel API: %v", err)
 	}
+	defer intelAPI.Close()
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := intelAPI.BlastRadius(tt.typ, tt.identifier)
+			got, err := intelAPI.InternetExposure(context.Background(), tt.typ, tt.identifier)
 
 			if (err == nil) != tt.wantNilErr {
 				t.Fatalf("unexpected error: wantNilErr=%v, got=%v", tt.wantNilErr, err)
@@ -134,8 +977,8 @@ func TestAPIBlastRadius(t *testing.T) {
 				return
 			}
 
-			if diff := cmp.Diff(wantBlastRadiusResult, got); diff != "" {
-				t.Errorf("Blast Radius scores mismatch (-want +got):\n%v", diff)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("internet exposure mismatch (-want +got):\n%v", diff)
 			}
 		})
 	}
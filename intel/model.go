@@ -0,0 +1,207 @@
+package intel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// DefaultModel is the blast radius model used when no model is specified.
+const DefaultModel = "net"
+
+// defaultModelVersion is the version of the configurable blast radius models
+// that do not specify one.
+const defaultModelVersion = "1"
+
+// ErrUnknownModel is returned when a blast radius model does not exist.
+var ErrUnknownModel = errors.New("unknown model")
+
+// A Scorer calculates the contribution of the resources reachable from an
+// asset to its blast radius score. The contribution of a resource is
+// Decay(steps) * Weight(label).
+type Scorer interface {
+	// Weight returns the weight of a resource given its vertex label.
+	Weight(label string) float64
+
+	// Decay returns the factor applied to the weight of a resource
+	// reached after traversing the provided number of steps.
+	Decay(steps int) float64
+}
+
+// ModelConfig defines a configurable blast radius model. It scores the
+// resources reachable through the network, like the default "net" model,
+// using custom weights and decay function.
+type ModelConfig struct {
+	// Name is the name of the model. It is used to select the model
+	// and returned as the metadata of the results.
+	Name string `json:"name"`
+
+	// Version is the version of the model. It is returned as part of
+	// the metadata of the results, so clients can tell apart scores
+	// calculated with different settings. If empty, "1" is used.
+	Version string `json:"version"`
+
+	// Weights maps vertex labels to weights.
+	Weights map[string]float64 `json:"weights"`
+
+	// DefaultWeight is the weight of the labels not present in Weights.
+	DefaultWeight float64 `json:"default_weight"`
+
+	// Decay is the decay function. Valid values: "inverse" (1/steps),
+	// "exponential" (DecayRate^(steps-1)) and "none" (1). If empty,
+	// "inverse" is used.
+	Decay string `json:"decay"`
+
+	// DecayRate is the rate of the "exponential" decay function. It must
+	// be in the range (0, 1].
+	DecayRate float64 `json:"decay_rate"`
+}
+
+// model represents a blast radius model.
+type model struct {
+	// name is the name of the model.
+	name string
+
+	// version is the version of the model.
+	version string
+
+	// reach returns the resources reachable from a vertex ID. If
+	// withPath is true, the path followed to reach every resource is
+	// also returned.
+	reach func(api API, ctx context.Context, vid string, withPath bool) ([]resource, error)
+
+	// scorer calculates the contribution of every reachable resource.
+	scorer Scorer
+}
+
+// score returns the blast radius score of the provided reachable resources
+// using scorer.
+func score(rscs []resource, scorer Scorer) float64 {
+	sum := 0.0
+	for _, rsc := range rscs {
+		sum += scorer.Decay(int(rsc.steps)) * scorer.Weight(rsc.label)
+	}
+	return sum
+}
+
+// builtinModels returns the built-in blast radius models indexed by name.
+func builtinModels() map[string]model {
+	return map[string]model{
+		DefaultModel: {
+			name:    DefaultModel,
+			version: "1",
+			reach:   API.netReachableResources,
+			scorer:  netScorer{},
+		},
+		IAMModel: {
+			name:    IAMModel,
+			version: "1",
+			reach:   API.iamReachableResources,
+			scorer:  iamScorer{},
+		},
+	}
+}
+
+// newModels returns the built-in blast radius models along with the models
+// defined by cfgs, indexed by name.
+func newModels(cfgs []ModelConfig) (map[string]model, error) {
+	models := builtinModels()
+	for _, cfg := range cfgs {
+		if cfg.Name == "" {
+			return nil, errors.New("missing model name")
+		}
+		if _, ok := models[cfg.Name]; ok {
+			return nil, fmt.Errorf("duplicated model %q", cfg.Name)
+		}
+
+		scorer, err := newWeightedScorer(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid model %q: %w", cfg.Name, err)
+		}
+
+		version := cfg.Version
+		if version == "" {
+			version = defaultModelVersion
+		}
+
+		models[cfg.Name] = model{
+			name:    cfg.Name,
+			version: version,
+			reach:   API.netReachableResources,
+			scorer:  scorer,
+		}
+	}
+	return models, nil
+}
+
+// netScorer is the [Scorer] of the Network Blast Radius model. Security
+// groups do not contribute to the score and the weight of the other
+// resources is 1. The decay function is 1/steps.
+type netScorer struct{}
+
+// Weight returns the weight of a resource given its vertex label.
+func (netScorer) Weight(label string) float64 {
+	if label == "ec2:security-group" {
+		return 0.0
+	}
+	return 1.0
+}
+
+// Decay returns 1/steps.
+func (netScorer) Decay(steps int) float64 {
+	return 1.0 / float64(steps)
+}
+
+// weightedScorer is a [Scorer] with configurable weights and decay
+// function.
+type weightedScorer struct {
+	weights       map[string]float64
+	defaultWeight float64
+	decay         func(steps int) float64
+}
+
+// newWeightedScorer returns a [weightedScorer] defined by cfg.
+func newWeightedScorer(cfg ModelConfig) (weightedScorer, error) {
+	var decay func(steps int) float64
+	switch cfg.Decay {
+	case "", "inverse":
+		decay = func(steps int) float64 {
+			return 1.0 / float64(steps)
+		}
+	case "exponential":
+		if cfg.DecayRate <= 0 || cfg.DecayRate > 1 {
+			return weightedScorer{}, fmt.Errorf("invalid decay rate %v", cfg.DecayRate)
+		}
+		rate := cfg.DecayRate
+		decay = func(steps int) float64 {
+			return math.Pow(rate, float64(steps-1))
+		}
+	case "none":
+		decay = func(int) float64 {
+			return 1.0
+		}
+	default:
+		return weightedScorer{}, fmt.Errorf("unknown decay function %q", cfg.Decay)
+	}
+
+	scorer := weightedScorer{
+		weights:       cfg.Weights,
+		defaultWeight: cfg.DefaultWeight,
+		decay:         decay,
+	}
+	return scorer, nil
+}
+
+// Weight returns the configured weight of a resource given its vertex label.
+func (s weightedScorer) Weight(label string) float64 {
+	if w, ok := s.weights[label]; ok {
+		return w
+	}
+	return s.defaultWeight
+}
+
+// Decay returns the value of the configured decay function.
+func (s weightedScorer) Decay(steps int) float64 {
+	return s.decay(steps)
+}
@@ -0,0 +1,133 @@
+package intel
+
+import (
+	"testing"
+)
+
+func TestNewModels(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfgs       []ModelConfig
+		wantModels []string
+		wantNilErr bool
+	}{
+		{
+			name:       "built-in models",
+			cfgs:       nil,
+			wantModels: []string{"net", "iam"},
+			wantNilErr: true,
+		},
+		{
+			name: "configurable model",
+			cfgs: []ModelConfig{
+				{
+					Name:      "custom",
+					Weights:   map[string]float64{"rds:db": 10},
+					Decay:     "exponential",
+					DecayRate: 0.5,
+				},
+			},
+			wantModels: []string{"net", "iam", "custom"},
+			wantNilErr: true,
+		},
+		{
+			name:       "missing name",
+			cfgs:       []ModelConfig{{Decay: "none"}},
+			wantNilErr: false,
+		},
+		{
+			name:       "duplicated name",
+			cfgs:       []ModelConfig{{Name: "net"}},
+			wantNilErr: false,
+		},
+		{
+			name:       "unknown decay function",
+			cfgs:       []ModelConfig{{Name: "custom", Decay: "unknown"}},
+			wantNilErr: false,
+		},
+		{
+			name:       "invalid decay rate",
+			cfgs:       []ModelConfig{{Name: "custom", Decay: "exponential", DecayRate: 2}},
+			wantNilErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			models, err := newModels(tt.cfgs)
+			if (err == nil) != tt.wantNilErr {
+				t.Fatalf("unexpected error: wantNilErr=%v, got=%v", tt.wantNilErr, err)
+			}
+
+			if len(models) != len(tt.wantModels) {
+				t.Errorf("unexpected number of models: got=%v want=%v", len(models), len(tt.wantModels))
+			}
+			for _, name := range tt.wantModels {
+				if _, ok := models[name]; !ok {
+					t.Errorf("missing model %q", name)
+				}
+			}
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	rscs := []resource{
+		{id: "sg0", label: "ec2:security-group", steps: 3},
+		{id: "r0", label: "ip_range", steps: 7},
+		{id: "db0", label: "rds:db", steps: 11},
+	}
+
+	tests := []struct {
+		name string
+		cfg  ModelConfig
+		want float64
+	}{
+		{
+			name: "inverse",
+			cfg: ModelConfig{
+				Name:          "inverse",
+				Weights:       map[string]float64{"ec2:security-group": 0, "rds:db": 11},
+				Decay:         "inverse",
+				DefaultWeight: 7,
+			},
+			want: 2,
+		},
+		{
+			name: "exponential",
+			cfg: ModelConfig{
+				Name:      "exponential",
+				Weights:   map[string]float64{"rds:db": 1024},
+				Decay:     "exponential",
+				DecayRate: 0.5,
+			},
+			want: 1,
+		},
+		{
+			name: "none",
+			cfg: ModelConfig{
+				Name:          "none",
+				Decay:         "none",
+				DefaultWeight: 1,
+			},
+			want: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scorer, err := newWeightedScorer(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := score(rscs, scorer); got != tt.want {
+				t.Errorf("unexpected score: got=%v want=%v", got, tt.want)
+			}
+		})
+	}
+
+	if got, want := score(rscs, netScorer{}), 1.0/7.0+1.0/11.0; got != want {
+		t.Errorf("unexpected net score: got=%v want=%v", got, want)
+	}
+}
@@ -0,0 +1,217 @@
+package intel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+
+	"github.com/adevinta/graph-intel-api/log"
+)
+
+// AssetResolution describes how an asset maps to the vertices of the
+// Security Graph.
+type AssetResolution struct {
+	// VertexID is the vertex ID chosen when resolving the asset.
+	VertexID string `json:"vertex_id"`
+
+	// Resolution is the method used to resolve the asset. Valid
+	// values: [ResolutionDirect] and [ResolutionDNS].
+	Resolution string `json:"resolution"`
+
+	// Candidates contains every vertex that matches the asset. Direct
+	// matches are ordered by snapshot timestamp, newest first. DNS
+	// matches are grouped by IP address in the order returned by the
+	// DNS lookup.
+	Candidates []ResolutionCandidate `json:"candidates"`
+}
+
+// ResolutionCandidate represents a vertex that matches an asset.
+type ResolutionCandidate struct {
+	// ID is the vertex ID.
+	ID string `json:"id"`
+
+	// Label is the vertex label.
+	Label string `json:"label"`
+
+	// Property is the vertex property that matches the identifier of
+	// the asset. If DNSFallback is true, it is the property that
+	// matches IP.
+	Property string `json:"property"`
+
+	// Snapshot is the altimeter snapshot that includes the vertex.
+	Snapshot Snapshot `json:"snapshot"`
+
+	// DNSFallback reports whether the vertex was found using the IP
+	// addresses returned by a DNS lookup of the hostname.
+	DNSFallback bool `json:"dns_fallback"`
+
+	// IP is the IP address returned by the DNS lookup that matches the
+	// vertex. It is only set if DNSFallback is true.
+	IP string `json:"ip,omitempty"`
+
+	// Chosen reports whether the vertex was chosen when resolving the
+	// asset.
+	Chosen bool `json:"chosen"`
+}
+
+// Vertex properties that can match the identifier of an asset.
+var (
+	hostnameProperties = []string{"private_dns_name", "public_dns_name", "dns_name"}
+	ipProperties       = []string{"public_ip", "private_ip_address"}
+	arnProperties      = []string{"arn"}
+)
+
+// ResolveAsset returns every vertex that matches a given asset and the one
+// chosen by the resolver used by the other endpoints. The asset is resolved
+// in the latest snapshot that contains it. Candidates from every snapshot
+// are returned.
+func (api API) ResolveAsset(ctx context.Context, typ, identifier string) (AssetResolution, error) {
+	vid, method, err := api.resolveAsset(ctx, typ, identifier, "")
+	if err != nil {
+		return AssetResolution{}, fmt.Errorf("could not resolve asset: %w", err)
+	}
+
+	var cands []ResolutionCandidate
+	switch typ {
+	case "IP":
+		cands, err = api.candidates(ctx, ipTraversal(identifier), ipProperties, identifier)
+	case "Hostname":
+		cands, err = api.hostnameCandidates(ctx, identifier)
+	case "ARN":
+		cands, err = api.candidates(ctx, arnTraversal(identifier), arnProperties, identifier)
+	case "ResourceID":
+		tf, tferr := resourceIDTraversal(identifier)
+		if tferr != nil {
+			return AssetResolution{}, tferr
+		}
+		cands, err = api.candidates(ctx, tf, arnProperties, gremlingo.TextP.EndingWith("/"+identifier))
+	default:
+		return AssetResolution{}, fmt.Errorf("%w: %v", ErrUnsupportedAssetType, typ)
+	}
+	if err != nil {
+		return AssetResolution{}, fmt.Errorf("could not get candidates: %w", err)
+	}
+
+	for i := range cands {
+		cands[i].Chosen = cands[i].ID == vid
+	}
+
+	resolution := AssetResolution{
+		VertexID:   vid,
+		Resolution: method,
+		Candidates: cands,
+	}
+	return resolution, nil
+}
+
+// hostnameCandidates returns the vertices that match a given hostname. Like
+// [API.resolveAsset], if no vertex has the hostname, the IP addresses
+// returned by a DNS lookup are used instead.
+func (api API) hostnameCandidates(ctx context.Context, hostname string) ([]ResolutionCandidate, error) {
+	cands, err := api.candidates(ctx, hostnameTraversal(hostname), hostnameProperties, hostname)
+	if err != nil {
+		return nil, err
+	}
+	if len(cands) > 0 {
+		return cands, nil
+	}
+
+	log.FromContext(ctx).Debug.Printf("graph-intel-api: intel: could not find hostname %q: fallback to DNS lookup", hostname)
+
+	ips, err := api.resolver.LookupHost(ctx, hostname)
+	if err != nil {
+		return nil, fmt.Errorf("DNS lookup error for %q: %w", hostname, err)
+	}
+
+	for _, ip := range ips {
+		ipCands, err := api.candidates(ctx, ipTraversal(ip), ipProperties, ip)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range ipCands {
+			c.DNSFallback = true
+			c.IP = ip
+			cands = append(cands, c)
+		}
+	}
+	return cands, nil
+}
+
+// candidates returns the vertices selected by the traversal returned by tf
+// that are included in an altimeter snapshot, ordered by snapshot timestamp,
+// newest first. props are the properties that can match value, which can be
+// a literal or a Gremlin predicate. The first one that matches is reported as
+// the matching property of every vertex.
+func (api API) candidates(ctx context.Context, tf func(g *gremlingo.GraphTraversalSource) *gremlingo.GraphTraversal, props []string, value any) ([]ResolutionCandidate, error) {
+	results, err := api.conn.Query(ctx, "resolve_candidates", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		t := g
+
+		if api.cfg.ResolveTimeoutMs > 0 {
+			t = t.With("evaluationTimeout", api.cfg.ResolveTimeoutMs)
+		}
+
+		var matches []any
+		for _, prop := range props {
+			matches = append(matches, gremlingo.T__.Has(prop, value).Constant(prop))
+		}
+		matches = append(matches, gremlingo.T__.Constant(""))
+
+		return tf(t).
+			As("assets").
+			In("includes").HasLabel("altimeter_snapshot").
+			As("snapshots").
+			In("universe_of").HasLabel("Universe").Has("namespace", "altimeter").Has("version", 1).
+			Order().By(gremlingo.T__.Select("snapshots").Values("timestamp"), gremlingo.Order.Desc).
+			Project("id", "label", "property", "snapshot_id", "timestamp").
+			By(gremlingo.T__.Select("assets").Id()).
+			By(gremlingo.T__.Select("assets").Label()).
+			By(gremlingo.T__.Select("assets").Coalesce(matches...)).
+			By(gremlingo.T__.Select("snapshots").Id()).
+			By(gremlingo.T__.Select("snapshots").Values("timestamp")).
+			ToList()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	cands := []ResolutionCandidate{}
+	for _, result := range results {
+		c, err := parseCandidate(result)
+		if err != nil {
+			return nil, fmt.Errorf("invalid result: %w", err)
+		}
+		cands = append(cands, c)
+	}
+	return cands, nil
+}
+
+// parseCandidate parses a Gremlin result returned by [API.candidates].
+func parseCandidate(result *gremlingo.Result) (ResolutionCandidate, error) {
+	m, err := resultMap(result)
+	if err != nil {
+		return ResolutionCandidate{}, err
+	}
+
+	var c ResolutionCandidate
+	if c.ID, err = mapString(m, "id"); err != nil {
+		return ResolutionCandidate{}, err
+	}
+	if c.Label, err = mapString(m, "label"); err != nil {
+		return ResolutionCandidate{}, err
+	}
+	if c.Property, err = mapString(m, "property"); err != nil {
+		return ResolutionCandidate{}, err
+	}
+	if c.Snapshot.ID, err = mapString(m, "snapshot_id"); err != nil {
+		return ResolutionCandidate{}, err
+	}
+	ts, err := mapInt(m, "timestamp")
+	if err != nil {
+		return ResolutionCandidate{}, err
+	}
+	c.Snapshot.Timestamp = time.Unix(ts, 0).UTC()
+
+	return c, nil
+}
@@ -0,0 +1,112 @@
+package intel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adevinta/graph-intel-api/gremlin"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAPIResolveAsset(t *testing.T) {
+	s0 := Snapshot{ID: "s0", Timestamp: time.Unix(1000, 0).UTC()}
+	s1 := Snapshot{ID: "s1", Timestamp: time.Unix(2000, 0).UTC()}
+
+	tests := []struct {
+		name       string
+		typ        string
+		identifier string
+		want       AssetResolution
+		wantErr    error
+	}{
+		{
+			name:       "IP in several snapshots",
+			typ:        "IP",
+			identifier: "1.2.3.4",
+			want: AssetResolution{
+				VertexID:   "s1-ni0",
+				Resolution: ResolutionDirect,
+				Candidates: []ResolutionCandidate{
+					{
+						ID:       "s1-ni0",
+						Label:    "ec2:network-interface",
+						Property: "public_ip",
+						Snapshot: s1,
+						Chosen:   true,
+					},
+					{
+						ID:       "s0-ni0",
+						Label:    "ec2:network-interface",
+						Property: "public_ip",
+						Snapshot: s0,
+						Chosen:   false,
+					},
+				},
+			},
+		},
+		{
+			name:       "ResourceID",
+			typ:        "ResourceID",
+			identifier: "i-0123456789abcdef0",
+			want: AssetResolution{
+				VertexID:   "s1-i0",
+				Resolution: ResolutionDirect,
+				Candidates: []ResolutionCandidate{
+					{
+						ID:       "s1-i0",
+						Label:    "ec2:instance",
+						Property: "arn",
+						Snapshot: s1,
+						Chosen:   true,
+					},
+				},
+			},
+		},
+		{
+			name:       "not found",
+			typ:        "IP",
+			identifier: "5.6.7.8",
+			wantErr:    ErrNotFound,
+		},
+		{
+			name:       "unsupported type",
+			typ:        "unknown",
+			identifier: "1.2.3.4",
+			wantErr:    ErrUnsupportedAssetType,
+		},
+	}
+
+	if err := setupHistoricalGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+		ResolveTimeoutMs:     60000,
+		BlastRadiusTimeoutMs: 60000,
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := intelAPI.ResolveAsset(context.Background(), tt.typ, tt.identifier)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: got=%v want=%v", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("resolution mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
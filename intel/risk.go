@@ -0,0 +1,221 @@
+package intel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Names of the components of the risk score.
+const (
+	// RiskComponentNet is the component calculated using the "net"
+	// blast radius model.
+	RiskComponentNet = "net"
+
+	// RiskComponentInternetExposure is the component calculated from
+	// the internet exposure of the asset. It is 1 if the asset is
+	// exposed and 0 otherwise.
+	RiskComponentInternetExposure = "internet_exposure"
+
+	// RiskComponentIAM is the component calculated using the "iam"
+	// blast radius model. It is only available if the asset is granted
+	// any permission by IAM.
+	RiskComponentIAM = "iam"
+)
+
+// RiskWeights contains the weights used to combine the components of the
+// risk score.
+type RiskWeights struct {
+	// Net is the weight of the network blast radius.
+	Net float64
+
+	// InternetExposure is the weight of the internet exposure.
+	InternetExposure float64
+
+	// IAM is the weight of the IAM blast radius.
+	IAM float64
+}
+
+// RiskScoreResult represents the composite risk score of an asset.
+type RiskScoreResult struct {
+	// Score is the weighted average of the normalized scores of the
+	// available components. It is in the range [0, 1].
+	Score float64 `json:"score"`
+
+	// Components contains the individual scores combined into Score.
+	Components []RiskComponent `json:"components"`
+
+	// Snapshot is the altimeter snapshot the score was calculated
+	// against.
+	Snapshot Snapshot `json:"snapshot"`
+}
+
+// RiskComponent represents one of the scores combined into a risk score.
+type RiskComponent struct {
+	// Name is the name of the component.
+	Name string `json:"name"`
+
+	// Score is the score of the component.
+	Score float64 `json:"score"`
+
+	// NormalizedScore is the score of the component normalized to the
+	// range [0, 1]. The blast radius scores are not bounded, so they
+	// are normalized as score/(1+score). The internet exposure score is
+	// already 0 or 1.
+	NormalizedScore float64 `json:"normalized_score"`
+
+	// Weight is the weight of the component.
+	Weight float64 `json:"weight"`
+
+	// Available reports whether the component was considered when
+	// calculating the risk score.
+	Available bool `json:"available"`
+}
+
+// RiskScore returns the composite risk score of a given asset. The network
+// blast radius, internet exposure and IAM blast radius of the asset are
+// calculated concurrently, normalized and combined using
+// [Config.RiskWeights]. The asset is resolved in the latest snapshot that
+// contains it. If any analysis fails, the others are canceled and the error of
+// the first failing one is returned.
+func (api API) RiskScore(ctx context.Context, typ, identifier string) (RiskScoreResult, error) {
+	vid, _, err := api.resolveAsset(ctx, typ, identifier, "")
+	if err != nil {
+		return RiskScoreResult{}, fmt.Errorf("could not resolve asset: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		snap     snapshot
+		netRscs  []resource
+		exposure InternetExposureResult
+		iamRscs  []resource
+	)
+
+	analyses := []struct {
+		name string
+		f    func() error
+	}{
+		{
+			name: "snapshot",
+			f: func() (err error) {
+				snap, err = api.vertexSnapshot(ctx, vid)
+				return err
+			},
+		},
+		{
+			name: RiskComponentNet,
+			f: func() (err error) {
+				netRscs, err = api.netReachableResources(ctx, vid, false)
+				return err
+			},
+		},
+		{
+			name: RiskComponentInternetExposure,
+			f: func() (err error) {
+				exposure, err = api.internetExposure(ctx, vid)
+				return err
+			},
+		},
+		{
+			name: RiskComponentIAM,
+			f: func() (err error) {
+				iamRscs, err = api.iamReachableResources(ctx, vid, false)
+				return err
+			},
+		},
+	}
+
+	for _, a := range analyses {
+		wg.Add(1)
+		go func(name string, f func() error) {
+			defer wg.Done()
+			if err := f(); err != nil {
+				// The other analyses fail with the context
+				// error once canceled, so only the first
+				// error is the actual cause.
+				once.Do(func() {
+					firstErr = fmt.Errorf("could not calculate %v: %w", name, err)
+					cancel()
+				})
+			}
+		}(a.name, a.f)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return RiskScoreResult{}, firstErr
+	}
+
+	exposureScore := 0.0
+	if exposure.Exposed {
+		exposureScore = 1.0
+	}
+
+	netScore := score(netRscs, netScorer{})
+	iamScore := score(iamRscs, iamScorer{})
+
+	components := []RiskComponent{
+		{
+			Name:            RiskComponentNet,
+			Score:           netScore,
+			NormalizedScore: normalizeScore(netScore),
+			Weight:          api.cfg.RiskWeights.Net,
+			Available:       true,
+		},
+		{
+			Name:            RiskComponentInternetExposure,
+			Score:           exposureScore,
+			NormalizedScore: exposureScore,
+			Weight:          api.cfg.RiskWeights.InternetExposure,
+			Available:       true,
+		},
+		{
+			Name:            RiskComponentIAM,
+			Score:           iamScore,
+			NormalizedScore: normalizeScore(iamScore),
+			Weight:          api.cfg.RiskWeights.IAM,
+			Available:       len(iamRscs) > 0,
+		},
+	}
+
+	result := RiskScoreResult{
+		Score:      combineRisk(components),
+		Components: components,
+		Snapshot:   Snapshot{ID: snap.id, Timestamp: snap.timestamp},
+	}
+	return result, nil
+}
+
+// normalizeScore maps a non-negative blast radius score to the range [0, 1).
+// The mapping is monotonic, so the order of the scores is preserved.
+func normalizeScore(s float64) float64 {
+	if s <= 0 {
+		return 0
+	}
+	return s / (1 + s)
+}
+
+// combineRisk returns the weighted average of the normalized scores of the
+// available components. It returns 0 if the weights of the available
+// components sum 0.
+func combineRisk(components []RiskComponent) float64 {
+	var sum, weights float64
+	for _, c := range components {
+		if !c.Available {
+			continue
+		}
+		sum += c.Weight * c.NormalizedScore
+		weights += c.Weight
+	}
+
+	if weights == 0 {
+		return 0
+	}
+	return sum / weights
+}
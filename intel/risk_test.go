@@ -0,0 +1,121 @@
+package intel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adevinta/graph-intel-api/gremlin"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCombineRisk(t *testing.T) {
+	tests := []struct {
+		name       string
+		components []RiskComponent
+		want       float64
+	}{
+		{
+			name: "all available",
+			components: []RiskComponent{
+				{Name: "net", Score: 1, NormalizedScore: 0.5, Weight: 2, Available: true},
+				{Name: "internet_exposure", Score: 1, NormalizedScore: 1, Weight: 1, Available: true},
+				{Name: "iam", Score: 3, NormalizedScore: 0.75, Weight: 1, Available: true},
+			},
+			want: 0.6875,
+		},
+		{
+			name: "unavailable component",
+			components: []RiskComponent{
+				{Name: "net", Score: 1, NormalizedScore: 0.5, Weight: 2, Available: true},
+				{Name: "internet_exposure", Score: 1, NormalizedScore: 1, Weight: 1, Available: true},
+				{Name: "iam", Score: 0, NormalizedScore: 0, Weight: 1, Available: false},
+			},
+			want: 2.0 / 3.0,
+		},
+		{
+			name: "zero weights",
+			components: []RiskComponent{
+				{Name: "net", Score: 1, NormalizedScore: 0.5, Weight: 0, Available: true},
+			},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := combineRisk(tt.components); got != tt.want {
+				t.Errorf("unexpected score: got=%v want=%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeScore(t *testing.T) {
+	tests := []struct {
+		name  string
+		score float64
+		want  float64
+	}{
+		{name: "zero", score: 0, want: 0},
+		{name: "one", score: 1, want: 0.5},
+		{name: "large", score: 99, want: 0.99},
+		{name: "negative", score: -1, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeScore(tt.score); got != tt.want {
+				t.Errorf("unexpected score: got=%v want=%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIRiskScore(t *testing.T) {
+	netScore := wantBlastRadiusResult.Score
+	netNormalized := netScore / (1 + netScore)
+
+	want := RiskScoreResult{
+		Score: 2 * netNormalized / 3,
+		Components: []RiskComponent{
+			{Name: "net", Score: netScore, NormalizedScore: netNormalized, Weight: 2, Available: true},
+			{Name: "internet_exposure", Score: 0, NormalizedScore: 0, Weight: 1, Available: true},
+			{Name: "iam", Score: 0, NormalizedScore: 0, Weight: 1, Available: false},
+		},
+		Snapshot: Snapshot{ID: "s0", Timestamp: time.Unix(0, 0).UTC()},
+	}
+
+	if err := setupBlastRadiusGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+		ResolveTimeoutMs:     60000,
+		BlastRadiusTimeoutMs: 60000,
+		RiskWeights: RiskWeights{
+			Net:              2,
+			InternetExposure: 1,
+			IAM:              1,
+		},
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	got, err := intelAPI.RiskScore(context.Background(), "IP", "1.2.3.4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("risk score mismatch (-want +got):\n%v", diff)
+	}
+}
@@ -0,0 +1,283 @@
+package intel
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+)
+
+const (
+	// DefaultSearchLimit is the number of assets returned per page when
+	// no limit is specified.
+	DefaultSearchLimit = 100
+
+	// MaxSearchLimit is the maximum number of assets returned per page.
+	MaxSearchLimit = 1000
+)
+
+var (
+	// ErrInvalidCursor is returned when a pagination cursor cannot be
+	// decoded or does not match the requested snapshot.
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrInvalidLimit is returned when the requested page size is out of
+	// range.
+	ErrInvalidLimit = errors.New("invalid limit")
+)
+
+// AssetQuery defines the assets returned by [API.SearchAssets].
+type AssetQuery struct {
+	// Labels are the vertex labels of the assets. If not empty, only
+	// the assets with any of these labels are returned.
+	Labels []string
+
+	// Properties are the property filters that the assets must match.
+	Properties []PropertyFilter
+
+	// SnapshotID is the vertex ID of the altimeter snapshot to search.
+	// If empty, the latest snapshot is used.
+	SnapshotID string
+
+	// Cursor is the cursor returned along with the previous page. If
+	// empty, the first page is returned. It must be used with the same
+	// filters as the previous page.
+	Cursor string
+
+	// Limit is the maximum number of assets returned. If zero,
+	// [DefaultSearchLimit] is used. It cannot exceed [MaxSearchLimit].
+	Limit int
+}
+
+// PropertyFilter filters assets by the value of a property.
+type PropertyFilter struct {
+	// Name is the name of the property.
+	Name string
+
+	// Value is the value of the property. It is compared with the
+	// property value as a string, so properties stored with other types,
+	// like numbers or booleans, never match.
+	Value string
+
+	// Prefix reports whether Value is a prefix of the property value
+	// instead of the whole value.
+	Prefix bool
+}
+
+// AssetPage represents a page of the assets returned by [API.SearchAssets].
+type AssetPage struct {
+	// Assets contains the assets in the page, ordered by vertex ID.
+	Assets []AssetSummary `json:"assets"`
+
+	// Snapshot is the altimeter snapshot that was searched.
+	Snapshot Snapshot `json:"snapshot"`
+
+	// NextCursor is the cursor of the next page. It is empty if there
+	// are no more assets.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// AssetSummary represents an asset returned by [API.SearchAssets].
+type AssetSummary struct {
+	// ID is the vertex ID.
+	ID string `json:"id"`
+
+	// Label is the vertex label.
+	Label string `json:"label"`
+
+	// Properties contains the properties of the vertex. Properties
+	// with a single value are not wrapped in a list.
+	Properties map[string]any `json:"properties"`
+}
+
+// searchCursor is the decoded representation of a pagination cursor. The
+// cursor pins the snapshot of the first page, so the pages of a search are
+// consistent even if new snapshots are created in the meantime. It also
+// stores the vertex ID of the last asset of the previous page, so the next
+// page starts right after it without scanning the previous pages.
+type searchCursor struct {
+	SnapshotID string `json:"s"`
+	LastID     string `json:"l"`
+}
+
+// encode returns the opaque representation of the cursor.
+func (c searchCursor) encode() (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor decodes the opaque representation of a cursor.
+func decodeCursor(s string) (searchCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return searchCursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	var c searchCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return searchCursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if c.SnapshotID == "" || c.LastID == "" {
+		return searchCursor{}, fmt.Errorf("%w: missing snapshot or last ID", ErrInvalidCursor)
+	}
+	return c, nil
+}
+
+// SearchAssets returns the assets of an altimeter snapshot that match the
+// provided query. Results are paginated. Use the returned
+// [AssetPage.NextCursor] to get the next page.
+func (api API) SearchAssets(ctx context.Context, q AssetQuery) (AssetPage, error) {
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultSearchLimit
+	}
+	if limit < 0 || limit > MaxSearchLimit {
+		return AssetPage{}, fmt.Errorf("%w: %v not in range [1, %v]", ErrInvalidLimit, limit, MaxSearchLimit)
+	}
+
+	var cursor searchCursor
+	if q.Cursor != "" {
+		var err error
+		if cursor, err = decodeCursor(q.Cursor); err != nil {
+			return AssetPage{}, err
+		}
+		if q.SnapshotID != "" && q.SnapshotID != cursor.SnapshotID {
+			return AssetPage{}, fmt.Errorf("%w: snapshot mismatch", ErrInvalidCursor)
+		}
+	}
+
+	sid := q.SnapshotID
+	if cursor.SnapshotID != "" {
+		sid = cursor.SnapshotID
+	}
+
+	var (
+		snap snapshot
+		err  error
+	)
+	if sid == "" {
+		snap, err = api.snapshotAt(ctx, time.Now())
+	} else {
+		snap, err = api.snapshotByID(ctx, sid)
+	}
+	if err != nil {
+		return AssetPage{}, fmt.Errorf("could not get snapshot: %w", err)
+	}
+
+	results, err := api.conn.Query(ctx, "search_assets", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		t := g
+
+		if api.cfg.ResolveTimeoutMs > 0 {
+			t = t.With("evaluationTimeout", api.cfg.ResolveTimeoutMs)
+		}
+
+		traversal := t.
+			V(snap.id).
+			Out("includes")
+
+		if len(q.Labels) > 0 {
+			traversal = traversal.HasLabel(toAnySlice(q.Labels)...)
+		}
+
+		for _, pf := range q.Properties {
+			if pf.Prefix {
+				traversal = traversal.Has(pf.Name, gremlingo.TextP.StartingWith(pf.Value))
+			} else {
+				traversal = traversal.Has(pf.Name, pf.Value)
+			}
+		}
+
+		if cursor.LastID != "" {
+			traversal = traversal.Has(gremlingo.T.Id, gremlingo.P.Gt(cursor.LastID))
+		}
+
+		// Request one more asset to know if there is a next page.
+		return traversal.
+			Order().By(gremlingo.T__.Id()).
+			Limit(int64(limit+1)).
+			Project("id", "label", "properties").
+			By(gremlingo.T__.Id()).
+			By(gremlingo.T__.Label()).
+			By(gremlingo.T__.ValueMap()).
+			ToList()
+	})
+	if err != nil {
+		return AssetPage{}, fmt.Errorf("query error: %w", err)
+	}
+
+	page := AssetPage{
+		Assets:   []AssetSummary{},
+		Snapshot: Snapshot{ID: snap.id, Timestamp: snap.timestamp},
+	}
+
+	for i, result := range results {
+		if i == limit {
+			last := page.Assets[len(page.Assets)-1]
+			next := searchCursor{SnapshotID: snap.id, LastID: last.ID}
+			if page.NextCursor, err = next.encode(); err != nil {
+				return AssetPage{}, fmt.Errorf("could not encode cursor: %w", err)
+			}
+			break
+		}
+
+		m, err := resultMap(result)
+		if err != nil {
+			return AssetPage{}, fmt.Errorf("invalid result: %w", err)
+		}
+
+		var asset AssetSummary
+		if asset.ID, err = mapString(m, "id"); err != nil {
+			return AssetPage{}, fmt.Errorf("invalid result: %w", err)
+		}
+		if asset.Label, err = mapString(m, "label"); err != nil {
+			return AssetPage{}, fmt.Errorf("invalid result: %w", err)
+		}
+		if asset.Properties, err = parseProperties(m["properties"]); err != nil {
+			return AssetPage{}, fmt.Errorf("invalid result: %w", err)
+		}
+		page.Assets = append(page.Assets, asset)
+	}
+
+	return page, nil
+}
+
+// snapshotByID returns the altimeter snapshot with the provided vertex ID.
+func (api API) snapshotByID(ctx context.Context, sid string) (snapshot, error) {
+	results, err := api.conn.Query(ctx, "snapshot", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		t := g
+
+		if api.cfg.ResolveTimeoutMs > 0 {
+			t = t.With("evaluationTimeout", api.cfg.ResolveTimeoutMs)
+		}
+
+		return t.
+			V(sid).
+			HasLabel("altimeter_snapshot").
+			Where(gremlingo.T__.In("universe_of").HasLabel("Universe").Has("namespace", "altimeter").Has("version", 1)).
+			Project("id", "timestamp").
+			By(gremlingo.T__.Id()).
+			By(gremlingo.T__.Values("timestamp")).
+			ToList()
+	})
+	if err != nil {
+		return snapshot{}, fmt.Errorf("query error: %w", err)
+	}
+
+	if len(results) == 0 {
+		return snapshot{}, ErrNotFound
+	}
+
+	snap, err := parseSnapshot(results[0])
+	if err != nil {
+		return snapshot{}, fmt.Errorf("invalid result: %w", err)
+	}
+
+	return snap, nil
+}
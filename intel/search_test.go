@@ -0,0 +1,242 @@
+package intel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adevinta/graph-intel-api/gremlin"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	tests := []struct {
+		name       string
+		cursor     string
+		want       searchCursor
+		wantNilErr bool
+	}{
+		{
+			name:       "valid cursor",
+			cursor:     "eyJzIjoiczEiLCJsIjoiczEtcjAifQ",
+			want:       searchCursor{SnapshotID: "s1", LastID: "s1-r0"},
+			wantNilErr: true,
+		},
+		{
+			name:       "invalid encoding",
+			cursor:     "not a cursor",
+			wantNilErr: false,
+		},
+		{
+			name:       "invalid JSON",
+			cursor:     "bm90IEpTT04",
+			wantNilErr: false,
+		},
+		{
+			name:       "missing snapshot",
+			cursor:     "eyJsIjoiczEtcjAifQ",
+			wantNilErr: false,
+		},
+		{
+			name:       "missing last ID",
+			cursor:     "eyJzIjoiczEifQ",
+			wantNilErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.cursor)
+			if (err == nil) != tt.wantNilErr {
+				t.Fatalf("unexpected error: wantNilErr=%v, got=%v", tt.wantNilErr, err)
+			}
+
+			if err != nil && !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("unexpected error: got=%v want=%v", err, ErrInvalidCursor)
+			}
+
+			if got != tt.want {
+				t.Errorf("unexpected cursor: got=%+v want=%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchCursorEncode(t *testing.T) {
+	want := searchCursor{SnapshotID: "s1", LastID: "s1-r0"}
+
+	s, err := want.encode()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := decodeCursor(s)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got != want {
+		t.Errorf("unexpected cursor: got=%+v want=%+v", got, want)
+	}
+}
+
+func TestAPISearchAssets(t *testing.T) {
+	s0 := Snapshot{ID: "s0", Timestamp: time.Unix(1000, 0).UTC()}
+	s1 := Snapshot{ID: "s1", Timestamp: time.Unix(2000, 0).UTC()}
+
+	tests := []struct {
+		name    string
+		query   AssetQuery
+		want    AssetPage
+		wantErr error
+	}{
+		{
+			name:  "label",
+			query: AssetQuery{Labels: []string{"ec2:security-group"}},
+			want: AssetPage{
+				Assets: []AssetSummary{
+					{ID: "s1-sg0", Label: "ec2:security-group", Properties: map[string]any{}},
+					{ID: "s1-sg1", Label: "ec2:security-group", Properties: map[string]any{}},
+				},
+				Snapshot: s1,
+			},
+		},
+		{
+			name: "property",
+			query: AssetQuery{
+				Properties: []PropertyFilter{{Name: "cidr_ip", Value: "0.0.0.0/0"}},
+			},
+			want: AssetPage{
+				Assets: []AssetSummary{
+					{ID: "s1-r1", Label: "ip_range", Properties: map[string]any{"cidr_ip": "0.0.0.0/0"}},
+				},
+				Snapshot: s1,
+			},
+		},
+		{
+			name: "property prefix in snapshot",
+			query: AssetQuery{
+				Labels:     []string{"ip_range"},
+				Properties: []PropertyFilter{{Name: "cidr_ip", Value: "10.", Prefix: true}},
+				SnapshotID: "s0",
+			},
+			want: AssetPage{
+				Assets: []AssetSummary{
+					{ID: "s0-r0", Label: "ip_range", Properties: map[string]any{"cidr_ip": "10.0.0.0/8"}},
+				},
+				Snapshot: s0,
+			},
+		},
+		{
+			name:  "no matches",
+			query: AssetQuery{Labels: []string{"rds:db"}},
+			want: AssetPage{
+				Assets:   []AssetSummary{},
+				Snapshot: s1,
+			},
+		},
+		{
+			name:    "unknown snapshot",
+			query:   AssetQuery{SnapshotID: "unknown"},
+			wantErr: ErrNotFound,
+		},
+		{
+			name:    "invalid cursor",
+			query:   AssetQuery{Cursor: "not a cursor"},
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:    "invalid limit",
+			query:   AssetQuery{Limit: MaxSearchLimit + 1},
+			wantErr: ErrInvalidLimit,
+		},
+	}
+
+	if err := setupHistoricalGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+		ResolveTimeoutMs:     60000,
+		BlastRadiusTimeoutMs: 60000,
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := intelAPI.SearchAssets(context.Background(), tt.query)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: got=%v want=%v", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("page mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPISearchAssets_Pagination(t *testing.T) {
+	if err := setupHistoricalGraph(); err != nil {
+		t.Fatalf("error setting up the initial graph: %v", err)
+	}
+
+	cfg := Config{
+		GremlinConfig: gremlin.Config{
+			Endpoint: gremlinEndpoint,
+			AuthMode: gremlinAuthMode,
+		},
+		ResolveTimeoutMs:     60000,
+		BlastRadiusTimeoutMs: 60000,
+	}
+	intelAPI, err := NewAPI(cfg)
+	if err != nil {
+		t.Fatalf("error creating intel API: %v", err)
+	}
+	defer intelAPI.Close()
+
+	// The latest snapshot contains 10 assets.
+	var (
+		ids    []string
+		cursor string
+		pages  int
+	)
+	for {
+		page, err := intelAPI.SearchAssets(context.Background(), AssetQuery{Cursor: cursor, Limit: 4})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		pages++
+
+		for _, asset := range page.Assets {
+			ids = append(ids, asset.ID)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	if pages != 3 {
+		t.Errorf("unexpected number of pages: got=%v want=%v", pages, 3)
+	}
+
+	want := []string{
+		"s1-er0", "s1-er1", "s1-i0", "s1-ir0", "s1-ni0",
+		"s1-r0", "s1-r1", "s1-sg0", "s1-sg1", "s1-uigp0",
+	}
+	if diff := cmp.Diff(want, ids); diff != "" {
+		t.Errorf("assets mismatch (-want +got):\n%v", diff)
+	}
+}
@@ -8,11 +8,16 @@ package log
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Logger is the interface for logging messages.
@@ -44,15 +49,31 @@ const (
 	DisabledLevel
 )
 
+// Format represents the output format of the log messages.
+type Format int
+
+// Different output formats.
+const (
+	// TextFormat writes every message in a line prefixed by the date.
+	// Fields are appended to the message as key=value pairs.
+	TextFormat Format = iota
+
+	// JSONFormat writes every message as a JSON object in a line. The
+	// object contains the time, level, message and fields.
+	JSONFormat
+)
+
 // The set of default loggers for each log level.
 var (
-	Debug = &logger{DebugLevel}
-	Info  = &logger{InfoLevel}
-	Error = &logger{ErrorLevel}
+	Debug = &logger{level: DebugLevel}
+	Info  = &logger{level: InfoLevel}
+	Error = &logger{level: ErrorLevel}
 )
 
 type globalState struct {
 	currentLevel  Level
+	format        Format
+	output        io.Writer
 	defaultLogger Logger
 }
 
@@ -60,7 +81,9 @@ var (
 	mu    sync.RWMutex
 	state = globalState{
 		currentLevel:  InfoLevel,
-		defaultLogger: newDefaultLogger(os.Stderr),
+		format:        TextFormat,
+		output:        os.Stderr,
+		defaultLogger: newDefaultLogger(os.Stderr, TextFormat),
 	}
 )
 
@@ -70,7 +93,11 @@ func globals() globalState {
 	return state
 }
 
-func newDefaultLogger(w io.Writer) Logger {
+func newDefaultLogger(w io.Writer, format Format) Logger {
+	if format == JSONFormat {
+		// The time is part of the JSON object.
+		return log.New(w, "", 0)
+	}
 	return log.New(w, "", log.Ldate|log.Ltime|log.LUTC|log.Lmicroseconds)
 }
 
@@ -112,15 +139,23 @@ func SetOutput(w io.Writer) {
 	mu.Lock()
 	defer mu.Unlock()
 
+	state.output = w
 	if w == nil {
 		state.defaultLogger = nil
 	} else {
-		state.defaultLogger = newDefaultLogger(w)
+		state.defaultLogger = newDefaultLogger(w, state.format)
 	}
 }
 
+// field is a key/value pair attached to a log message.
+type field struct {
+	key   string
+	value any
+}
+
 type logger struct {
-	level Level
+	level  Level
+	fields []field
 }
 
 var _ Logger = (*logger)(nil)
@@ -132,9 +167,14 @@ func (l *logger) Printf(format string, v ...any) {
 	if l.level < g.currentLevel {
 		return // Don't log at lower levels.
 	}
-	if g.defaultLogger != nil {
-		g.defaultLogger.Printf(format, v...)
+	if g.defaultLogger == nil {
+		return
+	}
+	if l.structured(g) {
+		g.defaultLogger.Print(l.encode(g.format, fmt.Sprintf(format, v...)))
+		return
 	}
+	g.defaultLogger.Printf(format, v...)
 }
 
 // Print writes a message to the log.
@@ -144,9 +184,14 @@ func (l *logger) Print(v ...any) {
 	if l.level < g.currentLevel {
 		return // Don't log at lower levels.
 	}
-	if g.defaultLogger != nil {
-		g.defaultLogger.Print(v...)
+	if g.defaultLogger == nil {
+		return
 	}
+	if l.structured(g) {
+		g.defaultLogger.Print(l.encode(g.format, fmt.Sprint(v...)))
+		return
+	}
+	g.defaultLogger.Print(v...)
 }
 
 // Println writes a line to the log.
@@ -156,9 +201,14 @@ func (l *logger) Println(v ...any) {
 	if l.level < g.currentLevel {
 		return // Don't log at lower levels.
 	}
-	if g.defaultLogger != nil {
-		g.defaultLogger.Println(v...)
+	if g.defaultLogger == nil {
+		return
+	}
+	if l.structured(g) {
+		g.defaultLogger.Print(l.encode(g.format, strings.TrimSuffix(fmt.Sprintln(v...), "\n")))
+		return
 	}
+	g.defaultLogger.Println(v...)
 }
 
 // Fatal writes a message to the log and aborts, regardless of the current log
@@ -166,11 +216,14 @@ func (l *logger) Println(v ...any) {
 func (l *logger) Fatal(v ...any) {
 	g := globals()
 
-	if g.defaultLogger != nil {
-		g.defaultLogger.Fatal(v...)
-	} else {
+	if g.defaultLogger == nil {
 		log.Fatal(v...)
 	}
+	if l.structured(g) {
+		g.defaultLogger.Fatal(l.encode(g.format, fmt.Sprint(v...)))
+		return
+	}
+	g.defaultLogger.Fatal(v...)
 }
 
 // Fatalf writes a formatted message to the log and aborts, regardless of the
@@ -178,11 +231,80 @@ func (l *logger) Fatal(v ...any) {
 func (l *logger) Fatalf(format string, v ...any) {
 	g := globals()
 
-	if g.defaultLogger != nil {
-		g.defaultLogger.Fatalf(format, v...)
-	} else {
+	if g.defaultLogger == nil {
 		log.Fatalf(format, v...)
 	}
+	if l.structured(g) {
+		g.defaultLogger.Fatal(l.encode(g.format, fmt.Sprintf(format, v...)))
+		return
+	}
+	g.defaultLogger.Fatalf(format, v...)
+}
+
+// structured reports whether the message must be encoded before being passed
+// to the default logger. That is, if the logger has fields or the output
+// format is JSON.
+func (l *logger) structured(g globalState) bool {
+	return len(l.fields) > 0 || g.format == JSONFormat
+}
+
+// encode encodes msg and the fields of the logger using the provided format.
+func (l *logger) encode(format Format, msg string) string {
+	if format == JSONFormat {
+		return l.encodeJSON(msg)
+	}
+	return l.encodeText(msg)
+}
+
+// encodeText returns msg followed by the fields of the logger as key=value
+// pairs.
+func (l *logger) encodeText(msg string) string {
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for _, f := range l.fields {
+		sb.WriteByte(' ')
+		sb.WriteString(f.key)
+		sb.WriteByte('=')
+
+		v := fmt.Sprint(f.value)
+		if strings.ContainsAny(v, " \t\n\"=") {
+			v = strconv.Quote(v)
+		}
+		sb.WriteString(v)
+	}
+	return sb.String()
+}
+
+// encodeJSON returns a JSON object with the time, level, msg and the fields
+// of the logger.
+func (l *logger) encodeJSON(msg string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	writeJSONField(&buf, "time", time.Now().UTC().Format(time.RFC3339Nano))
+	buf.WriteByte(',')
+	writeJSONField(&buf, "level", toString(l.level))
+	buf.WriteByte(',')
+	writeJSONField(&buf, "msg", msg)
+	for _, f := range l.fields {
+		buf.WriteByte(',')
+		writeJSONField(&buf, f.key, f.value)
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// writeJSONField writes a JSON encoded key/value pair into buf. If the value
+// cannot be encoded as JSON, its string representation is used.
+func writeJSONField(buf *bytes.Buffer, key string, value any) {
+	k, _ := json.Marshal(key)
+	buf.Write(k)
+	buf.WriteByte(':')
+
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(v)
 }
 
 // String returns the name of the logger.
@@ -218,6 +340,34 @@ func toLevel(level string) (Level, error) {
 	return DisabledLevel, fmt.Errorf("invalid log level %q", level)
 }
 
+func toFormat(format string) (Format, error) {
+	switch format {
+	case "text":
+		return TextFormat, nil
+	case "json":
+		return JSONFormat, nil
+	}
+	return TextFormat, fmt.Errorf("invalid log format %q", format)
+}
+
+// SetFormat sets the output format of the default loggers. Valid values:
+// "text", "json".
+func SetFormat(format string) error {
+	f, err := toFormat(format)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	state.format = f
+	if state.output != nil {
+		state.defaultLogger = newDefaultLogger(state.output, f)
+	}
+	return nil
+}
+
 // GetLevel returns the current logging level.
 func GetLevel() string {
 	g := globals()
@@ -272,3 +422,77 @@ func Fatal(v ...any) {
 func Fatalf(format string, v ...any) {
 	Info.Fatalf(format, v...)
 }
+
+// An Entry groups a logger per level. All of them attach the same key/value
+// fields to the log messages.
+type Entry struct {
+	// Debug is the logger for the debug level.
+	Debug Logger
+
+	// Info is the logger for the info level.
+	Info Logger
+
+	// Error is the logger for the error level.
+	Error Logger
+
+	fields []field
+}
+
+// defaultEntry is the [Entry] made of the default loggers.
+var defaultEntry = &Entry{
+	Debug: Debug,
+	Info:  Info,
+	Error: Error,
+}
+
+// With returns an [Entry] that attaches the provided key/value pairs to the
+// log messages. kv must alternate keys and values. Keys must be strings.
+func With(kv ...any) *Entry {
+	return defaultEntry.With(kv...)
+}
+
+// With returns a copy of e that also attaches the provided key/value pairs
+// to the log messages. kv must alternate keys and values. Keys must be
+// strings.
+func (e *Entry) With(kv ...any) *Entry {
+	fields := make([]field, len(e.fields), len(e.fields)+len(kv)/2+1)
+	copy(fields, e.fields)
+
+	for i := 0; i < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+
+		var value any = "(MISSING)"
+		if i+1 < len(kv) {
+			value = kv[i+1]
+		}
+
+		fields = append(fields, field{key: key, value: value})
+	}
+
+	return &Entry{
+		Debug:  &logger{level: DebugLevel, fields: fields},
+		Info:   &logger{level: InfoLevel, fields: fields},
+		Error:  &logger{level: ErrorLevel, fields: fields},
+		fields: fields,
+	}
+}
+
+// ctxKey is the key of the [Entry] stored in a context.
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx that carries e.
+func NewContext(ctx context.Context, e *Entry) context.Context {
+	return context.WithValue(ctx, ctxKey{}, e)
+}
+
+// FromContext returns the [Entry] carried by ctx. If ctx does not carry an
+// [Entry], the default loggers are returned.
+func FromContext(ctx context.Context) *Entry {
+	if e, ok := ctx.Value(ctxKey{}).(*Entry); ok {
+		return e
+	}
+	return defaultEntry
+}
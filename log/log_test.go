@@ -1,6 +1,9 @@
 package log
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"testing"
 )
@@ -61,6 +64,68 @@ func TestDisableDefaultLoggers(t *testing.T) {
 	Print("not printed")
 }
 
+func TestWith(t *testing.T) {
+	setMockLogger(`request served request_id=1234 route="GET /v1"`, false)
+	mustSetLevel("info")
+
+	e := With("request_id", "1234").With("route", "GET /v1")
+	e.Debug.Printf("not logged")
+	e.Info.Printf("request %s", "served")
+
+	globals().defaultLogger.(*mockLogger).Verify(t)
+}
+
+func TestJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(nil)
+
+	if err := SetFormat("json"); err != nil {
+		t.Fatalf("could not set format: %v", err)
+	}
+	defer SetFormat("text")
+	mustSetLevel("info")
+
+	With("request_id", "1234", "status", 200).Error.Printf("request %s", "failed")
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("malformed JSON output %q: %v", buf.String(), err)
+	}
+
+	want := map[string]any{
+		"level":      "error",
+		"msg":        "request failed",
+		"request_id": "1234",
+		"status":     float64(200),
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("unexpected value for %q: got=%v want=%v", k, got[k], v)
+		}
+	}
+	if _, ok := got["time"]; !ok {
+		t.Errorf("missing time")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if e := FromContext(context.Background()); e != defaultEntry {
+		t.Errorf("expected default entry")
+	}
+
+	want := With("request_id", "1234")
+	if got := FromContext(NewContext(context.Background(), want)); got != want {
+		t.Errorf("unexpected entry: got=%v want=%v", got, want)
+	}
+}
+
+func TestSetFormat_Invalid(t *testing.T) {
+	if err := SetFormat("xml"); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
 func mustSetLevel(level string) {
 	if err := SetLevel(level); err != nil {
 		panic(err)
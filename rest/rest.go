@@ -2,16 +2,47 @@
 package rest
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 
+	"github.com/adevinta/graph-intel-api/gremlin"
 	"github.com/adevinta/graph-intel-api/intel"
 	"github.com/adevinta/graph-intel-api/log"
 )
 
+// maxBatchSize is the maximum number of assets accepted by the batch
+// endpoints.
+const maxBatchSize = 1000
+
+// maxBatchBodySize is the maximum size in bytes of the body accepted by the
+// batch endpoints.
+const maxBatchBodySize = 4 << 20
+
+// requestIDHeader is the HTTP header used to propagate the request ID. If a
+// request does not provide it, a new ID is generated.
+const requestIDHeader = "X-Request-Id"
+
+// requestDuration is the Prometheus metric that tracks the duration of the
+// requests served by the REST API.
+var requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "graph_intel_api",
+	Subsystem: "http",
+	Name:      "request_duration_seconds",
+	Help:      "Duration of the requests served by the REST API.",
+	Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+}, []string{"method", "route", "status"})
+
 // restError represents a REST error. It is serialized an returned to the user.
 type restError struct {
 	status int `json:"-"`
@@ -26,13 +57,14 @@ func (r restError) Error() string {
 
 // write writes an error response.
 func (r restError) write(w http.ResponseWriter, req *http.Request) {
-	log.Error.Printf("graph-intel-api: rest: error serving request to %s: %v", req.RequestURI, r)
+	logger := log.FromContext(req.Context())
+	logger.Error.Printf("graph-intel-api: rest: error serving request to %s: %v", req.RequestURI, r)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(r.status)
 
 	if err := json.NewEncoder(w).Encode(r); err != nil {
-		log.Error.Printf("graph-intel-api: rest: error generating response for request to %s: %v", req.RequestURI, err)
+		logger.Error.Printf("graph-intel-api: rest: error generating response for request to %s: %v", req.RequestURI, err)
 	}
 }
 
@@ -44,6 +76,34 @@ var (
 		Msg:    "missing parameter",
 	}
 
+	// errInvalidParameter is an error returned by the REST API when a
+	// parameter has an invalid value.
+	errInvalidParameter = restError{
+		status: http.StatusBadRequest,
+		Msg:    "invalid parameter",
+	}
+
+	// errMalformedBody is an error returned by the REST API when the
+	// request body cannot be parsed.
+	errMalformedBody = restError{
+		status: http.StatusBadRequest,
+		Msg:    "malformed body",
+	}
+
+	// errBodyTooLarge is an error returned by the REST API when the
+	// request body exceeds the maximum size.
+	errBodyTooLarge = restError{
+		status: http.StatusRequestEntityTooLarge,
+		Msg:    "body too large",
+	}
+
+	// errInvalidBatchSize is an error returned by the REST API when a
+	// batch is empty or exceeds the maximum number of elements.
+	errInvalidBatchSize = restError{
+		status: http.StatusBadRequest,
+		Msg:    "invalid batch size",
+	}
+
 	// errNotFound is an error returned by the REST API when an entity is
 	// not found.
 	errNotFound = restError{
@@ -64,7 +124,40 @@ var (
 // this package.
 type IntelAPI interface {
 	// BlastRadius returns the blast radius of a given asset.
-	BlastRadius(typ, identifier string) (intel.BlastRadiusResult, error)
+	BlastRadius(ctx context.Context, typ, identifier string, opts intel.BlastRadiusOptions) (intel.BlastRadiusResult, error)
+
+	// BlastRadiusExplain returns the blast radius of a given asset along
+	// with the resources that contributed to the score.
+	BlastRadiusExplain(ctx context.Context, typ, identifier string, opts intel.BlastRadiusOptions) (intel.BlastRadiusExplanation, error)
+
+	// BlastRadiusTrend returns the blast radius of a given asset in
+	// every snapshot within a time range.
+	BlastRadiusTrend(ctx context.Context, typ, identifier string, from, to time.Time, model string) (intel.BlastRadiusTrend, error)
+
+	// BlastRadiusDiff returns the changes in the resources reachable
+	// from a given asset between two snapshots.
+	BlastRadiusDiff(ctx context.Context, typ, identifier string, from, to time.Time) (intel.BlastRadiusDiff, error)
+
+	// BlastRadiusBatch returns the blast radius of every provided asset.
+	BlastRadiusBatch(ctx context.Context, assets []intel.Asset) []intel.BlastRadiusBatchResult
+
+	// InternetExposure returns the internet exposure of a given asset.
+	InternetExposure(ctx context.Context, typ, identifier string) (intel.InternetExposureResult, error)
+
+	// RiskScore returns the composite risk score of a given asset.
+	RiskScore(ctx context.Context, typ, identifier string) (intel.RiskScoreResult, error)
+
+	// ResolveAsset returns every vertex that matches a given asset and
+	// the one chosen when resolving it.
+	ResolveAsset(ctx context.Context, typ, identifier string) (intel.AssetResolution, error)
+
+	// Asset returns the properties, snapshots and neighbours of a given
+	// vertex ID.
+	Asset(ctx context.Context, vid string, depth int) (intel.AssetDetail, error)
+
+	// SearchAssets returns a page of the assets that match a given
+	// query.
+	SearchAssets(ctx context.Context, q intel.AssetQuery) (intel.AssetPage, error)
 }
 
 // API exposes the Security Graph intel API as an HTTP REST endpoint.
@@ -81,43 +174,718 @@ func NewAPI(intelAPI IntelAPI) API {
 		intelAPI: intelAPI,
 		router:   router,
 	}
-	router.GET("/v1/blast-radius", api.BlastRadius)
+	api.handle(http.MethodGet, "/v1/blast-radius", api.BlastRadius)
+	api.handle(http.MethodGet, "/v1/blast-radius/explain", api.BlastRadiusExplain)
+	api.handle(http.MethodGet, "/v1/blast-radius/trend", api.BlastRadiusTrend)
+	api.handle(http.MethodGet, "/v1/blast-radius/diff", api.BlastRadiusDiff)
+
+	// httprouter handles ":batch" as a named parameter, so
+	// [API.BlastRadiusBatch] must check its value.
+	api.handle(http.MethodPost, "/v1/blast-radius:batch", api.BlastRadiusBatch)
+
+	api.handle(http.MethodGet, "/v1/internet-exposure", api.InternetExposure)
+	api.handle(http.MethodGet, "/v1/risk-score", api.RiskScore)
+	api.handle(http.MethodGet, "/v1/assets", api.SearchAssets)
+
+	// httprouter does not allow static and named parameters in the same
+	// path segment, and vertex IDs can contain slashes, so the asset
+	// endpoints share a catch-all route. Requests to /v1/assets/resolve
+	// with asset parameters resolve the asset. Otherwise, they return the
+	// vertex with ID "resolve".
+	resolve := api.instrument(http.MethodGet, "/v1/assets/resolve", api.ResolveAsset)
+	asset := api.instrument(http.MethodGet, "/v1/assets/*vertex_id", api.Asset)
+	api.router.GET("/v1/assets/*vertex_id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if ps.ByName("vertex_id") == "/resolve" && isResolveQuery(r) {
+			resolve(w, r, ps)
+			return
+		}
+		asset(w, r, ps)
+	})
+
+	// The v2 endpoints return structured metadata.
+	api.handle(http.MethodGet, "/v2/blast-radius", api.BlastRadiusV2)
+	api.handle(http.MethodGet, "/v2/blast-radius/explain", api.BlastRadiusExplainV2)
+	api.handle(http.MethodGet, "/v2/blast-radius/trend", api.BlastRadiusTrendV2)
 	return api
 }
 
+// handle registers a handler for the given method and route, instrumented
+// by [API.instrument].
+func (api API) handle(method, route string, h httprouter.Handle) {
+	api.router.Handle(method, route, api.instrument(method, route, h))
+}
+
+// instrument returns a handler that wraps h. The duration and status of the
+// requests are recorded in the exported metrics using the given method and
+// route. Every request carries a logger with the request ID, route and asset
+// in its context, which is also used to log the outcome of the request.
+func (api API) instrument(method, route string, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		start := time.Now()
+
+		reqID := r.Header.Get(requestIDHeader)
+		if reqID == "" {
+			reqID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, reqID)
+
+		logger := log.With("request_id", reqID, "route", method+" "+route)
+		params := r.URL.Query()
+		if typ := params.Get("asset_type"); typ != "" {
+			logger = logger.With("asset_type", typ)
+		}
+		if identifier := params.Get("asset_identifier"); identifier != "" {
+			logger = logger.With("asset_identifier", identifier)
+		}
+		r = r.WithContext(log.NewContext(r.Context(), logger))
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		h(sw, r, ps)
+
+		duration := time.Since(start)
+		status := strconv.Itoa(sw.status)
+		requestDuration.WithLabelValues(method, route, status).Observe(duration.Seconds())
+
+		logger.With(
+			"status", sw.status,
+			"duration_ms", duration.Milliseconds(),
+			"outcome", outcome(sw.status),
+		).Info.Printf("graph-intel-api: rest: request served")
+	}
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// outcome returns the outcome of a request given its status code.
+func outcome(status int) string {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return "server_error"
+	case status >= http.StatusBadRequest:
+		return "client_error"
+	default:
+		return "success"
+	}
+}
+
+// statusWriter is an [http.ResponseWriter] that records the status code of
+// the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code and writes it to the underlying
+// [http.ResponseWriter].
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
+
 // ServeHTTP serves the routes exposed by the REST API.
 func (api API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	api.router.ServeHTTP(w, r)
 }
 
-// BlastRadius handles the endpoint that returns the blast radius given a
+// blastRadiusRespV1 is the body of a v1 blast radius response. Its metadata
+// is the name of the blast radius model.
+type blastRadiusRespV1 struct {
+	// Score contains the blast radius score.
+	Score float64 `json:"score"`
+
+	// Metadata is the name of the blast radius model.
+	Metadata string `json:"metadata"`
+}
+
+// newBlastRadiusRespV1 returns the v1 representation of a blast radius.
+func newBlastRadiusRespV1(br intel.BlastRadiusResult) blastRadiusRespV1 {
+	return blastRadiusRespV1{
+		Score:    br.Score,
+		Metadata: br.Metadata.Model,
+	}
+}
+
+// blastRadiusExplainRespV1 is the body of a v1 blast radius explanation
+// response. Its metadata is the name of the blast radius model.
+type blastRadiusExplainRespV1 struct {
+	// Score contains the blast radius score.
+	Score float64 `json:"score"`
+
+	// Metadata is the name of the blast radius model.
+	Metadata string `json:"metadata"`
+
+	// Resources contains the resources that contributed to the score.
+	Resources []intel.ExplainedResource `json:"resources"`
+}
+
+// blastRadiusTrendRespV1 is the body of a v1 blast radius trend response. Its
+// metadata is the name of the blast radius model.
+type blastRadiusTrendRespV1 struct {
+	// Metadata is the name of the blast radius model.
+	Metadata string `json:"metadata"`
+
+	// Points contains the blast radius score of the asset in every
+	// snapshot that contains it.
+	Points []intel.TrendPoint `json:"points"`
+}
+
+// BlastRadius handles the v1 endpoint that returns the blast radius given a
 // specific asset.
 func (api API) BlastRadius(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	br, ok := api.blastRadius(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, r, newBlastRadiusRespV1(br))
+}
+
+// BlastRadiusV2 handles the v2 endpoint that returns the blast radius given
+// a specific asset along with structured metadata.
+func (api API) BlastRadiusV2(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	br, ok := api.blastRadius(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, r, br)
+}
+
+// blastRadius returns the blast radius of the asset provided as query
+// parameters. If the blast radius cannot be calculated, it writes an error
+// response and returns false.
+func (api API) blastRadius(w http.ResponseWriter, r *http.Request) (intel.BlastRadiusResult, bool) {
+	typ, identifier, ok := assetParams(w, r)
+	if !ok {
+		return intel.BlastRadiusResult{}, false
+	}
+
+	opts, ok := blastRadiusOptions(w, r)
+	if !ok {
+		return intel.BlastRadiusResult{}, false
+	}
+
+	br, err := api.intelAPI.BlastRadius(r.Context(), typ, identifier, opts)
+	if err != nil {
+		writeIntelError(w, r, err)
+		return intel.BlastRadiusResult{}, false
+	}
+
+	return br, true
+}
+
+// BlastRadiusExplain handles the v1 endpoint that returns the blast radius
+// given a specific asset along with the resources that contributed to the
+// score.
+func (api API) BlastRadiusExplain(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	explanation, ok := api.blastRadiusExplain(w, r)
+	if !ok {
+		return
+	}
+
+	resp := blastRadiusExplainRespV1{
+		Score:     explanation.Score,
+		Metadata:  explanation.Metadata.Model,
+		Resources: explanation.Resources,
+	}
+	writeJSON(w, r, resp)
+}
+
+// BlastRadiusExplainV2 handles the v2 endpoint that returns the blast radius
+// given a specific asset along with structured metadata and the resources
+// that contributed to the score.
+func (api API) BlastRadiusExplainV2(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	explanation, ok := api.blastRadiusExplain(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, r, explanation)
+}
+
+// blastRadiusExplain returns the blast radius explanation of the asset
+// provided as query parameters. If the explanation cannot be calculated, it
+// writes an error response and returns false.
+func (api API) blastRadiusExplain(w http.ResponseWriter, r *http.Request) (intel.BlastRadiusExplanation, bool) {
+	typ, identifier, ok := assetParams(w, r)
+	if !ok {
+		return intel.BlastRadiusExplanation{}, false
+	}
+
+	opts, ok := blastRadiusOptions(w, r)
+	if !ok {
+		return intel.BlastRadiusExplanation{}, false
+	}
+
+	explanation, err := api.intelAPI.BlastRadiusExplain(r.Context(), typ, identifier, opts)
+	if err != nil {
+		writeIntelError(w, r, err)
+		return intel.BlastRadiusExplanation{}, false
+	}
+
+	return explanation, true
+}
+
+// BlastRadiusTrend handles the v1 endpoint that returns the blast radius of
+// a specific asset in every snapshot within a time range.
+func (api API) BlastRadiusTrend(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	trend, ok := api.blastRadiusTrend(w, r)
+	if !ok {
+		return
+	}
+
+	resp := blastRadiusTrendRespV1{
+		Metadata: trend.Metadata.Model,
+		Points:   trend.Points,
+	}
+	writeJSON(w, r, resp)
+}
+
+// BlastRadiusTrendV2 handles the v2 endpoint that returns the blast radius
+// of a specific asset in every snapshot within a time range along with
+// structured metadata.
+func (api API) BlastRadiusTrendV2(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	trend, ok := api.blastRadiusTrend(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, r, trend)
+}
+
+// blastRadiusTrend returns the blast radius trend of the asset provided as
+// query parameters. If the trend cannot be calculated, it writes an error
+// response and returns false.
+func (api API) blastRadiusTrend(w http.ResponseWriter, r *http.Request) (intel.BlastRadiusTrend, bool) {
+	typ, identifier, ok := assetParams(w, r)
+	if !ok {
+		return intel.BlastRadiusTrend{}, false
+	}
+
+	from, to, ok := timeRangeParams(w, r)
+	if !ok {
+		return intel.BlastRadiusTrend{}, false
+	}
+
+	model := r.URL.Query().Get("model")
+
+	trend, err := api.intelAPI.BlastRadiusTrend(r.Context(), typ, identifier, from, to, model)
+	if err != nil {
+		writeIntelError(w, r, err)
+		return intel.BlastRadiusTrend{}, false
+	}
+
+	return trend, true
+}
+
+// BlastRadiusDiff handles the endpoint that returns the changes in the
+// resources reachable from a specific asset between two snapshots.
+func (api API) BlastRadiusDiff(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	typ, identifier, ok := assetParams(w, r)
+	if !ok {
+		return
+	}
+
+	from, to, ok := timeRangeParams(w, r)
+	if !ok {
+		return
+	}
+
+	diff, err := api.intelAPI.BlastRadiusDiff(r.Context(), typ, identifier, from, to)
+	if err != nil {
+		writeIntelError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, diff)
+}
+
+// blastRadiusBatchReq is the body of a batch blast radius request.
+type blastRadiusBatchReq struct {
+	// Assets contains the assets to process.
+	Assets []intel.Asset `json:"assets"`
+}
+
+// blastRadiusBatchResp is the body of a batch blast radius response.
+type blastRadiusBatchResp struct {
+	// Results contains one result per requested asset, in the same
+	// order of the request.
+	Results []blastRadiusBatchItem `json:"results"`
+}
+
+// blastRadiusBatchItem is the result of processing an asset in a batch
+// request. Only one of Result and Error is set.
+type blastRadiusBatchItem struct {
+	// AssetType is the type of the asset.
+	AssetType string `json:"asset_type"`
+
+	// AssetIdentifier is the identifier of the asset.
+	AssetIdentifier string `json:"asset_identifier"`
+
+	// Result is the blast radius of the asset.
+	Result *blastRadiusRespV1 `json:"result,omitempty"`
+
+	// Error is the error found while processing the asset.
+	Error *batchError `json:"error,omitempty"`
+}
+
+// batchError represents an error found while processing an element of a
+// batch request.
+type batchError struct {
+	// Code identifies the kind of error.
+	Code string `json:"code"`
+
+	// Msg is the error message.
+	Msg string `json:"msg"`
+}
+
+// Error codes of batch requests.
+const (
+	batchCodeMissingParameter     = "missing_parameter"
+	batchCodeNotFound             = "not_found"
+	batchCodeUnsupportedAssetType = "unsupported_asset_type"
+	batchCodeTimeout              = "timeout"
+	batchCodeCancelled            = "cancelled"
+	batchCodeInternal             = "internal_error"
+)
+
+// BlastRadiusBatch handles the endpoint that returns the blast radius of a
+// batch of assets. Errors found while processing individual assets are
+// reported per asset.
+func (api API) BlastRadiusBatch(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if ps.ByName("batch") != ":batch" {
+		errNotFound.write(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBodySize)
+
+	var req blastRadiusBatchReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			errBodyTooLarge.write(w, r)
+			return
+		}
+		errMalformedBody.write(w, r)
+		return
+	}
+
+	if len(req.Assets) == 0 || len(req.Assets) > maxBatchSize {
+		errInvalidBatchSize.write(w, r)
+		return
+	}
+
+	resp := blastRadiusBatchResp{
+		Results: make([]blastRadiusBatchItem, len(req.Assets)),
+	}
+
+	var (
+		assets []intel.Asset
+		idxs   []int
+	)
+	for i, asset := range req.Assets {
+		resp.Results[i] = blastRadiusBatchItem{
+			AssetType:       asset.Type,
+			AssetIdentifier: asset.Identifier,
+		}
+
+		if asset.Type == "" || asset.Identifier == "" {
+			resp.Results[i].Error = &batchError{
+				Code: batchCodeMissingParameter,
+				Msg:  "missing parameter",
+			}
+			continue
+		}
+
+		assets = append(assets, asset)
+		idxs = append(idxs, i)
+	}
+
+	for i, br := range api.intelAPI.BlastRadiusBatch(r.Context(), assets) {
+		item := &resp.Results[idxs[i]]
+		if br.Err != nil {
+			item.Error = newBatchError(r.Context(), br.Err)
+			continue
+		}
+		result := newBlastRadiusRespV1(br.Result)
+		item.Result = &result
+	}
+
+	writeJSON(w, r, resp)
+}
+
+// newBatchError returns the [batchError] corresponding to an error returned
+// by the intel API.
+func newBatchError(ctx context.Context, err error) *batchError {
+	switch {
+	case errors.Is(err, intel.ErrNotFound):
+		return &batchError{Code: batchCodeNotFound, Msg: "not found"}
+	case errors.Is(err, intel.ErrUnsupportedAssetType):
+		return &batchError{Code: batchCodeUnsupportedAssetType, Msg: "unsupported asset type"}
+	case errors.Is(err, gremlin.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return &batchError{Code: batchCodeTimeout, Msg: "timeout"}
+	case errors.Is(err, context.Canceled):
+		return &batchError{Code: batchCodeCancelled, Msg: "cancelled"}
+	default:
+		log.FromContext(ctx).Error.Printf("graph-intel-api: rest: error calculating Blast Radius: %v", err)
+		return &batchError{Code: batchCodeInternal, Msg: "internal server error"}
+	}
+}
+
+// InternetExposure handles the endpoint that returns the internet exposure
+// of a specific asset.
+func (api API) InternetExposure(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	typ, identifier, ok := assetParams(w, r)
+	if !ok {
+		return
+	}
+
+	exposure, err := api.intelAPI.InternetExposure(r.Context(), typ, identifier)
+	if err != nil {
+		writeIntelError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, exposure)
+}
+
+// RiskScore handles the endpoint that returns the composite risk score of a
+// specific asset.
+func (api API) RiskScore(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	typ, identifier, ok := assetParams(w, r)
+	if !ok {
+		return
+	}
+
+	risk, err := api.intelAPI.RiskScore(r.Context(), typ, identifier)
+	if err != nil {
+		writeIntelError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, risk)
+}
+
+// isResolveQuery reports whether r carries the parameters of an asset
+// resolution request.
+func isResolveQuery(r *http.Request) bool {
 	params := r.URL.Query()
-	typ := params.Get("asset_type")
-	if typ == "" {
-		errMissingParameter.write(w, r)
+	return params.Has("asset_type") || params.Has("asset_identifier")
+}
+
+// ResolveAsset handles the endpoint that returns how a specific asset maps
+// to the vertices of the Security Graph.
+func (api API) ResolveAsset(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	typ, identifier, ok := assetParams(w, r)
+	if !ok {
 		return
 	}
-	identifier := params.Get("asset_identifier")
-	if identifier == "" {
+
+	resolution, err := api.intelAPI.ResolveAsset(r.Context(), typ, identifier)
+	if err != nil {
+		writeIntelError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, resolution)
+}
+
+// Asset handles the endpoint that returns the properties, snapshots and
+// neighbours of a specific vertex. The depth of the neighbours is provided by
+// the optional query parameter "depth". It defaults to 1.
+func (api API) Asset(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	vid := strings.TrimPrefix(ps.ByName("vertex_id"), "/")
+	if vid == "" {
 		errMissingParameter.write(w, r)
 		return
 	}
 
-	br, err := api.intelAPI.BlastRadius(typ, identifier)
+	depth := 1
+	if d := r.URL.Query().Get("depth"); d != "" {
+		var err error
+		if depth, err = strconv.Atoi(d); err != nil {
+			errInvalidParameter.write(w, r)
+			return
+		}
+	}
+
+	detail, err := api.intelAPI.Asset(r.Context(), vid, depth)
 	if err != nil {
-		if errors.Is(err, intel.ErrNotFound) {
-			errNotFound.write(w, r)
+		writeIntelError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, detail)
+}
+
+// SearchAssets handles the endpoint that returns the assets that match the
+// provided filters. The following query parameters are supported, all of them
+// optional:
+//
+//   - label: vertex label. It can be repeated to match any of the labels.
+//   - property: property equality filter in the form "name:value".
+//   - property_prefix: property prefix filter in the form "name:prefix".
+//   - snapshot_id: vertex ID of the snapshot to search. Defaults to the
+//     latest snapshot.
+//   - cursor: cursor returned along with the previous page.
+//   - limit: maximum number of assets returned.
+//
+// Property filters can be repeated. Assets must match all of them.
+func (api API) SearchAssets(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	params := r.URL.Query()
+
+	q := intel.AssetQuery{
+		Labels:     params["label"],
+		SnapshotID: params.Get("snapshot_id"),
+		Cursor:     params.Get("cursor"),
+	}
+
+	for _, v := range params["property"] {
+		pf, ok := propertyFilter(v, false)
+		if !ok {
+			errInvalidParameter.write(w, r)
 			return
 		}
-		log.Error.Printf("graph-intel-api: rest: error calculating Blast Radius: %v", err)
-		errInternalServerError.write(w, r)
+		q.Properties = append(q.Properties, pf)
+	}
+
+	for _, v := range params["property_prefix"] {
+		pf, ok := propertyFilter(v, true)
+		if !ok {
+			errInvalidParameter.write(w, r)
+			return
+		}
+		q.Properties = append(q.Properties, pf)
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		var err error
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit <= 0 {
+			errInvalidParameter.write(w, r)
+			return
+		}
+	}
+
+	page, err := api.intelAPI.SearchAssets(r.Context(), q)
+	if err != nil {
+		writeIntelError(w, r, err)
 		return
 	}
 
+	writeJSON(w, r, page)
+}
+
+// propertyFilter parses a property filter in the form "name:value". It
+// returns false if the filter is malformed.
+func propertyFilter(s string, prefix bool) (intel.PropertyFilter, bool) {
+	name, value, ok := strings.Cut(s, ":")
+	if !ok || name == "" {
+		return intel.PropertyFilter{}, false
+	}
+	return intel.PropertyFilter{Name: name, Value: value, Prefix: prefix}, true
+}
+
+// assetParams returns the asset type and identifier provided as query
+// parameters. If any of them is missing, it writes an error response and
+// returns false.
+func assetParams(w http.ResponseWriter, r *http.Request) (typ, identifier string, ok bool) {
+	params := r.URL.Query()
+	typ = params.Get("asset_type")
+	if typ == "" {
+		errMissingParameter.write(w, r)
+		return "", "", false
+	}
+	identifier = params.Get("asset_identifier")
+	if identifier == "" {
+		errMissingParameter.write(w, r)
+		return "", "", false
+	}
+	return typ, identifier, true
+}
+
+// timeRangeParams returns the time range provided by the "from" and "to"
+// query parameters. If any of them is missing or invalid, it writes an error
+// response and returns false.
+func timeRangeParams(w http.ResponseWriter, r *http.Request) (from, to time.Time, ok bool) {
+	if from, ok = timeParam(w, r, "from"); !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	if to, ok = timeParam(w, r, "to"); !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	if to.Before(from) {
+		errInvalidParameter.write(w, r)
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// timeParam returns the RFC 3339 time provided by the named query parameter.
+// If it is missing or invalid, it writes an error response and returns false.
+func timeParam(w http.ResponseWriter, r *http.Request, name string) (t time.Time, ok bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		errMissingParameter.write(w, r)
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		errInvalidParameter.write(w, r)
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// blastRadiusOptions returns the blast radius options provided as query
+// parameters. If any of them is invalid, it writes an error response and
+// returns false.
+func blastRadiusOptions(w http.ResponseWriter, r *http.Request) (opts intel.BlastRadiusOptions, ok bool) {
+	params := r.URL.Query()
+	opts.Model = params.Get("model")
+	if at := params.Get("at"); at != "" {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			errInvalidParameter.write(w, r)
+			return intel.BlastRadiusOptions{}, false
+		}
+		opts.At = t
+	}
+	return opts, true
+}
+
+// writeIntelError writes the error response corresponding to an error
+// returned by the intel API.
+func writeIntelError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, intel.ErrNotFound):
+		errNotFound.write(w, r)
+	case errors.Is(err, intel.ErrUnsupportedAssetType),
+		errors.Is(err, intel.ErrUnknownModel),
+		errors.Is(err, intel.ErrInvalidDepth),
+		errors.Is(err, intel.ErrInvalidCursor),
+		errors.Is(err, intel.ErrInvalidLimit),
+		errors.Is(err, intel.ErrTooManySnapshots):
+		errInvalidParameter.write(w, r)
+	default:
+		log.FromContext(r.Context()).Error.Printf("graph-intel-api: rest: intel API error: %v", err)
+		errInternalServerError.write(w, r)
+	}
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(br); err != nil {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
 		errInternalServerError.write(w, r)
 		return
 	}
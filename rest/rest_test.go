@@ -1,21 +1,31 @@
 package rest
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/adevinta/graph-intel-api/gremlin"
 	"github.com/adevinta/graph-intel-api/intel"
+	"github.com/adevinta/graph-intel-api/log"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 type blastRadiusParams struct {
 	typ        string
 	identifier string
+	at         string
+	model      string
 }
 
 type blastRadiusResp struct {
@@ -26,14 +36,14 @@ type blastRadiusResp struct {
 func TestAPIBlastRadius(t *testing.T) {
 	tests := []struct {
 		name       string
-		mock       blastRadiusMock
+		mock       intelMock
 		params     blastRadiusParams
 		wantStatus int
 		wantResp   blastRadiusResp
 	}{
 		{
 			name: "ok",
-			mock: blastRadiusMock{
+			mock: intelMock{
 				typ:        "typ1",
 				identifier: "identifier1",
 				score:      123.123,
@@ -50,7 +60,7 @@ func TestAPIBlastRadius(t *testing.T) {
 		},
 		{
 			name: "not found",
-			mock: blastRadiusMock{
+			mock: intelMock{
 				typ:        "typ1",
 				identifier: "identifier1",
 				score:      123.123,
@@ -64,7 +74,7 @@ func TestAPIBlastRadius(t *testing.T) {
 		},
 		{
 			name: "internal server error",
-			mock: blastRadiusMock{
+			mock: intelMock{
 				forceError: true,
 			},
 			params: blastRadiusParams{
@@ -74,9 +84,109 @@ func TestAPIBlastRadius(t *testing.T) {
 			wantStatus: http.StatusInternalServerError,
 			wantResp:   blastRadiusResp{},
 		},
+		{
+			name: "unsupported asset type",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+				score:      123.123,
+				errs: map[string]error{
+					"vpc-0123456789abcdef0": fmt.Errorf("could not resolve asset: %w", intel.ErrUnsupportedAssetType),
+				},
+			},
+			params: blastRadiusParams{
+				typ:        "ResourceID",
+				identifier: "vpc-0123456789abcdef0",
+			},
+			wantStatus: http.StatusBadRequest,
+			wantResp:   blastRadiusResp{},
+		},
+		{
+			name: "at",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+				score:      123.123,
+				snapshot:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			params: blastRadiusParams{
+				typ:        "typ1",
+				identifier: "identifier1",
+				at:         "2023-01-02T00:00:00Z",
+			},
+			wantStatus: http.StatusOK,
+			wantResp: blastRadiusResp{
+				Score:    123.123,
+				Metadata: "mock",
+			},
+		},
+		{
+			name: "at before first snapshot",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+				score:      123.123,
+				snapshot:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			params: blastRadiusParams{
+				typ:        "typ1",
+				identifier: "identifier1",
+				at:         "2022-12-31T00:00:00Z",
+			},
+			wantStatus: http.StatusNotFound,
+			wantResp:   blastRadiusResp{},
+		},
+		{
+			name: "model",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+				score:      123.123,
+			},
+			params: blastRadiusParams{
+				typ:        "typ1",
+				identifier: "identifier1",
+				model:      "custom",
+			},
+			wantStatus: http.StatusOK,
+			wantResp: blastRadiusResp{
+				Score:    123.123,
+				Metadata: "custom",
+			},
+		},
+		{
+			name: "unknown model",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+				score:      123.123,
+			},
+			params: blastRadiusParams{
+				typ:        "typ1",
+				identifier: "identifier1",
+				model:      "unknown",
+			},
+			wantStatus: http.StatusBadRequest,
+			wantResp:   blastRadiusResp{},
+		},
+		{
+			name: "invalid parameter at",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+				score:      123.123,
+			},
+			params: blastRadiusParams{
+				typ:        "typ1",
+				identifier: "identifier1",
+				at:         "yesterday",
+			},
+			wantStatus: http.StatusBadRequest,
+			wantResp:   blastRadiusResp{},
+		},
 		{
 			name: "missing parameter asset_identifier",
-			mock: blastRadiusMock{
+			mock: intelMock{
 				typ:        "typ1",
 				identifier: "identifier1",
 				score:      123.123,
@@ -89,7 +199,7 @@ func TestAPIBlastRadius(t *testing.T) {
 		},
 		{
 			name: "missing parameter asset_type",
-			mock: blastRadiusMock{
+			mock: intelMock{
 				typ:        "typ1",
 				identifier: "identifier1",
 				score:      123.123,
@@ -109,6 +219,12 @@ func TestAPIBlastRadius(t *testing.T) {
 			defer ts.Close()
 
 			url := fmt.Sprintf("%s/v1/blast-radius?asset_type=%v&asset_identifier=%v", ts.URL, tt.params.typ, tt.params.identifier)
+			if tt.params.at != "" {
+				url += "&at=" + tt.params.at
+			}
+			if tt.params.model != "" {
+				url += "&model=" + tt.params.model
+			}
 			res, err := http.Get(url)
 			if err != nil {
 				t.Fatalf("request error: %v", err)
@@ -135,25 +251,1545 @@ func TestAPIBlastRadius(t *testing.T) {
 	}
 }
 
-type blastRadiusMock struct {
-	typ        string
-	identifier string
-	score      float64
-	forceError bool
+func TestAPIBlastRadiusExplain(t *testing.T) {
+	resources := []intel.ExplainedResource{
+		{
+			ID:     "i0",
+			Label:  "ec2:instance",
+			Steps:  11,
+			Weight: 1,
+			Score:  1.0 / 11.0,
+			Path: []intel.PathVertex{
+				{ID: "ni0", Label: "ec2:network-interface"},
+				{ID: "sg0", Label: "ec2:security-group"},
+				{ID: "i0", Label: "ec2:instance"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		mock       intelMock
+		params     blastRadiusParams
+		wantStatus int
+		wantResp   blastRadiusExplainRespV1
+	}{
+		{
+			name: "ok",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+				score:      123.123,
+				resources:  resources,
+			},
+			params: blastRadiusParams{
+				typ:        "typ1",
+				identifier: "identifier1",
+			},
+			wantStatus: http.StatusOK,
+			wantResp: blastRadiusExplainRespV1{
+				Score:     123.123,
+				Metadata:  "mock",
+				Resources: resources,
+			},
+		},
+		{
+			name: "not found",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+				score:      123.123,
+			},
+			params: blastRadiusParams{
+				typ:        "unknown_typ",
+				identifier: "unknown_identifier",
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "internal server error",
+			mock: intelMock{
+				forceError: true,
+			},
+			params: blastRadiusParams{
+				typ:        "typ1",
+				identifier: "identifier1",
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "missing parameter asset_type",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+				score:      123.123,
+			},
+			params: blastRadiusParams{
+				identifier: "identifier1",
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restAPI := NewAPI(tt.mock)
+			ts := httptest.NewServer(restAPI)
+			defer ts.Close()
+
+			url := fmt.Sprintf("%s/v1/blast-radius/explain?asset_type=%v&asset_identifier=%v", ts.URL, tt.params.typ, tt.params.identifier)
+			res, err := http.Get(url)
+			if err != nil {
+				t.Fatalf("request error: %v", err)
+			}
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("unexpected status: got=%v want=%v", res.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got blastRadiusExplainRespV1
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("malformed body: %v", err)
+			}
+			res.Body.Close()
+
+			if diff := cmp.Diff(tt.wantResp, got); diff != "" {
+				t.Errorf("responses mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
 }
 
-func (mock blastRadiusMock) BlastRadius(typ, identifier string) (intel.BlastRadiusResult, error) {
-	if mock.forceError {
-		return intel.BlastRadiusResult{}, errors.New("forced error")
+func TestAPIBlastRadiusV2(t *testing.T) {
+	resources := []intel.ExplainedResource{
+		{
+			ID:     "i0",
+			Label:  "ec2:instance",
+			Steps:  11,
+			Weight: 1,
+			Score:  1.0 / 11.0,
+			Path: []intel.PathVertex{
+				{ID: "ni0", Label: "ec2:network-interface"},
+				{ID: "sg0", Label: "ec2:security-group"},
+				{ID: "i0", Label: "ec2:instance"},
+			},
+		},
 	}
 
-	if typ == mock.typ && identifier == mock.identifier {
-		result := intel.BlastRadiusResult{
-			Score:    mock.score,
-			Metadata: "mock",
-		}
-		return result, nil
+	mock := intelMock{
+		typ:        "typ1",
+		identifier: "identifier1",
+		score:      123.123,
+		resources:  resources,
+		snapshot:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
 	}
 
-	return intel.BlastRadiusResult{}, intel.ErrNotFound
+	tests := []struct {
+		name       string
+		path       string
+		params     blastRadiusParams
+		wantStatus int
+		wantResp   intel.BlastRadiusExplanation
+	}{
+		{
+			name: "blast radius",
+			path: "/v2/blast-radius",
+			params: blastRadiusParams{
+				typ:        "typ1",
+				identifier: "identifier1",
+			},
+			wantStatus: http.StatusOK,
+			wantResp: intel.BlastRadiusExplanation{
+				Score:    123.123,
+				Metadata: mock.metadata("mock"),
+			},
+		},
+		{
+			name: "blast radius model",
+			path: "/v2/blast-radius",
+			params: blastRadiusParams{
+				typ:        "typ1",
+				identifier: "identifier1",
+				model:      "custom",
+			},
+			wantStatus: http.StatusOK,
+			wantResp: intel.BlastRadiusExplanation{
+				Score:    123.123,
+				Metadata: mock.metadata("custom"),
+			},
+		},
+		{
+			name: "blast radius not found",
+			path: "/v2/blast-radius",
+			params: blastRadiusParams{
+				typ:        "unknown_typ",
+				identifier: "unknown_identifier",
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "explain",
+			path: "/v2/blast-radius/explain",
+			params: blastRadiusParams{
+				typ:        "typ1",
+				identifier: "identifier1",
+			},
+			wantStatus: http.StatusOK,
+			wantResp: intel.BlastRadiusExplanation{
+				Score:     123.123,
+				Metadata:  mock.metadata("mock"),
+				Resources: resources,
+			},
+		},
+		{
+			name: "explain missing parameter asset_type",
+			path: "/v2/blast-radius/explain",
+			params: blastRadiusParams{
+				identifier: "identifier1",
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restAPI := NewAPI(mock)
+			ts := httptest.NewServer(restAPI)
+			defer ts.Close()
+
+			url := fmt.Sprintf("%s%s?asset_type=%v&asset_identifier=%v", ts.URL, tt.path, tt.params.typ, tt.params.identifier)
+			if tt.params.model != "" {
+				url += "&model=" + tt.params.model
+			}
+			res, err := http.Get(url)
+			if err != nil {
+				t.Fatalf("request error: %v", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("unexpected status: got=%v want=%v", res.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got intel.BlastRadiusExplanation
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("malformed body: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.wantResp, got); diff != "" {
+				t.Errorf("responses mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+var trendPoints = []intel.TrendPoint{
+	{
+		Timestamp:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		SnapshotID: "s0",
+		Score:      1,
+		VertexID:   "vid0",
+		Resolution: intel.ResolutionDirect,
+	},
+	{
+		Timestamp:  time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		SnapshotID: "s1",
+		Score:      2,
+		VertexID:   "vid1",
+		Resolution: intel.ResolutionDirect,
+	},
+}
+
+func TestAPIBlastRadiusTrend(t *testing.T) {
+	points := trendPoints
+
+	mock := intelMock{
+		typ:        "typ1",
+		identifier: "identifier1",
+		trend:      points,
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantResp   blastRadiusTrendRespV1
+	}{
+		{
+			name:       "ok",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2023-01-01T00:00:00Z&to=2023-01-31T00:00:00Z",
+			wantStatus: http.StatusOK,
+			wantResp: blastRadiusTrendRespV1{
+				Metadata: "mock",
+				Points:   points,
+			},
+		},
+		{
+			name:       "partial range",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2023-01-02T00:00:00Z&to=2023-01-31T00:00:00Z",
+			wantStatus: http.StatusOK,
+			wantResp: blastRadiusTrendRespV1{
+				Metadata: "mock",
+				Points:   points[1:],
+			},
+		},
+		{
+			name:       "model",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2023-01-01T00:00:00Z&to=2023-01-31T00:00:00Z&model=custom",
+			wantStatus: http.StatusOK,
+			wantResp: blastRadiusTrendRespV1{
+				Metadata: "custom",
+				Points:   points,
+			},
+		},
+		{
+			name:       "unknown model",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2023-01-01T00:00:00Z&to=2023-01-31T00:00:00Z&model=unknown",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not found",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2022-01-01T00:00:00Z&to=2022-01-31T00:00:00Z",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "missing parameter to",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2023-01-01T00:00:00Z",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid parameter from",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=yesterday&to=2023-01-31T00:00:00Z",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid time range",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2023-01-31T00:00:00Z&to=2023-01-01T00:00:00Z",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "too many snapshots",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2020-01-01T00:00:00Z&to=2023-01-31T00:00:00Z",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	restAPI := NewAPI(mock)
+	ts := httptest.NewServer(restAPI)
+	defer ts.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := http.Get(ts.URL + "/v1/blast-radius/trend?" + tt.query)
+			if err != nil {
+				t.Fatalf("request error: %v", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("unexpected status: got=%v want=%v", res.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got blastRadiusTrendRespV1
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("malformed body: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.wantResp, got); diff != "" {
+				t.Errorf("responses mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPIBlastRadiusTrendV2(t *testing.T) {
+	mock := intelMock{
+		typ:        "typ1",
+		identifier: "identifier1",
+		trend:      trendPoints,
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantResp   intel.BlastRadiusTrend
+	}{
+		{
+			name:       "ok",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2023-01-01T00:00:00Z&to=2023-01-31T00:00:00Z",
+			wantStatus: http.StatusOK,
+			wantResp: intel.BlastRadiusTrend{
+				Metadata: mock.trendMetadata("mock", trendPoints),
+				Points:   trendPoints,
+			},
+		},
+		{
+			name:       "model",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2023-01-02T00:00:00Z&to=2023-01-31T00:00:00Z&model=custom",
+			wantStatus: http.StatusOK,
+			wantResp: intel.BlastRadiusTrend{
+				Metadata: mock.trendMetadata("custom", trendPoints[1:]),
+				Points:   trendPoints[1:],
+			},
+		},
+		{
+			name:       "unknown model",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2023-01-01T00:00:00Z&to=2023-01-31T00:00:00Z&model=unknown",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not found",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2022-01-01T00:00:00Z&to=2022-01-31T00:00:00Z",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	restAPI := NewAPI(mock)
+	ts := httptest.NewServer(restAPI)
+	defer ts.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := http.Get(ts.URL + "/v2/blast-radius/trend?" + tt.query)
+			if err != nil {
+				t.Fatalf("request error: %v", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("unexpected status: got=%v want=%v", res.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got intel.BlastRadiusTrend
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("malformed body: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.wantResp, got); diff != "" {
+				t.Errorf("responses mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPIBlastRadiusDiff(t *testing.T) {
+	diff := intel.BlastRadiusDiff{
+		From: intel.Snapshot{ID: "s0", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		To:   intel.Snapshot{ID: "s1", Timestamp: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)},
+		Added: []intel.DiffResource{
+			{
+				Key:   "0.0.0.0/0",
+				ID:    "r1",
+				Label: "ip_range",
+				Rules: []intel.PathVertex{{ID: "er1", Label: "egress_rule"}},
+			},
+		},
+		Removed:   []intel.DiffResource{},
+		Unchanged: []intel.DiffResource{},
+	}
+
+	mock := intelMock{
+		typ:        "typ1",
+		identifier: "identifier1",
+		diff:       diff,
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantResp   intel.BlastRadiusDiff
+	}{
+		{
+			name:       "ok",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2023-01-01T00:00:00Z&to=2023-01-02T00:00:00Z",
+			wantStatus: http.StatusOK,
+			wantResp:   diff,
+		},
+		{
+			name:       "not found",
+			query:      "asset_type=typ1&asset_identifier=unknown&from=2023-01-01T00:00:00Z&to=2023-01-02T00:00:00Z",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "missing parameter from",
+			query:      "asset_type=typ1&asset_identifier=identifier1&to=2023-01-02T00:00:00Z",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid parameter to",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2023-01-01T00:00:00Z&to=tomorrow",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "to before from",
+			query:      "asset_type=typ1&asset_identifier=identifier1&from=2023-01-02T00:00:00Z&to=2023-01-01T00:00:00Z",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	restAPI := NewAPI(mock)
+	ts := httptest.NewServer(restAPI)
+	defer ts.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := http.Get(ts.URL + "/v1/blast-radius/diff?" + tt.query)
+			if err != nil {
+				t.Fatalf("request error: %v", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("unexpected status: got=%v want=%v", res.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got intel.BlastRadiusDiff
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("malformed body: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.wantResp, got); diff != "" {
+				t.Errorf("responses mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPIBlastRadiusBatch(t *testing.T) {
+	mock := intelMock{
+		typ:        "typ1",
+		identifier: "identifier1",
+		score:      123.123,
+		errs: map[string]error{
+			"timeout":     fmt.Errorf("query error: %w", gremlin.ErrTimeout),
+			"unsupported": fmt.Errorf("could not resolve asset: %w", intel.ErrUnsupportedAssetType),
+			"cancelled":   fmt.Errorf("query error: %w", context.Canceled),
+			"internal":    errors.New("forced error"),
+		},
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantResp   blastRadiusBatchResp
+	}{
+		{
+			name: "ok",
+			path: "/v1/blast-radius:batch",
+			body: `{"assets": [
+				{"asset_type": "typ1", "asset_identifier": "identifier1"},
+				{"asset_type": "typ1", "asset_identifier": "unknown"},
+				{"asset_type": "typ1", "asset_identifier": "timeout"},
+				{"asset_type": "typ1", "asset_identifier": "unsupported"},
+				{"asset_type": "typ1", "asset_identifier": "cancelled"},
+				{"asset_type": "typ1", "asset_identifier": "internal"},
+				{"asset_type": "typ1"}
+			]}`,
+			wantStatus: http.StatusOK,
+			wantResp: blastRadiusBatchResp{
+				Results: []blastRadiusBatchItem{
+					{
+						AssetType:       "typ1",
+						AssetIdentifier: "identifier1",
+						Result: &blastRadiusRespV1{
+							Score:    123.123,
+							Metadata: "mock",
+						},
+					},
+					{
+						AssetType:       "typ1",
+						AssetIdentifier: "unknown",
+						Error:           &batchError{Code: batchCodeNotFound, Msg: "not found"},
+					},
+					{
+						AssetType:       "typ1",
+						AssetIdentifier: "timeout",
+						Error:           &batchError{Code: batchCodeTimeout, Msg: "timeout"},
+					},
+					{
+						AssetType:       "typ1",
+						AssetIdentifier: "unsupported",
+						Error:           &batchError{Code: batchCodeUnsupportedAssetType, Msg: "unsupported asset type"},
+					},
+					{
+						AssetType:       "typ1",
+						AssetIdentifier: "cancelled",
+						Error:           &batchError{Code: batchCodeCancelled, Msg: "cancelled"},
+					},
+					{
+						AssetType:       "typ1",
+						AssetIdentifier: "internal",
+						Error:           &batchError{Code: batchCodeInternal, Msg: "internal server error"},
+					},
+					{
+						AssetType: "typ1",
+						Error:     &batchError{Code: batchCodeMissingParameter, Msg: "missing parameter"},
+					},
+				},
+			},
+		},
+		{
+			name:       "malformed body",
+			path:       "/v1/blast-radius:batch",
+			body:       `{"assets": [`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "body too large",
+			path:       "/v1/blast-radius:batch",
+			body:       `{"assets": [` + strings.Repeat(" ", maxBatchBodySize) + `]}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "empty batch",
+			path:       "/v1/blast-radius:batch",
+			body:       `{"assets": []}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown method",
+			path:       "/v1/blast-radius:unknown",
+			body:       `{"assets": [{"asset_type": "typ1", "asset_identifier": "identifier1"}]}`,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restAPI := NewAPI(mock)
+			ts := httptest.NewServer(restAPI)
+			defer ts.Close()
+
+			res, err := http.Post(ts.URL+tt.path, "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("request error: %v", err)
+			}
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("unexpected status: got=%v want=%v", res.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got blastRadiusBatchResp
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("malformed body: %v", err)
+			}
+			res.Body.Close()
+
+			if diff := cmp.Diff(tt.wantResp, got); diff != "" {
+				t.Errorf("responses mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPIInternetExposure(t *testing.T) {
+	exposure := intel.InternetExposureResult{
+		Exposed: true,
+		Public:  true,
+		Rules: []intel.ExposureRule{
+			{
+				SecurityGroupID: "sg0",
+				IngressRuleID:   "ir0",
+				Protocol:        "tcp",
+				FromPort:        22,
+				ToPort:          22,
+				CIDR:            "0.0.0.0/0",
+			},
+		},
+		LoadBalancers: []intel.ExposedLoadBalancer{},
+	}
+
+	tests := []struct {
+		name       string
+		mock       intelMock
+		params     blastRadiusParams
+		wantStatus int
+		wantResp   intel.InternetExposureResult
+	}{
+		{
+			name: "ok",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+				exposure:   exposure,
+			},
+			params: blastRadiusParams{
+				typ:        "typ1",
+				identifier: "identifier1",
+			},
+			wantStatus: http.StatusOK,
+			wantResp:   exposure,
+		},
+		{
+			name: "not found",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+			},
+			params: blastRadiusParams{
+				typ:        "unknown_typ",
+				identifier: "unknown_identifier",
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "internal server error",
+			mock: intelMock{
+				forceError: true,
+			},
+			params: blastRadiusParams{
+				typ:        "typ1",
+				identifier: "identifier1",
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "missing parameter asset_identifier",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+			},
+			params: blastRadiusParams{
+				typ: "typ1",
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restAPI := NewAPI(tt.mock)
+			ts := httptest.NewServer(restAPI)
+			defer ts.Close()
+
+			url := fmt.Sprintf("%s/v1/internet-exposure?asset_type=%v&asset_identifier=%v", ts.URL, tt.params.typ, tt.params.identifier)
+			res, err := http.Get(url)
+			if err != nil {
+				t.Fatalf("request error: %v", err)
+			}
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("unexpected status: got=%v want=%v", res.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got intel.InternetExposureResult
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("malformed body: %v", err)
+			}
+			res.Body.Close()
+
+			if diff := cmp.Diff(tt.wantResp, got); diff != "" {
+				t.Errorf("responses mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPIRiskScore(t *testing.T) {
+	risk := intel.RiskScoreResult{
+		Score: 0.875,
+		Components: []intel.RiskComponent{
+			{Name: "net", Score: 3, NormalizedScore: 0.75, Weight: 1, Available: true},
+			{Name: "internet_exposure", Score: 1, NormalizedScore: 1, Weight: 1, Available: true},
+			{Name: "iam", Score: 0, NormalizedScore: 0, Weight: 1, Available: false},
+		},
+		Snapshot: intel.Snapshot{ID: "s0", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		name       string
+		mock       intelMock
+		params     blastRadiusParams
+		wantStatus int
+		wantResp   intel.RiskScoreResult
+	}{
+		{
+			name: "ok",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+				risk:       risk,
+			},
+			params: blastRadiusParams{
+				typ:        "typ1",
+				identifier: "identifier1",
+			},
+			wantStatus: http.StatusOK,
+			wantResp:   risk,
+		},
+		{
+			name: "not found",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+			},
+			params: blastRadiusParams{
+				typ:        "unknown_typ",
+				identifier: "unknown_identifier",
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "internal server error",
+			mock: intelMock{
+				forceError: true,
+			},
+			params: blastRadiusParams{
+				typ:        "typ1",
+				identifier: "identifier1",
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "missing parameter asset_type",
+			mock: intelMock{
+				typ:        "typ1",
+				identifier: "identifier1",
+			},
+			params: blastRadiusParams{
+				identifier: "identifier1",
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restAPI := NewAPI(tt.mock)
+			ts := httptest.NewServer(restAPI)
+			defer ts.Close()
+
+			url := fmt.Sprintf("%s/v1/risk-score?asset_type=%v&asset_identifier=%v", ts.URL, tt.params.typ, tt.params.identifier)
+			res, err := http.Get(url)
+			if err != nil {
+				t.Fatalf("request error: %v", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("unexpected status: got=%v want=%v", res.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got intel.RiskScoreResult
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("malformed body: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.wantResp, got); diff != "" {
+				t.Errorf("responses mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPIResolveAsset(t *testing.T) {
+	resolution := intel.AssetResolution{
+		VertexID:   "ni1",
+		Resolution: intel.ResolutionDNS,
+		Candidates: []intel.ResolutionCandidate{
+			{
+				ID:          "ni1",
+				Label:       "ec2:network-interface",
+				Property:    "public_ip",
+				Snapshot:    intel.Snapshot{ID: "s1", Timestamp: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)},
+				DNSFallback: true,
+				IP:          "1.2.3.4",
+				Chosen:      true,
+			},
+			{
+				ID:          "ni0",
+				Label:       "ec2:network-interface",
+				Property:    "public_ip",
+				Snapshot:    intel.Snapshot{ID: "s0", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+				DNSFallback: true,
+				IP:          "1.2.3.4",
+				Chosen:      false,
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		mock       intelMock
+		params     blastRadiusParams
+		wantStatus int
+		wantResp   intel.AssetResolution
+	}{
+		{
+			name: "ok",
+			mock: intelMock{
+				typ:        "Hostname",
+				identifier: "example.com",
+				resolution: resolution,
+			},
+			params: blastRadiusParams{
+				typ:        "Hostname",
+				identifier: "example.com",
+			},
+			wantStatus: http.StatusOK,
+			wantResp:   resolution,
+		},
+		{
+			name: "not found",
+			mock: intelMock{
+				typ:        "Hostname",
+				identifier: "example.com",
+			},
+			params: blastRadiusParams{
+				typ:        "Hostname",
+				identifier: "unknown.example.com",
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "internal server error",
+			mock: intelMock{
+				forceError: true,
+			},
+			params: blastRadiusParams{
+				typ:        "Hostname",
+				identifier: "example.com",
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "missing parameter asset_identifier",
+			mock: intelMock{
+				typ:        "Hostname",
+				identifier: "example.com",
+			},
+			params: blastRadiusParams{
+				typ: "Hostname",
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restAPI := NewAPI(tt.mock)
+			ts := httptest.NewServer(restAPI)
+			defer ts.Close()
+
+			url := fmt.Sprintf("%s/v1/assets/resolve?asset_type=%v&asset_identifier=%v", ts.URL, tt.params.typ, tt.params.identifier)
+			res, err := http.Get(url)
+			if err != nil {
+				t.Fatalf("request error: %v", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("unexpected status: got=%v want=%v", res.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got intel.AssetResolution
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("malformed body: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.wantResp, got); diff != "" {
+				t.Errorf("responses mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPIAsset(t *testing.T) {
+	asset := intel.AssetDetail{
+		ID:    "arn:aws:ec2:eu-west-1:123456789012:network-interface/eni-0123456789abcdef0",
+		Label: "ec2:network-interface",
+		Properties: map[string]any{
+			"public_ip": "1.2.3.4",
+			"status":    "in-use",
+		},
+		Snapshots: []intel.Snapshot{
+			{ID: "s0", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		Neighbors: map[string][]intel.Neighbor{
+			"resource_link": {
+				{
+					ID:        "sg0",
+					Label:     "ec2:security-group",
+					Direction: intel.DirectionOut,
+					Neighbors: map[string][]intel.Neighbor{
+						"egress_rule": {
+							{ID: "er0", Label: "egress_rule", Direction: intel.DirectionOut},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		mock       intelMock
+		path       string
+		wantStatus int
+		wantResp   intel.AssetDetail
+	}{
+		{
+			name:       "ok",
+			mock:       intelMock{asset: asset},
+			path:       "/v1/assets/" + asset.ID,
+			wantStatus: http.StatusOK,
+			wantResp:   asset,
+		},
+		{
+			name:       "depth",
+			mock:       intelMock{asset: asset},
+			path:       "/v1/assets/" + asset.ID + "?depth=2",
+			wantStatus: http.StatusOK,
+			wantResp:   asset,
+		},
+		{
+			name:       "invalid parameter depth",
+			mock:       intelMock{asset: asset},
+			path:       "/v1/assets/" + asset.ID + "?depth=all",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "depth out of range",
+			mock:       intelMock{asset: asset},
+			path:       "/v1/assets/" + asset.ID + "?depth=3",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not found",
+			mock:       intelMock{asset: asset},
+			path:       "/v1/assets/unknown",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "vertex ID resolve",
+			mock:       intelMock{asset: intel.AssetDetail{ID: "resolve", Label: "ec2:instance"}},
+			path:       "/v1/assets/resolve",
+			wantStatus: http.StatusOK,
+			wantResp:   intel.AssetDetail{ID: "resolve", Label: "ec2:instance"},
+		},
+		{
+			name:       "vertex ID ending in resolve",
+			mock:       intelMock{asset: intel.AssetDetail{ID: "i0/resolve", Label: "ec2:instance"}},
+			path:       "/v1/assets/i0/resolve",
+			wantStatus: http.StatusOK,
+			wantResp:   intel.AssetDetail{ID: "i0/resolve", Label: "ec2:instance"},
+		},
+		{
+			name:       "internal server error",
+			mock:       intelMock{forceError: true},
+			path:       "/v1/assets/" + asset.ID,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "missing vertex ID",
+			mock:       intelMock{asset: asset},
+			path:       "/v1/assets/",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restAPI := NewAPI(tt.mock)
+			ts := httptest.NewServer(restAPI)
+			defer ts.Close()
+
+			res, err := http.Get(ts.URL + tt.path)
+			if err != nil {
+				t.Fatalf("request error: %v", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("unexpected status: got=%v want=%v", res.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got intel.AssetDetail
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("malformed body: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.wantResp, got); diff != "" {
+				t.Errorf("responses mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPISearchAssets(t *testing.T) {
+	page := intel.AssetPage{
+		Assets: []intel.AssetSummary{
+			{
+				ID:    "i0",
+				Label: "ec2:instance",
+				Properties: map[string]any{
+					"public_dns_name": "ec2-1-2-3-4.eu-west-1.compute.amazonaws.com",
+					"state":           "running",
+				},
+			},
+		},
+		Snapshot:   intel.Snapshot{ID: "s1", Timestamp: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)},
+		NextCursor: "cursor2",
+	}
+
+	tests := []struct {
+		name       string
+		mock       intelMock
+		query      string
+		wantStatus int
+		wantResp   intel.AssetPage
+	}{
+		{
+			name:       "no filters",
+			mock:       intelMock{page: page},
+			query:      "",
+			wantStatus: http.StatusOK,
+			wantResp:   page,
+		},
+		{
+			name: "filters",
+			mock: intelMock{
+				query: intel.AssetQuery{
+					Labels: []string{"ec2:instance", "rds:db"},
+					Properties: []intel.PropertyFilter{
+						{Name: "state", Value: "running"},
+						{Name: "public_dns_name", Value: "ec2-", Prefix: true},
+					},
+					SnapshotID: "s1",
+					Cursor:     "cursor1",
+					Limit:      1,
+				},
+				page: page,
+			},
+			query:      "label=ec2:instance&label=rds:db&property=state:running&property_prefix=public_dns_name:ec2-&snapshot_id=s1&cursor=cursor1&limit=1",
+			wantStatus: http.StatusOK,
+			wantResp:   page,
+		},
+		{
+			name:       "invalid parameter property",
+			mock:       intelMock{page: page},
+			query:      "property=state",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid parameter limit",
+			mock:       intelMock{page: page},
+			query:      "limit=0",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "invalid cursor",
+			mock: intelMock{
+				errs: map[string]error{"invalid": fmt.Errorf("%w: bad encoding", intel.ErrInvalidCursor)},
+			},
+			query:      "cursor=invalid",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "snapshot not found",
+			mock:       intelMock{page: page},
+			query:      "snapshot_id=unknown",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "internal server error",
+			mock:       intelMock{forceError: true},
+			query:      "",
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restAPI := NewAPI(tt.mock)
+			ts := httptest.NewServer(restAPI)
+			defer ts.Close()
+
+			res, err := http.Get(ts.URL + "/v1/assets?" + tt.query)
+			if err != nil {
+				t.Fatalf("request error: %v", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("unexpected status: got=%v want=%v", res.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got intel.AssetPage
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("malformed body: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.wantResp, got); diff != "" {
+				t.Errorf("responses mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAPIMetrics(t *testing.T) {
+	mock := intelMock{
+		typ:        "typ1",
+		identifier: "identifier1",
+		score:      123.123,
+	}
+	restAPI := NewAPI(mock)
+	ts := httptest.NewServer(restAPI)
+	defer ts.Close()
+
+	requestDuration.Reset()
+
+	for _, identifier := range []string{"identifier1", "unknown"} {
+		url := fmt.Sprintf("%s/v1/blast-radius?asset_type=typ1&asset_identifier=%v", ts.URL, identifier)
+		res, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("request error: %v", err)
+		}
+		res.Body.Close()
+	}
+
+	// One series per status code.
+	if n := testutil.CollectAndCount(requestDuration); n != 2 {
+		t.Errorf("unexpected number of series: got=%v want=2", n)
+	}
+}
+
+func TestAPIRequestLogging(t *testing.T) {
+	mock := intelMock{
+		typ:        "typ1",
+		identifier: "identifier1",
+		score:      123.123,
+	}
+	restAPI := NewAPI(mock)
+	ts := httptest.NewServer(restAPI)
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		name      string
+		requestID string
+	}{
+		{
+			name:      "provided request ID",
+			requestID: "1234",
+		},
+		{
+			name:      "generated request ID",
+			requestID: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/blast-radius?asset_type=typ1&asset_identifier=identifier1", nil)
+			if err != nil {
+				t.Fatalf("could not create request: %v", err)
+			}
+			if tt.requestID != "" {
+				req.Header.Set("X-Request-Id", tt.requestID)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request error: %v", err)
+			}
+			res.Body.Close()
+
+			got := res.Header.Get("X-Request-Id")
+			if got == "" {
+				t.Fatalf("missing request ID")
+			}
+			if tt.requestID != "" && got != tt.requestID {
+				t.Errorf("unexpected request ID: got=%v want=%v", got, tt.requestID)
+			}
+
+			for _, want := range []string{
+				"request_id=" + got,
+				`route="GET /v1/blast-radius"`,
+				"asset_type=typ1",
+				"asset_identifier=identifier1",
+				"status=200",
+				"outcome=success",
+			} {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("missing %q in log output: %q", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+type intelMock struct {
+	typ        string
+	identifier string
+	score      float64
+	resources  []intel.ExplainedResource
+	exposure   intel.InternetExposureResult
+	errs       map[string]error
+	snapshot   time.Time
+	trend      []intel.TrendPoint
+	diff       intel.BlastRadiusDiff
+	risk       intel.RiskScoreResult
+	resolution intel.AssetResolution
+	asset      intel.AssetDetail
+	query      intel.AssetQuery
+	page       intel.AssetPage
+	forceError bool
+}
+
+func (mock intelMock) BlastRadius(ctx context.Context, typ, identifier string, opts intel.BlastRadiusOptions) (intel.BlastRadiusResult, error) {
+	if mock.forceError {
+		return intel.BlastRadiusResult{}, errors.New("forced error")
+	}
+
+	if err, ok := mock.errs[identifier]; ok {
+		return intel.BlastRadiusResult{}, err
+	}
+
+	if !opts.At.IsZero() && opts.At.Before(mock.snapshot) {
+		return intel.BlastRadiusResult{}, intel.ErrNotFound
+	}
+
+	model := "mock"
+	switch opts.Model {
+	case "":
+	case "custom":
+		model = opts.Model
+	default:
+		return intel.BlastRadiusResult{}, fmt.Errorf("%w: %v", intel.ErrUnknownModel, opts.Model)
+	}
+
+	if typ == mock.typ && identifier == mock.identifier {
+		result := intel.BlastRadiusResult{
+			Score:    mock.score,
+			Metadata: mock.metadata(model),
+		}
+		return result, nil
+	}
+
+	return intel.BlastRadiusResult{}, intel.ErrNotFound
+}
+
+func (mock intelMock) metadata(model string) intel.Metadata {
+	return intel.Metadata{
+		Model:        model,
+		ModelVersion: "1",
+		VertexID:     "vid1",
+		Resolution:   intel.ResolutionDirect,
+		Snapshot:     intel.Snapshot{ID: "sid1", Timestamp: mock.snapshot},
+		DurationMs:   10,
+	}
+}
+
+func (mock intelMock) BlastRadiusExplain(ctx context.Context, typ, identifier string, opts intel.BlastRadiusOptions) (intel.BlastRadiusExplanation, error) {
+	if mock.forceError {
+		return intel.BlastRadiusExplanation{}, errors.New("forced error")
+	}
+
+	if typ == mock.typ && identifier == mock.identifier {
+		explanation := intel.BlastRadiusExplanation{
+			Score:     mock.score,
+			Metadata:  mock.metadata("mock"),
+			Resources: mock.resources,
+		}
+		return explanation, nil
+	}
+
+	return intel.BlastRadiusExplanation{}, intel.ErrNotFound
+}
+
+func (mock intelMock) BlastRadiusTrend(ctx context.Context, typ, identifier string, from, to time.Time, model string) (intel.BlastRadiusTrend, error) {
+	if mock.forceError {
+		return intel.BlastRadiusTrend{}, errors.New("forced error")
+	}
+
+	switch model {
+	case "":
+		model = "mock"
+	case "custom":
+	default:
+		return intel.BlastRadiusTrend{}, fmt.Errorf("%w: %v", intel.ErrUnknownModel, model)
+	}
+
+	if typ != mock.typ || identifier != mock.identifier {
+		return intel.BlastRadiusTrend{}, intel.ErrNotFound
+	}
+
+	// Time ranges longer than a year contain too many snapshots.
+	if to.Sub(from) > 365*24*time.Hour {
+		return intel.BlastRadiusTrend{}, intel.ErrTooManySnapshots
+	}
+
+	var trend intel.BlastRadiusTrend
+	for _, p := range mock.trend {
+		if p.Timestamp.Before(from) || p.Timestamp.After(to) {
+			continue
+		}
+		trend.Points = append(trend.Points, p)
+	}
+
+	if len(trend.Points) == 0 {
+		return intel.BlastRadiusTrend{}, intel.ErrNotFound
+	}
+
+	trend.Metadata = mock.trendMetadata(model, trend.Points)
+	return trend, nil
+}
+
+func (mock intelMock) trendMetadata(model string, points []intel.TrendPoint) intel.TrendMetadata {
+	first, last := points[0], points[len(points)-1]
+	return intel.TrendMetadata{
+		Model:        model,
+		ModelVersion: "1",
+		From:         intel.Snapshot{ID: first.SnapshotID, Timestamp: first.Timestamp},
+		To:           intel.Snapshot{ID: last.SnapshotID, Timestamp: last.Timestamp},
+		DurationMs:   10,
+	}
+}
+
+func (mock intelMock) BlastRadiusDiff(ctx context.Context, typ, identifier string, from, to time.Time) (intel.BlastRadiusDiff, error) {
+	if mock.forceError {
+		return intel.BlastRadiusDiff{}, errors.New("forced error")
+	}
+
+	if typ != mock.typ || identifier != mock.identifier {
+		return intel.BlastRadiusDiff{}, intel.ErrNotFound
+	}
+
+	return mock.diff, nil
+}
+
+func (mock intelMock) BlastRadiusBatch(ctx context.Context, assets []intel.Asset) []intel.BlastRadiusBatchResult {
+	var results []intel.BlastRadiusBatchResult
+	for _, asset := range assets {
+		result := intel.BlastRadiusBatchResult{Asset: asset}
+		if err, ok := mock.errs[asset.Identifier]; ok {
+			result.Err = err
+		} else {
+			result.Result, result.Err = mock.BlastRadius(ctx, asset.Type, asset.Identifier, intel.BlastRadiusOptions{})
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func (mock intelMock) InternetExposure(ctx context.Context, typ, identifier string) (intel.InternetExposureResult, error) {
+	if mock.forceError {
+		return intel.InternetExposureResult{}, errors.New("forced error")
+	}
+
+	if typ == mock.typ && identifier == mock.identifier {
+		return mock.exposure, nil
+	}
+
+	return intel.InternetExposureResult{}, intel.ErrNotFound
+}
+
+func (mock intelMock) RiskScore(ctx context.Context, typ, identifier string) (intel.RiskScoreResult, error) {
+	if mock.forceError {
+		return intel.RiskScoreResult{}, errors.New("forced error")
+	}
+
+	if typ == mock.typ && identifier == mock.identifier {
+		return mock.risk, nil
+	}
+
+	return intel.RiskScoreResult{}, intel.ErrNotFound
+}
+
+func (mock intelMock) ResolveAsset(ctx context.Context, typ, identifier string) (intel.AssetResolution, error) {
+	if mock.forceError {
+		return intel.AssetResolution{}, errors.New("forced error")
+	}
+
+	if typ == mock.typ && identifier == mock.identifier {
+		return mock.resolution, nil
+	}
+
+	return intel.AssetResolution{}, intel.ErrNotFound
+}
+
+func (mock intelMock) Asset(ctx context.Context, vid string, depth int) (intel.AssetDetail, error) {
+	if mock.forceError {
+		return intel.AssetDetail{}, errors.New("forced error")
+	}
+
+	if depth < 1 || depth > 2 {
+		return intel.AssetDetail{}, fmt.Errorf("%w: %v", intel.ErrInvalidDepth, depth)
+	}
+
+	if vid == mock.asset.ID {
+		return mock.asset, nil
+	}
+
+	return intel.AssetDetail{}, intel.ErrNotFound
+}
+
+func (mock intelMock) SearchAssets(ctx context.Context, q intel.AssetQuery) (intel.AssetPage, error) {
+	if mock.forceError {
+		return intel.AssetPage{}, errors.New("forced error")
+	}
+
+	if err, ok := mock.errs[q.Cursor]; ok {
+		return intel.AssetPage{}, err
+	}
+
+	if cmp.Equal(q, mock.query) {
+		return mock.page, nil
+	}
+
+	return intel.AssetPage{}, intel.ErrNotFound
 }
@@ -0,0 +1,409 @@
+// Package scenario loads declarative descriptions of altimeter-shaped graphs
+// into a Gremlin server.
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/adevinta/graph-intel-api/gremlin"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+	"gopkg.in/yaml.v3"
+)
+
+// Default values of the universe properties. They match the universe
+// queried by the intel API.
+const (
+	DefaultNamespace = "altimeter"
+	DefaultVersion   = 1
+)
+
+// batchSize is the maximum number of vertices or edges added by a single
+// Gremlin query.
+const batchSize = 50
+
+// Labels of the vertices and edges that link resources with universes and
+// snapshots.
+const (
+	universeLabel   = "Universe"
+	snapshotLabel   = "altimeter_snapshot"
+	universeOfLabel = "universe_of"
+	includesLabel   = "includes"
+)
+
+// ErrInvalidScenario is returned when a scenario is not valid.
+var ErrInvalidScenario = errors.New("invalid scenario")
+
+// Scenario describes a graph with the shape of the graphs written by
+// altimeter. Universes contain snapshots, snapshots include resources and
+// resources are linked by edges.
+type Scenario struct {
+	// Universes contains the universes of the graph.
+	Universes []Universe `yaml:"universes"`
+
+	// Snapshots contains the altimeter snapshots of the graph.
+	Snapshots []Snapshot `yaml:"snapshots"`
+
+	// Resources contains the resources of the graph.
+	Resources []Resource `yaml:"resources"`
+
+	// Edges contains the edges between resources.
+	Edges []Edge `yaml:"edges"`
+}
+
+// Universe is a universe vertex.
+type Universe struct {
+	// ID is the vertex ID.
+	ID string `yaml:"id"`
+
+	// Namespace is the namespace of the universe. If empty,
+	// [DefaultNamespace] is used.
+	Namespace string `yaml:"namespace"`
+
+	// Version is the version of the universe. If zero,
+	// [DefaultVersion] is used.
+	Version int `yaml:"version"`
+}
+
+// Snapshot is an altimeter snapshot vertex.
+type Snapshot struct {
+	// ID is the vertex ID.
+	ID string `yaml:"id"`
+
+	// Universe is the ID of the universe of the snapshot.
+	Universe string `yaml:"universe"`
+
+	// Timestamp is the time of the snapshot in Unix seconds.
+	Timestamp int64 `yaml:"timestamp"`
+}
+
+// Resource is a resource vertex.
+type Resource struct {
+	// ID is the vertex ID.
+	ID string `yaml:"id"`
+
+	// Label is the vertex label, like "ec2:instance".
+	Label string `yaml:"label"`
+
+	// Snapshots contains the IDs of the snapshots that include the
+	// resource.
+	Snapshots []string `yaml:"snapshots"`
+
+	// Properties contains the properties of the vertex. Values must be
+	// strings, numbers, booleans or lists of them. Every element of a
+	// list is stored as a value of a multi-valued property.
+	Properties map[string]any `yaml:"properties"`
+}
+
+// Edge is an edge between two resources.
+type Edge struct {
+	// Label is the edge label, like "resource_link".
+	Label string `yaml:"label"`
+
+	// From is the ID of the out vertex.
+	From string `yaml:"from"`
+
+	// To is the ID of the in vertex.
+	To string `yaml:"to"`
+
+	// Properties contains the properties of the edge. Values must be
+	// strings, numbers or booleans.
+	Properties map[string]any `yaml:"properties"`
+}
+
+// Parse parses a YAML or JSON scenario and validates it. Unknown fields are
+// rejected.
+func Parse(r io.Reader) (Scenario, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+
+	var sc Scenario
+	if err := dec.Decode(&sc); err != nil && !errors.Is(err, io.EOF) {
+		return Scenario{}, fmt.Errorf("%w: %v", ErrInvalidScenario, err)
+	}
+
+	if err := sc.Validate(); err != nil {
+		return Scenario{}, err
+	}
+	return sc, nil
+}
+
+// ReadFile parses the scenario file with the provided path.
+func ReadFile(path string) (Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Scenario{}, fmt.Errorf("could not open scenario file: %w", err)
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Validate checks that the vertex IDs are unique, that all the references
+// point to existing vertices and that the property values are supported.
+func (sc Scenario) Validate() error {
+	kinds := make(map[string]string)
+	add := func(kind, id string) error {
+		if id == "" {
+			return fmt.Errorf("%w: %v with empty ID", ErrInvalidScenario, kind)
+		}
+		if _, ok := kinds[id]; ok {
+			return fmt.Errorf("%w: duplicated ID %q", ErrInvalidScenario, id)
+		}
+		kinds[id] = kind
+		return nil
+	}
+	ref := func(kind, id, from string) error {
+		if kinds[id] != kind {
+			return fmt.Errorf("%w: unknown %v %q referenced by %q", ErrInvalidScenario, kind, id, from)
+		}
+		return nil
+	}
+
+	for _, u := range sc.Universes {
+		if err := add("universe", u.ID); err != nil {
+			return err
+		}
+	}
+	for _, s := range sc.Snapshots {
+		if err := add("snapshot", s.ID); err != nil {
+			return err
+		}
+		if err := ref("universe", s.Universe, s.ID); err != nil {
+			return err
+		}
+	}
+	for _, r := range sc.Resources {
+		if err := add("resource", r.ID); err != nil {
+			return err
+		}
+		if r.Label == "" {
+			return fmt.Errorf("%w: resource %q with empty label", ErrInvalidScenario, r.ID)
+		}
+		for _, sid := range r.Snapshots {
+			if err := ref("snapshot", sid, r.ID); err != nil {
+				return err
+			}
+		}
+		for k, v := range r.Properties {
+			if err := validateValue(v, true); err != nil {
+				return fmt.Errorf("%w: property %q of resource %q: %v", ErrInvalidScenario, k, r.ID, err)
+			}
+		}
+	}
+	for i, e := range sc.Edges {
+		from := fmt.Sprintf("edge %v", i)
+		if e.Label == "" {
+			return fmt.Errorf("%w: %v with empty label", ErrInvalidScenario, from)
+		}
+		if err := ref("resource", e.From, from); err != nil {
+			return err
+		}
+		if err := ref("resource", e.To, from); err != nil {
+			return err
+		}
+		for k, v := range e.Properties {
+			if err := validateValue(v, false); err != nil {
+				return fmt.Errorf("%w: property %q of %v: %v", ErrInvalidScenario, k, from, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateValue checks that v is a supported property value. Lists are
+// only supported if list is true.
+func validateValue(v any, list bool) error {
+	switch v := v.(type) {
+	case string, bool, int, float64:
+		return nil
+	case []any:
+		if !list {
+			return errors.New("lists are not supported")
+		}
+		for _, e := range v {
+			if err := validateValue(e, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported value %v", v)
+}
+
+// Reset removes all the vertices and edges of the graph.
+func Reset(ctx context.Context, conn gremlin.Connection) error {
+	_, err := conn.QueryOnce(ctx, "scenario_reset", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return nil, <-g.V().Drop().Iterate()
+	})
+	if err != nil {
+		return fmt.Errorf("query error: %w", err)
+	}
+	return nil
+}
+
+// Seed adds the vertices and edges of the scenario to the graph. The
+// universes, snapshots and resources are linked with "universe_of" and
+// "includes" edges. Elements are added in batches, so the graph may be left
+// partially seeded if an error occurs. The batches are not retried, because
+// a partially applied batch would fail with duplicated IDs.
+func Seed(ctx context.Context, conn gremlin.Connection, sc Scenario) error {
+	if err := sc.Validate(); err != nil {
+		return err
+	}
+
+	var vertices []vertex
+	for _, u := range sc.Universes {
+		namespace := u.Namespace
+		if namespace == "" {
+			namespace = DefaultNamespace
+		}
+		version := u.Version
+		if version == 0 {
+			version = DefaultVersion
+		}
+		vertices = append(vertices, vertex{
+			id:    u.ID,
+			label: universeLabel,
+			props: []property{{"namespace", namespace}, {"version", version}},
+		})
+	}
+	for _, s := range sc.Snapshots {
+		vertices = append(vertices, vertex{
+			id:    s.ID,
+			label: snapshotLabel,
+			props: []property{{"timestamp", s.Timestamp}},
+		})
+	}
+	for _, r := range sc.Resources {
+		vertices = append(vertices, vertex{
+			id:    r.ID,
+			label: r.Label,
+			props: sortedProperties(r.Properties),
+		})
+	}
+
+	var edges []edge
+	for _, s := range sc.Snapshots {
+		edges = append(edges, edge{label: universeOfLabel, from: s.Universe, to: s.ID})
+	}
+	for _, r := range sc.Resources {
+		for _, sid := range r.Snapshots {
+			edges = append(edges, edge{label: includesLabel, from: sid, to: r.ID})
+		}
+	}
+	for _, e := range sc.Edges {
+		edges = append(edges, edge{
+			label: e.Label,
+			from:  e.From,
+			to:    e.To,
+			props: sortedProperties(e.Properties),
+		})
+	}
+
+	for i := 0; i < len(vertices); i += batchSize {
+		if err := addVertices(ctx, conn, vertices[i:minInt(i+batchSize, len(vertices))]); err != nil {
+			return fmt.Errorf("could not add vertices: %w", err)
+		}
+	}
+	for i := 0; i < len(edges); i += batchSize {
+		if err := addEdges(ctx, conn, edges[i:minInt(i+batchSize, len(edges))]); err != nil {
+			return fmt.Errorf("could not add edges: %w", err)
+		}
+	}
+	return nil
+}
+
+// vertex is a vertex to be added to the graph.
+type vertex struct {
+	id    string
+	label string
+	props []property
+}
+
+// edge is an edge to be added to the graph.
+type edge struct {
+	label string
+	from  string
+	to    string
+	props []property
+}
+
+// property is a property of a vertex or an edge.
+type property struct {
+	key   string
+	value any
+}
+
+// sortedProperties returns the properties of m sorted by key, so the
+// elements are always added in the same way.
+func sortedProperties(m map[string]any) []property {
+	props := make([]property, 0, len(m))
+	for k, v := range m {
+		props = append(props, property{k, v})
+	}
+	sort.Slice(props, func(i, j int) bool { return props[i].key < props[j].key })
+	return props
+}
+
+// addVertices adds the provided vertices with a single query.
+func addVertices(ctx context.Context, conn gremlin.Connection, vertices []vertex) error {
+	_, err := conn.QueryOnce(ctx, "scenario_vertices", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		var t *gremlingo.GraphTraversal
+		for _, v := range vertices {
+			if t == nil {
+				t = g.AddV(v.label)
+			} else {
+				t = t.AddV(v.label)
+			}
+			t = t.Property(gremlingo.T.Id, v.id)
+
+			for _, p := range v.props {
+				values, ok := p.value.([]any)
+				if !ok {
+					t = t.Property(p.key, p.value)
+					continue
+				}
+				for _, value := range values {
+					t = t.Property(gremlingo.Cardinality.Set, p.key, value)
+				}
+			}
+		}
+		return nil, <-t.Iterate()
+	})
+	return err
+}
+
+// addEdges adds the provided edges with a single query.
+func addEdges(ctx context.Context, conn gremlin.Connection, edges []edge) error {
+	_, err := conn.QueryOnce(ctx, "scenario_edges", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		var t *gremlingo.GraphTraversal
+		for _, e := range edges {
+			if t == nil {
+				t = g.AddE(e.label)
+			} else {
+				t = t.AddE(e.label)
+			}
+			t = t.From(gremlingo.T__.V(e.from)).To(gremlingo.T__.V(e.to))
+
+			for _, p := range e.props {
+				t = t.Property(p.key, p.value)
+			}
+		}
+		return nil, <-t.Iterate()
+	})
+	return err
+}
+
+// minInt returns the minimum of a and b.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,233 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adevinta/graph-intel-api/gremlin"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+	"github.com/google/go-cmp/cmp"
+)
+
+// gremlinEndpoint and gremlinAuthMode configure the Gremlin backend used by
+// the tests. By default, the tests use an in-memory graph. If the
+// GREMLIN_TEST_ENDPOINT environment variable is set, they use the Gremlin
+// server listening on that endpoint.
+var gremlinEndpoint, gremlinAuthMode = gremlinTestBackend()
+
+func gremlinTestBackend() (endpoint, authMode string) {
+	if endpoint := os.Getenv("GREMLIN_TEST_ENDPOINT"); endpoint != "" {
+		return endpoint, "plain"
+	}
+	return "", "memory"
+}
+
+const testScenario = `
+universes:
+  - id: u0
+snapshots:
+  - id: s0
+    universe: u0
+    timestamp: 1000
+resources:
+  - id: i0
+    label: ec2:instance
+    snapshots: [s0]
+    properties:
+      arn: arn:aws:ec2:eu-west-1:123456789012:instance/i-0123456789abcdef0
+      tags: [a, b]
+  - id: sg0
+    label: ec2:security-group
+    snapshots: [s0]
+edges:
+  - label: transient_resource_link
+    from: i0
+    to: sg0
+    properties:
+      weight: 1.5
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		want    Scenario
+		wantErr error
+	}{
+		{
+			name: "valid YAML",
+			doc:  testScenario,
+			want: Scenario{
+				Universes: []Universe{{ID: "u0"}},
+				Snapshots: []Snapshot{{ID: "s0", Universe: "u0", Timestamp: 1000}},
+				Resources: []Resource{
+					{
+						ID:        "i0",
+						Label:     "ec2:instance",
+						Snapshots: []string{"s0"},
+						Properties: map[string]any{
+							"arn":  "arn:aws:ec2:eu-west-1:123456789012:instance/i-0123456789abcdef0",
+							"tags": []any{"a", "b"},
+						},
+					},
+					{ID: "sg0", Label: "ec2:security-group", Snapshots: []string{"s0"}},
+				},
+				Edges: []Edge{
+					{
+						Label:      "transient_resource_link",
+						From:       "i0",
+						To:         "sg0",
+						Properties: map[string]any{"weight": 1.5},
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "valid JSON",
+			doc:  `{"universes":[{"id":"u0","namespace":"test","version":2}],"snapshots":[{"id":"s0","universe":"u0","timestamp":1000}]}`,
+			want: Scenario{
+				Universes: []Universe{{ID: "u0", Namespace: "test", Version: 2}},
+				Snapshots: []Snapshot{{ID: "s0", Universe: "u0", Timestamp: 1000}},
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "empty",
+			doc:     "",
+			want:    Scenario{},
+			wantErr: nil,
+		},
+		{
+			name:    "unknown field",
+			doc:     `{"vertices":[]}`,
+			want:    Scenario{},
+			wantErr: ErrInvalidScenario,
+		},
+		{
+			name:    "duplicated ID",
+			doc:     `{"universes":[{"id":"u0"}],"snapshots":[{"id":"u0","universe":"u0"}]}`,
+			want:    Scenario{},
+			wantErr: ErrInvalidScenario,
+		},
+		{
+			name:    "unknown snapshot",
+			doc:     `{"resources":[{"id":"r0","label":"ip_range","snapshots":["s0"]}]}`,
+			want:    Scenario{},
+			wantErr: ErrInvalidScenario,
+		},
+		{
+			name:    "edge to snapshot",
+			doc:     `{"universes":[{"id":"u0"}],"snapshots":[{"id":"s0","universe":"u0"}],"resources":[{"id":"r0","label":"ip_range"}],"edges":[{"label":"includes","from":"s0","to":"r0"}]}`,
+			want:    Scenario{},
+			wantErr: ErrInvalidScenario,
+		},
+		{
+			name:    "unsupported property",
+			doc:     `{"resources":[{"id":"r0","label":"ip_range","properties":{"cidr":{"ip":"0.0.0.0/0"}}}]}`,
+			want:    Scenario{},
+			wantErr: ErrInvalidScenario,
+		},
+		{
+			name:    "list edge property",
+			doc:     `{"resources":[{"id":"r0","label":"ip_range"}],"edges":[{"label":"self","from":"r0","to":"r0","properties":{"tags":["a"]}}]}`,
+			want:    Scenario{},
+			wantErr: ErrInvalidScenario,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.doc))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: got=%v want=%v", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("scenario mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestReadFile_SharedScenarios(t *testing.T) {
+	paths, err := filepath.Glob("../_scenario/*.yaml")
+	if err != nil {
+		t.Fatalf("error listing scenarios: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no scenarios found")
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			if _, err := ReadFile(path); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSeed(t *testing.T) {
+	sc, err := Parse(strings.NewReader(testScenario))
+	if err != nil {
+		t.Fatalf("error parsing scenario: %v", err)
+	}
+
+	cfg := gremlin.Config{
+		Endpoint: gremlinEndpoint,
+		AuthMode: gremlinAuthMode,
+	}
+	conn, err := gremlin.NewConnection(cfg)
+	if err != nil {
+		t.Fatalf("error creating Gremlin connection: %v", err)
+	}
+	defer conn.Close()
+
+	if err := Reset(context.Background(), conn); err != nil {
+		t.Fatalf("error resetting graph: %v", err)
+	}
+
+	if err := Seed(context.Background(), conn, sc); err != nil {
+		t.Fatalf("error seeding graph: %v", err)
+	}
+
+	results, err := conn.Query(context.Background(), "test", func(g *gremlingo.GraphTraversalSource) ([]*gremlingo.Result, error) {
+		return g.
+			V().
+			HasLabel("Universe").Has("namespace", DefaultNamespace).Has("version", DefaultVersion).
+			Out("universe_of").HasLabel("altimeter_snapshot").Has("timestamp", 1000).
+			Out("includes").HasLabel("ec2:instance").
+			Project("id", "tags", "sg", "weight").
+			By(gremlingo.T__.Id()).
+			By(gremlingo.T__.Values("tags").Fold()).
+			By(gremlingo.T__.Out("transient_resource_link").Id()).
+			By(gremlingo.T__.OutE("transient_resource_link").Values("weight")).
+			ToList()
+	})
+	if err != nil {
+		t.Fatalf("query error: %v", err)
+	}
+
+	var got []any
+	for _, r := range results {
+		got = append(got, r.GetInterface())
+	}
+
+	want := []any{
+		map[any]any{
+			"id":     "i0",
+			"tags":   []any{"a", "b"},
+			"sg":     "sg0",
+			"weight": 1.5,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%v", diff)
+	}
+}